@@ -1,6 +1,7 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -36,14 +37,14 @@ func (service *HealthService) HealthCheckHandler() func(http.ResponseWriter, *ht
 	return fthealth.Handler(hc)
 }
 
-func (service *HealthService) checks(includeReadOnlyCheck bool) []fthealth.Check {
+func (service *HealthService) checks(includeWriteChecks bool) []fthealth.Check {
 	checks := []fthealth.Check{
 		service.esConnectivityHealthyCheck(),
 		service.esClusterIsHealthyCheck(),
 	}
 
-	if includeReadOnlyCheck {
-		checks = append(checks, service.indexIsWriteableCheck())
+	if includeWriteChecks {
+		checks = append(checks, service.indexIsWriteableCheck(), service.circuitBreakerCheck())
 	}
 
 	return checks
@@ -62,7 +63,7 @@ func (service *HealthService) esClusterIsHealthyCheck() fthealth.Check {
 }
 
 func (service *HealthService) healthChecker() (string, error) {
-	output, err := service.esHealthService.GetClusterHealth()
+	output, err := service.esHealthService.GetClusterHealth(context.Background())
 	if err != nil {
 		return "Cluster is not healthy: ", err
 	} else if output.Status != "green" {
@@ -85,7 +86,7 @@ func (service *HealthService) esConnectivityHealthyCheck() fthealth.Check {
 }
 
 func (service *HealthService) esConnectivityChecker() (string, error) {
-	_, err := service.esHealthService.GetClusterHealth()
+	_, err := service.esHealthService.GetClusterHealth(context.Background())
 	if err != nil {
 		return "Could not connect to elasticsearch", err
 	}
@@ -107,7 +108,7 @@ func (service *HealthService) indexIsWriteableCheck() fthealth.Check {
 }
 
 func (service *HealthService) readOnlyChecker() (string, error) {
-	readOnly, indexName, err := service.esHealthService.IsIndexReadOnly()
+	readOnly, indexName, err := service.esHealthService.IsIndexReadOnly(context.Background())
 	if err != nil {
 		return "Could not connect to elasticsearch", err
 	}
@@ -120,6 +121,28 @@ func (service *HealthService) readOnlyChecker() (string, error) {
 	return fmt.Sprintf("Elasticsearch index [%v] is writeable", indexName), nil
 }
 
+func (service *HealthService) circuitBreakerCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:             "check-elasticsearch-circuit-breaker",
+		BusinessImpact: "Concepts cannot be read from or written to Elasticsearch until the circuit breaker closes",
+		Name:           "Check the circuit breaker of the Elasticsearch calls is closed",
+		PanicGuide:     "https://runbooks.in.ft.com/up-crwes",
+		Severity:       2,
+		TechnicalSummary: `Elasticsearch rejected consecutive calls because it is overloaded (429 or 503), so the requests
+		fail fast with a 503 until the circuit breaker cooldown is over. Check the cluster load on /__health-details.`,
+		Checker: service.circuitBreakerChecker,
+	}
+}
+
+func (service *HealthService) circuitBreakerChecker() (string, error) {
+	state := service.esHealthService.CircuitBreaker()
+	if state.Open() {
+		err := fmt.Errorf("Circuit breaker is open after %d overloaded calls, retrying in %v", state.ConsecutiveFailures, state.RetryAfter.Round(time.Second))
+		return err.Error(), err
+	}
+	return fmt.Sprintf("Circuit breaker is %s", state.State), nil
+}
+
 func (service *HealthService) GTG() gtg.Status {
 	var statusChecker []gtg.StatusChecker
 	for _, c := range service.checks(false) {
@@ -138,21 +161,33 @@ func gtgCheck(handler func() (string, error)) gtg.Status {
 	return gtg.Status{GoodToGo: true}
 }
 
+// healthDetails is the cluster health with the usage of the bulk requests in flight. The error getting the cluster
+// health replaces it when it is unavailable.
+type healthDetails struct {
+	*service.ClusterHealth
+	ClusterHealthError string            `json:"clusterHealthError,omitempty"`
+	Bulk               service.BulkUsage `json:"bulk"`
+}
+
 //HealthDetails returns the response from elasticsearch service /__health endpoint - describing the cluster health
 func (service *HealthService) HealthDetails(writer http.ResponseWriter, req *http.Request) {
 
 	writer.Header().Set("Content-Type", "application/json")
 
-	output, err := service.esHealthService.GetClusterHealth()
+	details := healthDetails{Bulk: service.esHealthService.BulkUsage()}
+	output, err := service.esHealthService.GetClusterHealth(req.Context())
 	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		details.ClusterHealthError = err.Error()
+	} else {
+		details.ClusterHealth = output
 	}
 
-	var response []byte
-	response, err = json.Marshal(*output)
+	response, marshalErr := json.Marshal(details)
+	if marshalErr != nil {
+		response = []byte(marshalErr.Error())
+	}
 	if err != nil {
-		response = []byte(err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
 	}
 
 	_, err = writer.Write(response)
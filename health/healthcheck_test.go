@@ -5,21 +5,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"context"
 
 	"github.com/Financial-Times/concept-rw-elasticsearch/service"
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
-	"github.com/olivere/elastic/v7"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 var (
-	happyESCluster   = &elastic.ClusterHealthResponse{Status: "green"}
-	unhappyESCluster = &elastic.ClusterHealthResponse{Status: "red"}
+	happyESCluster   = &service.ClusterHealth{Status: "green"}
+	unhappyESCluster = &service.ClusterHealth{Status: "red"}
+	closedCircuit    = service.CircuitBreakerState{State: service.CircuitClosed}
 )
 
 func TestHealthDetailsHealthyCluster(t *testing.T) {
@@ -32,6 +33,7 @@ func TestHealthDetailsHealthyCluster(t *testing.T) {
 
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(happyESCluster, nil)
+	esService.On("BulkUsage").Return(service.BulkUsage{InFlightBytes: 1024, InFlightActions: 2, MaxInFlightBytes: 4096, MaxInFlightActions: 10})
 	healthService := NewHealthService(esService)
 
 	//create a responseRecorder
@@ -53,7 +55,7 @@ func TestHealthDetailsHealthyCluster(t *testing.T) {
 			contentType, "application/json")
 	}
 
-	var respObject *elastic.ClusterHealthResponse
+	var respObject *service.ClusterHealth
 	err = json.Unmarshal(rr.Body.Bytes(), &respObject)
 	if err != nil {
 		t.Errorf("Unmarshalling request response failed. %v", err)
@@ -62,6 +64,12 @@ func TestHealthDetailsHealthyCluster(t *testing.T) {
 		t.Errorf("Cluster status it is not as expected, got %v want %v", respObject.Status, "green")
 	}
 
+	var details struct {
+		Bulk service.BulkUsage `json:"bulk"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+	assert.Equal(t, service.BulkUsage{InFlightBytes: 1024, InFlightActions: 2, MaxInFlightBytes: 4096, MaxInFlightActions: 10}, details.Bulk)
+
 	esService.AssertExpectations(t)
 }
 
@@ -75,6 +83,7 @@ func TestHealthDetailsReturnsError(t *testing.T) {
 
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(unhappyESCluster, errors.New("computer says no"))
+	esService.On("BulkUsage").Return(service.BulkUsage{InFlightBytes: 1024, InFlightActions: 2, MaxInFlightBytes: 4096, MaxInFlightActions: 10})
 	healthService := NewHealthService(esService)
 
 	//create a responseRecorder
@@ -96,9 +105,11 @@ func TestHealthDetailsReturnsError(t *testing.T) {
 			contentType, "application/json")
 	}
 
-	if rr.Body.Bytes() != nil {
-		t.Error("Response body should be empty")
-	}
+	var details map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+	assert.Equal(t, "computer says no", details["clusterHealthError"])
+	assert.Equal(t, map[string]interface{}{"inFlightBytes": float64(1024), "inFlightActions": float64(2), "maxInFlightBytes": float64(4096), "maxInFlightActions": float64(10)}, details["bulk"])
+	assert.NotContains(t, details, "status", "the cluster health should not be reported when it could not be got")
 
 	esService.AssertExpectations(t)
 }
@@ -168,6 +179,7 @@ func TestHappyHealthCheck(t *testing.T) {
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(happyESCluster, nil)
 	esService.On("IsIndexReadOnly").Return(false, "indexName", nil)
+	esService.On("CircuitBreaker").Return(closedCircuit)
 	healthService := NewHealthService(esService)
 
 	rr := httptest.NewRecorder()
@@ -193,6 +205,7 @@ func TestHealthCheckUnhealthyESCluster(t *testing.T) {
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(unhappyESCluster, nil)
 	esService.On("IsIndexReadOnly").Return(false, "indexName", nil)
+	esService.On("CircuitBreaker").Return(closedCircuit)
 	healthService := NewHealthService(esService)
 
 	rr := httptest.NewRecorder()
@@ -223,6 +236,7 @@ func TestHealthCheckNoESClusterConnection(t *testing.T) {
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(unhappyESCluster, errors.New("computer says no"))
 	esService.On("IsIndexReadOnly").Return(false, "indexName", nil)
+	esService.On("CircuitBreaker").Return(closedCircuit)
 	healthService := NewHealthService(esService)
 
 	rr := httptest.NewRecorder()
@@ -253,6 +267,7 @@ func TestHealthCheckReadOnlyIndex(t *testing.T) {
 	esService := new(EsServiceMock)
 	esService.On("GetClusterHealth").Return(happyESCluster, nil)
 	esService.On("IsIndexReadOnly").Return(true, "indexName", nil)
+	esService.On("CircuitBreaker").Return(closedCircuit)
 
 	healthService := NewHealthService(esService)
 
@@ -277,48 +292,107 @@ func TestHealthCheckReadOnlyIndex(t *testing.T) {
 
 }
 
+func TestHealthCheckOpenCircuitBreaker(t *testing.T) {
+	req, err := http.NewRequest("GET", "/__health", nil)
+	assert.NoError(t, err, "HTTP request to healthcheck should be consistent")
+
+	esService := new(EsServiceMock)
+	esService.On("GetClusterHealth").Return(happyESCluster, nil)
+	esService.On("IsIndexReadOnly").Return(false, "indexName", nil)
+	esService.On("CircuitBreaker").Return(service.CircuitBreakerState{State: service.CircuitOpen, ConsecutiveFailures: 5, RetryAfter: 30 * time.Second})
+
+	healthService := NewHealthService(esService)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(healthService.HealthCheckHandler())
+
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "HealthCheck should return HTTP 200 OK")
+
+	checks, err := parseHealthcheck(rr.Body.String())
+	assert.NoError(t, err, "HealthCheck Response Body should be consistent")
+
+	for _, check := range checks {
+		if check.ID == "check-elasticsearch-circuit-breaker" {
+			assert.False(t, check.Ok)
+			assert.Equal(t, "Circuit breaker is open after 5 overloaded calls, retrying in 30s", check.CheckOutput)
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+
+	esService.AssertExpectations(t)
+}
+
 type EsServiceMock struct {
 	mock.Mock
 }
 
-func (m *EsServiceMock) LoadData(ctx context.Context, conceptType string, uuid string, payload service.EsModel) (bool, *elastic.IndexResponse, error) {
+func (m *EsServiceMock) LoadData(ctx context.Context, conceptType string, uuid string, payload service.EsModel) (bool, *service.IndexResult, error) {
 	args := m.Called(ctx, conceptType, uuid, payload)
-	return args.Bool(0), args.Get(1).(*elastic.IndexResponse), args.Error(1)
+	return args.Bool(0), args.Get(1).(*service.IndexResult), args.Error(1)
 }
 
-func (m *EsServiceMock) ReadData(uuid string) (*elastic.GetResult, error) {
-	args := m.Called(uuid)
-	return args.Get(0).(*elastic.GetResult), args.Error(1)
+func (m *EsServiceMock) ReadData(ctx context.Context, uuid string) (*service.GetResult, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(*service.GetResult), args.Error(1)
 }
 
-func (m *EsServiceMock) DeleteData(ctx context.Context, conceptType string, uuid string) (*elastic.DeleteResponse, error) {
+func (m *EsServiceMock) DeleteData(ctx context.Context, conceptType string, uuid string) (*service.DeleteResult, error) {
 	args := m.Called(ctx, conceptType, uuid)
-	return args.Get(0).(*elastic.DeleteResponse), args.Error(1)
+	return args.Get(0).(*service.DeleteResult), args.Error(1)
+}
+
+func (m *EsServiceMock) LoadBulkData(ctx context.Context, conceptType string, uuid string, payload interface{}) (bool, error) {
+	args := m.Called(ctx, conceptType, uuid, payload)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *EsServiceMock) ReadTombstone(ctx context.Context, uuid string) (*service.Tombstone, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(*service.Tombstone), args.Error(1)
+}
+
+func (m *EsServiceMock) History(ctx context.Context, uuid string) ([]service.ChangeRecord, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).([]service.ChangeRecord), args.Error(1)
+}
+
+func (m *EsServiceMock) CircuitBreaker() service.CircuitBreakerState {
+	args := m.Called()
+	return args.Get(0).(service.CircuitBreakerState)
 }
 
-func (m *EsServiceMock) LoadBulkData(uuid string, payload interface{}) {
-	m.Called(uuid, payload)
+func (m *EsServiceMock) PlanWrite(ctx context.Context, conceptType string, concept service.Concept, payload service.EsModel, bulk bool) ([]service.PlannedAction, error) {
+	args := m.Called(ctx, conceptType, concept, payload, bulk)
+	return args.Get(0).([]service.PlannedAction), args.Error(1)
 }
 
-func (m *EsServiceMock) PatchUpdateConcept(uuid string, payload service.PayloadPatch) {
-	m.Called(uuid, payload)
+func (m *EsServiceMock) BulkUsage() service.BulkUsage {
+	args := m.Called()
+	return args.Get(0).(service.BulkUsage)
+}
+
+func (m *EsServiceMock) PatchUpdateConcept(ctx context.Context, conceptType string, uuid string, payload service.PayloadPatch) error {
+	args := m.Called(ctx, conceptType, uuid, payload)
+	return args.Error(0)
 }
 
 func (m *EsServiceMock) CleanupData(ctx context.Context, concept service.Concept) {
 	m.Called(ctx, concept)
 }
 
-func (m *EsServiceMock) CloseBulkProcessor() error {
-	args := m.Called()
+func (m *EsServiceMock) CloseBulkProcessor(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 
-func (m *EsServiceMock) GetClusterHealth() (*elastic.ClusterHealthResponse, error) {
+func (m *EsServiceMock) GetClusterHealth(_ context.Context) (*service.ClusterHealth, error) {
 	args := m.Called()
-	return args.Get(0).(*elastic.ClusterHealthResponse), args.Error(1)
+	return args.Get(0).(*service.ClusterHealth), args.Error(1)
 }
 
-func (m *EsServiceMock) IsIndexReadOnly() (bool, string, error) {
+func (m *EsServiceMock) IsIndexReadOnly(_ context.Context) (bool, string, error) {
 	args := m.Called()
 	return args.Bool(0), args.String(1), args.Error(2)
 }
@@ -332,7 +406,17 @@ func parseHealthcheck(healthcheckJSON string) ([]fthealth.CheckResult, error) {
 	return result.Checks, err
 }
 
-func (m *EsServiceMock) GetAllIDs(_ context.Context, _ bool, _ bool) chan service.EsIDTypePair {
-	args := m.Called()
-	return args.Get(0).(chan service.EsIDTypePair)
+func (m *EsServiceMock) GetAllIDs(_ context.Context, scan service.IDScan) (chan service.IDScanPage, error) {
+	args := m.Called(scan)
+	return args.Get(0).(chan service.IDScanPage), args.Error(1)
+}
+
+func (m *EsServiceMock) CountConcepts(_ context.Context, filter service.ConceptFilter) (int64, error) {
+	args := m.Called(filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *EsServiceMock) ExportConcepts(_ context.Context, filter service.ExportFilter) (chan service.ExportedConcept, error) {
+	args := m.Called(filter)
+	return args.Get(0).(chan service.ExportedConcept), args.Error(1)
 }
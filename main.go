@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Financial-Times/concept-rw-elasticsearch/health"
@@ -12,6 +17,7 @@ import (
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/http-handlers-go/httphandlers"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
@@ -20,6 +26,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const conceptTypesReloadInterval = 30 * time.Second
+
 func main() {
 	app := cli.App("concept-rw-es", "Service for loading concepts into elasticsearch")
 	appSystemCode := app.String(cli.StringOpt{
@@ -46,6 +54,70 @@ func main() {
 		Desc:   "AES region",
 		EnvVar: "ELASTICSEARCH_REGION",
 	})
+	backend := app.String(cli.StringOpt{
+		Name:   "backend",
+		Value:  service.ElasticsearchBackend,
+		Desc:   "Search backend the concepts are written to: elasticsearch, opensearch or memory (for local development)",
+		EnvVar: "SEARCH_BACKEND",
+	})
+	esSigningService := app.String(cli.StringOpt{
+		Name:   "elasticsearch-signing-service",
+		Value:  service.SigningServiceES,
+		Desc:   "AWS SigV4 signing service name: es for Elasticsearch and OpenSearch domains, aoss for OpenSearch Serverless collections",
+		EnvVar: "ELASTICSEARCH_SIGNING_SERVICE",
+	})
+	esAuth := app.String(cli.StringOpt{
+		Name:   "elasticsearch-auth",
+		Desc:   "Authentication strategy used to connect to elasticsearch: none, basic, api-key, bearer, mtls or aws-sigv4. Defaults to none for the local region and aws-sigv4 otherwise",
+		EnvVar: "ELASTICSEARCH_AUTH",
+	})
+	esUsername := app.String(cli.StringOpt{
+		Name:   "elasticsearch-username",
+		Desc:   "Username used by the basic authentication strategy",
+		EnvVar: "ELASTICSEARCH_USERNAME",
+	})
+	esPassword := app.String(cli.StringOpt{
+		Name:   "elasticsearch-password",
+		Desc:   "Password used by the basic authentication strategy",
+		EnvVar: "ELASTICSEARCH_PASSWORD",
+	})
+	esAPIKey := app.String(cli.StringOpt{
+		Name:   "elasticsearch-api-key",
+		Desc:   "Base64 encoded API key used by the api-key authentication strategy",
+		EnvVar: "ELASTICSEARCH_API_KEY",
+	})
+	esBearerToken := app.String(cli.StringOpt{
+		Name:   "elasticsearch-bearer-token",
+		Desc:   "Token used by the bearer authentication strategy",
+		EnvVar: "ELASTICSEARCH_BEARER_TOKEN",
+	})
+	esCABundle := app.String(cli.StringOpt{
+		Name:   "elasticsearch-ca-bundle",
+		Desc:   "Path to a PEM file with the CA certificates trusted when connecting to elasticsearch",
+		EnvVar: "ELASTICSEARCH_CA_BUNDLE",
+	})
+	esClientCert := app.String(cli.StringOpt{
+		Name:   "elasticsearch-client-cert",
+		Desc:   "Path to the PEM client certificate used by the mtls authentication strategy",
+		EnvVar: "ELASTICSEARCH_CLIENT_CERT",
+	})
+	esClientKey := app.String(cli.StringOpt{
+		Name:   "elasticsearch-client-key",
+		Desc:   "Path to the PEM client key used by the mtls authentication strategy",
+		EnvVar: "ELASTICSEARCH_CLIENT_KEY",
+	})
+	esHealthCheckInterval := app.Int(cli.IntOpt{
+		Name:   "elasticsearch-health-check-interval",
+		Value:  30,
+		Desc:   "How frequently (in seconds) to check the elasticsearch connection, the client is rebuilt after repeated failures or when its AWS credentials expired",
+		EnvVar: "ELASTICSEARCH_HEALTH_CHECK_INTERVAL",
+	})
+	esMaxConnectionFailures := app.Int(cli.IntOpt{
+		Name:   "elasticsearch-max-connection-failures",
+		Value:  3,
+		Desc:   "Number of consecutive failed elasticsearch connection checks after which the client is rebuilt",
+		EnvVar: "ELASTICSEARCH_MAX_CONNECTION_FAILURES",
+	})
 	indexName := app.String(cli.StringOpt{
 		Name:   "index-name",
 		Value:  "all-concepts",
@@ -70,12 +142,150 @@ func main() {
 		Desc:   "Elasticsearch bulk processor should commit requests if size of requests >= 2 MB (default)",
 		EnvVar: "ELASTICSEARCH_BULK_SIZE",
 	})
+	bulkMaxInFlightBytes := app.Int(cli.IntOpt{
+		Name:   "bulk-max-in-flight-bytes",
+		Value:  50 << 20,
+		Desc:   "Size (in bytes) of the bulk requests queued or being committed from which /bulk responds with a 429, 0 is unlimited",
+		EnvVar: "ELASTICSEARCH_BULK_MAX_IN_FLIGHT_BYTES",
+	})
+	bulkMaxInFlightActions := app.Int(cli.IntOpt{
+		Name:   "bulk-max-in-flight-actions",
+		Value:  20000,
+		Desc:   "Number of bulk requests queued or being committed from which /bulk responds with a 429, 0 is unlimited",
+		EnvVar: "ELASTICSEARCH_BULK_MAX_IN_FLIGHT_ACTIONS",
+	})
 	elasticsearchFlushInterval := app.Int(cli.IntOpt{
 		Name:   "flush-interval",
 		Value:  10,
 		Desc:   "How frequently should the elasticsearch bulk processor commit requests",
 		EnvVar: "ELASTICSEARCH_FLUSH_INTERVAL",
 	})
+	metricsBulkWorkers := app.Int(cli.IntOpt{
+		Name:   "metrics-bulk-workers",
+		Value:  1,
+		Desc:   "Number of workers used in the elasticsearch bulk processor of the metrics",
+		EnvVar: "ELASTICSEARCH_METRICS_WORKERS",
+	})
+	metricsBulkRequests := app.Int(cli.IntOpt{
+		Name:   "metrics-bulk-requests",
+		Value:  1000,
+		Desc:   "The elasticsearch bulk processor of the metrics commits when requests >= this number",
+		EnvVar: "ELASTICSEARCH_METRICS_REQUEST_NR",
+	})
+	metricsBulkSize := app.Int(cli.IntOpt{
+		Name:   "metrics-bulk-size",
+		Value:  2 << 20,
+		Desc:   "The elasticsearch bulk processor of the metrics commits when the size (in bytes) of the requests >= this number",
+		EnvVar: "ELASTICSEARCH_METRICS_BULK_SIZE",
+	})
+	metricsFlushInterval := app.Int(cli.IntOpt{
+		Name:   "metrics-flush-interval",
+		Value:  30,
+		Desc:   "How frequently (in seconds) the elasticsearch bulk processor of the metrics commits requests",
+		EnvVar: "ELASTICSEARCH_METRICS_FLUSH_INTERVAL",
+	})
+	shutdownTimeout := app.Int(cli.IntOpt{
+		Name:   "shutdown-timeout",
+		Value:  10,
+		Desc:   "How long (in seconds) to wait for in-flight requests to complete on shutdown",
+		EnvVar: "SHUTDOWN_TIMEOUT",
+	})
+	bulkFlushTimeout := app.Int(cli.IntOpt{
+		Name:   "bulk-flush-timeout",
+		Value:  15,
+		Desc:   "How long (in seconds) to wait for the elasticsearch bulk processor to commit queued requests on shutdown",
+		EnvVar: "ELASTICSEARCH_BULK_FLUSH_TIMEOUT",
+	})
+	writeAttempts := app.Int(cli.IntOpt{
+		Name:   "write-attempts",
+		Value:  3,
+		Desc:   "How many times a concept write is attempted while elasticsearch is overloaded (429 or 503)",
+		EnvVar: "ELASTICSEARCH_WRITE_ATTEMPTS",
+	})
+	readAttempts := app.Int(cli.IntOpt{
+		Name:   "read-attempts",
+		Value:  3,
+		Desc:   "How many times a concept read is attempted while elasticsearch is overloaded (429 or 503)",
+		EnvVar: "ELASTICSEARCH_READ_ATTEMPTS",
+	})
+	deleteAttempts := app.Int(cli.IntOpt{
+		Name:   "delete-attempts",
+		Value:  3,
+		Desc:   "How many times a concept delete is attempted while elasticsearch is overloaded (429 or 503)",
+		EnvVar: "ELASTICSEARCH_DELETE_ATTEMPTS",
+	})
+	findAttempts := app.Int(cli.IntOpt{
+		Name:   "find-attempts",
+		Value:  3,
+		Desc:   "How many times the search of the concorded concepts is attempted while elasticsearch is overloaded (429 or 503)",
+		EnvVar: "ELASTICSEARCH_FIND_ATTEMPTS",
+	})
+	healthAttempts := app.Int(cli.IntOpt{
+		Name:   "health-attempts",
+		Value:  3,
+		Desc:   "How many times the cluster health and index settings checks are attempted while elasticsearch is overloaded (429 or 503)",
+		EnvVar: "ELASTICSEARCH_HEALTH_ATTEMPTS",
+	})
+	writeTimeout := app.Int(cli.IntOpt{
+		Name:   "write-timeout",
+		Value:  10,
+		Desc:   "How long (in seconds) a concept write may take including its retries, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_WRITE_TIMEOUT",
+	})
+	readTimeout := app.Int(cli.IntOpt{
+		Name:   "read-timeout",
+		Value:  5,
+		Desc:   "How long (in seconds) a concept read may take including its retries, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_READ_TIMEOUT",
+	})
+	deleteTimeout := app.Int(cli.IntOpt{
+		Name:   "delete-timeout",
+		Value:  10,
+		Desc:   "How long (in seconds) a concept delete may take including its retries, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_DELETE_TIMEOUT",
+	})
+	findTimeout := app.Int(cli.IntOpt{
+		Name:   "find-timeout",
+		Value:  5,
+		Desc:   "How long (in seconds) the search of the concorded concepts may take including its retries, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_FIND_TIMEOUT",
+	})
+	healthTimeout := app.Int(cli.IntOpt{
+		Name:   "health-timeout",
+		Value:  5,
+		Desc:   "How long (in seconds) the cluster health and index settings checks may take, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_HEALTH_TIMEOUT",
+	})
+	scanTimeout := app.Int(cli.IntOpt{
+		Name:   "scan-timeout",
+		Value:  30,
+		Desc:   "How long (in seconds) the opening of the point in time of an /__ids scan and the fetch of each of its pages may take, 0 is unbounded",
+		EnvVar: "ELASTICSEARCH_SCAN_TIMEOUT",
+	})
+	retryInitialBackoff := app.Int(cli.IntOpt{
+		Name:   "retry-initial-backoff",
+		Value:  100,
+		Desc:   "The longest wait (in milliseconds) before the first retry, doubled for every following retry. The wait is jittered",
+		EnvVar: "ELASTICSEARCH_RETRY_INITIAL_BACKOFF",
+	})
+	retryMaxBackoff := app.Int(cli.IntOpt{
+		Name:   "retry-max-backoff",
+		Value:  2000,
+		Desc:   "The longest wait (in milliseconds) before a retry",
+		EnvVar: "ELASTICSEARCH_RETRY_MAX_BACKOFF",
+	})
+	breakerThreshold := app.Int(cli.IntOpt{
+		Name:   "circuit-breaker-threshold",
+		Value:  5,
+		Desc:   "How many consecutive calls failing because elasticsearch is overloaded open the circuit breaker, 0 disables it",
+		EnvVar: "ELASTICSEARCH_CIRCUIT_BREAKER_THRESHOLD",
+	})
+	breakerCooldown := app.Int(cli.IntOpt{
+		Name:   "circuit-breaker-cooldown",
+		Value:  30,
+		Desc:   "How long (in seconds) the circuit breaker fails the requests with a 503 before letting them through again",
+		EnvVar: "ELASTICSEARCH_CIRCUIT_BREAKER_COOLDOWN",
+	})
 	publicAPIHost := app.String(cli.StringOpt{
 		Name:   "apiURL",
 		Desc:   "API Gateway URL used when building the thing ID url in the response, in the format scheme://host",
@@ -89,6 +299,75 @@ func main() {
 		EnvVar: "ELASTICSEARCH_WHITELISTED_CONCEPTS",
 	})
 
+	typeIndices := app.String(cli.StringOpt{
+		Name:   "type-indices",
+		Desc:   "Comma separated concept type to index mappings of the whitelisted concepts written to an index of their own, e.g. people=concepts-people,organisations=concepts-orgs. Concepts are then read through the all-concepts alias",
+		EnvVar: "ELASTICSEARCH_TYPE_INDICES",
+	})
+	conceptTypesConfig := app.String(cli.StringOpt{
+		Name:   "concept-types-config",
+		Desc:   "Path to a JSON file mapping each concept type to its index, read/write permissions and converter. It is reloaded when it changes and replaces the whitelisted concepts",
+		EnvVar: "CONCEPT_TYPES_CONFIG",
+	})
+	softDelete := app.Bool(cli.BoolOpt{
+		Name:   "soft-delete",
+		Value:  false,
+		Desc:   "Whether deleted concepts leave a tombstone, which rejects the writes of the concept last modified before its deletion",
+		EnvVar: "SOFT_DELETE",
+	})
+	tombstoneIndex := app.String(cli.StringOpt{
+		Name:   "tombstone-index",
+		Value:  "concept-tombstones",
+		Desc:   "The name of the elasticsearch index of the tombstones left by soft deletes",
+		EnvVar: "ELASTICSEARCH_TOMBSTONE_INDEX",
+	})
+	auditTrail := app.Bool(cli.BoolOpt{
+		Name:   "audit-trail",
+		Value:  false,
+		Desc:   "Whether a change record is appended to the audit index for every write and delete of a concept, readable through /{concept-type}/{id}/history",
+		EnvVar: "AUDIT_TRAIL",
+	})
+	auditIndex := app.String(cli.StringOpt{
+		Name:   "audit-index",
+		Value:  "concept-audit",
+		Desc:   "The name of the elasticsearch index of the audit trail",
+		EnvVar: "ELASTICSEARCH_AUDIT_INDEX",
+	})
+	reconcileSourceURL := app.String(cli.StringOpt{
+		Name:   "reconcile-source-url",
+		Desc:   "URL listing the upstream concepts as newline delimited {\"uuid\":\"...\",\"hash\":\"...\"} objects followed by a {\"complete\":true,\"count\":...} trailer, the index is reconciled with them through /__reconcile when set",
+		EnvVar: "RECONCILE_SOURCE_URL",
+	})
+	reconcileInterval := app.Int(cli.IntOpt{
+		Name:   "reconcile-interval",
+		Value:  0,
+		Desc:   "How frequently (in minutes) to reconcile the index with the upstream concepts, 0 to only reconcile on demand",
+		EnvVar: "RECONCILE_INTERVAL",
+	})
+	reconcileDeleteExtras := app.Bool(cli.BoolOpt{
+		Name:   "reconcile-delete-extras",
+		Value:  false,
+		Desc:   "Whether the reconciliation deletes the concepts of the index that are not upstream",
+		EnvVar: "RECONCILE_DELETE_EXTRAS",
+	})
+	reconcileMaxDeletes := app.Int(cli.IntOpt{
+		Name:   "reconcile-max-deletes",
+		Value:  1000,
+		Desc:   "The most extra concepts a reconciliation deletes, none is deleted when there are more, 0 is unbounded",
+		EnvVar: "RECONCILE_MAX_DELETES",
+	})
+	reconcileMaxDeletePercent := app.Int(cli.IntOpt{
+		Name:   "reconcile-max-delete-percent",
+		Value:  10,
+		Desc:   "The largest percentage of the indexed concepts a reconciliation deletes, none is deleted when the extra concepts are more, 0 is unbounded",
+		EnvVar: "RECONCILE_MAX_DELETE_PERCENT",
+	})
+	reconcileRepublishURL := app.String(cli.StringOpt{
+		Name:   "reconcile-republish-url",
+		Desc:   "URL the reconciliation posts the uuids of the missing and stale concepts to, as {\"uuids\":[...]}, to have them republished",
+		EnvVar: "RECONCILE_REPUBLISH_URL",
+	})
+
 	esTraceLogging := app.Bool(cli.BoolOpt{
 		Name:   "elasticsearch-trace",
 		Value:  false,
@@ -103,52 +382,154 @@ func main() {
 	})
 
 	logger.InitLogger(*appSystemCode, *logLevel)
-	logger.Infof("[Startup] The writer handles the following concept types: %v\n", *elasticsearchWhitelistedConceptTypes)
 
-	// It seems that once we have a connection, we can lose and reconnect to Elastic OK
-	// so just keep going until successful
+	newClientFactory := func() func() (*elastic.Client, *credentials.Credentials, error) {
+		authConfig := service.AuthConfig{
+			Strategy:       *esAuth,
+			Username:       *esUsername,
+			Password:       *esPassword,
+			APIKey:         *esAPIKey,
+			BearerToken:    *esBearerToken,
+			CABundlePath:   *esCABundle,
+			ClientCertPath: *esClientCert,
+			ClientKeyPath:  *esClientKey,
+			SigningService: *esSigningService,
+		}
+		if err := authConfig.Validate(*esRegion); err != nil {
+			log.WithError(err).Fatal("Invalid elasticsearch authentication configuration")
+		}
+		return elasticClientFactory(authConfig, *esRegion, *esEndpoint, *esTraceLogging)
+	}
+
+	app.Command("purge-tombstones", "Remove the tombstones of the concepts deleted before the retention period", func(cmd *cli.Cmd) {
+		retention := cmd.Int(cli.IntOpt{
+			Name:   "retention",
+			Value:  30,
+			Desc:   "How long (in days) the tombstones are kept",
+			EnvVar: "TOMBSTONE_RETENTION",
+		})
+
+		cmd.Action = func() {
+			ec, _, err := newClientFactory()()
+			if err != nil {
+				log.WithError(err).Fatal("Creating elasticsearch client")
+			}
+			defer ec.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			purged, err := service.PurgeTombstones(ctx, ec, *tombstoneIndex, time.Now().AddDate(0, 0, -*retention))
+			if err != nil {
+				log.WithError(err).Fatal("Purging the tombstones")
+			}
+			logger.Infof("Purged %d tombstones older than %d days from %s", purged, *retention, *tombstoneIndex)
+		}
+	})
+
 	app.Action = func() {
+		newClient := newClientFactory()
+
 		ecc := make(chan *elastic.Client)
-		go func() {
-			defer close(ecc)
-			for {
-				awsSession, sessionErr := session.NewSession()
-				if sessionErr != nil {
-					log.WithError(sessionErr).Fatal("Failed to initialize AWS session")
-				}
-				credValues, err := awsSession.Config.Credentials.Get()
-				if err != nil {
-					log.WithError(err).Fatal("Failed to obtain AWS credentials values")
-				}
-				awsCreds := awsSession.Config.Credentials
-				log.Infof("Obtaining AWS credentials by using [%s] as provider", credValues.ProviderName)
-				accessConfig := service.NewAccessConfig(awsCreds, *esEndpoint, *esTraceLogging)
-				ec, err := service.NewElasticClient(*esRegion, accessConfig)
-				if err == nil {
-					logger.Info("connected to ElasticSearch")
-					ecc <- ec
-					return
-				}
-				logger.Errorf("could not connect to ElasticSearch: %s", err.Error())
-				time.Sleep(time.Minute)
+		backgroundCtx, stopBackgroundTasks := context.WithCancel(context.Background())
+		if *backend == service.MemoryBackend {
+			close(ecc) // concepts are kept in memory, no cluster to connect to
+		} else {
+			supervisor := service.NewClientSupervisor(newClient, ecc, service.ClientSupervisorConfig{
+				CheckInterval: time.Duration(*esHealthCheckInterval) * time.Second,
+				MaxFailures:   *esMaxConnectionFailures,
+				RetryInterval: time.Minute,
+			})
+			go supervisor.Run(backgroundCtx)
+		}
+
+		indices, err := service.ParseTypeIndices(*typeIndices)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid concept type indices")
+		}
+		conceptTypes := service.ConceptTypesFromList(strings.Split(*elasticsearchWhitelistedConceptTypes, ","), indices)
+		if *conceptTypesConfig != "" {
+			conceptTypes, err = service.LoadConceptTypes(*conceptTypesConfig)
+			if err != nil {
+				log.WithError(err).Fatal("Loading concept types")
 			}
-		}()
+			go conceptTypes.Watch(backgroundCtx, *conceptTypesConfig, conceptTypesReloadInterval)
+		}
+		logger.Infof("[Startup] The writer handles the following concept types: %v", conceptTypes.Names())
 
 		//create writer service
-		bulkProcessorConfig := service.NewBulkProcessorConfig(*nrOfElasticsearchWorkers, *nrOfElasticsearchRequests, *elasticsearchBulkSize, time.Duration(*elasticsearchFlushInterval)*time.Second)
+		bulkProcessorConfig := service.NewBulkProcessorConfig(*nrOfElasticsearchWorkers, *nrOfElasticsearchRequests, *elasticsearchBulkSize, time.Duration(*elasticsearchFlushInterval)*time.Second).
+			WithInFlightLimits(int64(*bulkMaxInFlightBytes), int64(*bulkMaxInFlightActions)).
+			WithMetricsProcessor(service.NewBulkProcessorConfig(*metricsBulkWorkers, *metricsBulkRequests, *metricsBulkSize, time.Duration(*metricsFlushInterval)*time.Second))
 
-		esService := service.NewEsService(ecc, *indexName, &bulkProcessorConfig)
+		var tombstones *service.TombstoneConfig
+		if *softDelete {
+			tombstones = &service.TombstoneConfig{Index: *tombstoneIndex}
+		}
+		var audit *service.AuditConfig
+		if *auditTrail {
+			audit = &service.AuditConfig{Index: *auditIndex}
+		}
+		retryPolicy := func(attempts int) service.RetryPolicy {
+			return service.RetryPolicy{
+				MaxAttempts:    attempts,
+				InitialBackoff: time.Duration(*retryInitialBackoff) * time.Millisecond,
+				MaxBackoff:     time.Duration(*retryMaxBackoff) * time.Millisecond,
+			}
+		}
+		retry := &service.RetryConfig{
+			Write:            retryPolicy(*writeAttempts),
+			Read:             retryPolicy(*readAttempts),
+			Delete:           retryPolicy(*deleteAttempts),
+			Find:             retryPolicy(*findAttempts),
+			Health:           retryPolicy(*healthAttempts),
+			BreakerThreshold: *breakerThreshold,
+			BreakerCooldown:  time.Duration(*breakerCooldown) * time.Second,
+		}
+		timeouts := &service.TimeoutConfig{
+			Write:  time.Duration(*writeTimeout) * time.Second,
+			Read:   time.Duration(*readTimeout) * time.Second,
+			Delete: time.Duration(*deleteTimeout) * time.Second,
+			Find:   time.Duration(*findTimeout) * time.Second,
+			Health: time.Duration(*healthTimeout) * time.Second,
+			Scan:   time.Duration(*scanTimeout) * time.Second,
+		}
+		esService, err := service.NewBackendService(*backend, ecc, *indexName, &bulkProcessorConfig, conceptTypes, tombstones, audit, retry, timeouts)
+		if err != nil {
+			log.WithError(err).Fatal("Creating search backend")
+		}
 
-		allowedConceptTypes := strings.Split(*elasticsearchWhitelistedConceptTypes, ",")
-		handler, err := resources.NewHandler(esService, allowedConceptTypes, *publicAPIHost)
+		handler, err := resources.NewHandler(esService, conceptTypes, *publicAPIHost)
 		if err != nil {
 			log.WithError(err).Fatal("Creating http handler")
 		}
-		defer handler.Close()
+
+		var reconcileHandler *resources.ReconcileHandler
+		if *reconcileSourceURL != "" {
+			reconciler := service.NewReconciler(esService, &http.Client{Timeout: 10 * time.Minute}, service.ReconcileConfig{
+				SourceURL:      *reconcileSourceURL,
+				DeleteExtras:   *reconcileDeleteExtras,
+				MaxDeletes:     *reconcileMaxDeletes,
+				MaxDeleteRatio: float64(*reconcileMaxDeletePercent) / 100,
+				RepublishURL:   *reconcileRepublishURL,
+			})
+			if *reconcileInterval > 0 {
+				go reconciler.Schedule(backgroundCtx, time.Duration(*reconcileInterval)*time.Minute)
+			}
+			reconcileHandler = resources.NewReconcileHandler(reconciler)
+		}
 
 		//create health service
 		healthService := health.NewHealthService(esService)
-		routeRequests(port, handler, healthService)
+		server := routeRequests(port, handler, reconcileHandler, healthService)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatalf("Unable to start: %v", err)
+			}
+		}()
+
+		waitForSignal()
+		stopBackgroundTasks() // no client must replace the one flushing the bulk processor
+		shutdown(server, handler, time.Duration(*shutdownTimeout)*time.Second, time.Duration(*bulkFlushTimeout)*time.Second)
 	}
 
 	err := app.Run(os.Args)
@@ -158,14 +539,44 @@ func main() {
 	}
 }
 
-func routeRequests(port *string, handler *resources.Handler, healthService *health.HealthService) {
+// elasticClientFactory returns the function creating the elastic clients of the endpoint, which obtains new AWS
+// credentials every time when they are needed
+func elasticClientFactory(authConfig service.AuthConfig, region string, endpoint string, traceLogging bool) func() (*elastic.Client, *credentials.Credentials, error) {
+	return func() (*elastic.Client, *credentials.Credentials, error) {
+		var awsCreds *credentials.Credentials
+		if authConfig.StrategyName(region) == service.AuthAWSSigV4 {
+			// a new session picks up rotated credentials files and web identity tokens
+			awsSession, err := session.NewSession()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to initialize AWS session: %w", err)
+			}
+			credValues, err := awsSession.Config.Credentials.Get()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to obtain AWS credentials values: %w", err)
+			}
+			awsCreds = awsSession.Config.Credentials
+			log.Infof("Obtaining AWS credentials by using [%s] as provider", credValues.ProviderName)
+		}
+		accessConfig := service.NewAccessConfig(awsCreds, endpoint, traceLogging, authConfig)
+		ec, err := service.NewElasticClient(region, accessConfig)
+		return ec, awsCreds, err
+	}
+}
+
+func routeRequests(port *string, handler *resources.Handler, reconcileHandler *resources.ReconcileHandler, healthService *health.HealthService) *http.Server {
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", handler.LoadBulkData).Methods("PUT")
 	servicesRouter.HandleFunc("/{concept-type}/{id}/metrics", handler.LoadMetrics).Methods("PUT")
+	servicesRouter.HandleFunc("/{concept-type}/{id}/history", handler.History).Methods("GET")
 	servicesRouter.HandleFunc("/{concept-type}/{id}", handler.LoadData).Methods("PUT")
 	servicesRouter.HandleFunc("/{concept-type}/{id}", handler.ReadData).Methods("GET")
 	servicesRouter.HandleFunc("/{concept-type}/{id}", handler.DeleteData).Methods("DELETE")
 	servicesRouter.HandleFunc("/__ids", handler.GetAllIDs).Methods("GET")
+	servicesRouter.HandleFunc("/__export", handler.Export).Methods("GET")
+	if reconcileHandler != nil {
+		servicesRouter.HandleFunc("/__reconcile", reconcileHandler.Reconcile).Methods("POST")
+		servicesRouter.HandleFunc("/__reconcile", reconcileHandler.LastReport).Methods("GET")
+	}
 
 	var monitoringRouter http.Handler = servicesRouter
 	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), monitoringRouter)
@@ -178,7 +589,31 @@ func routeRequests(port *string, handler *resources.Handler, healthService *heal
 
 	http.Handle("/", monitoringRouter)
 
-	if err := http.ListenAndServe(":"+*port, nil); err != nil {
-		logger.Fatalf("Unable to start: %v", err)
+	return &http.Server{Addr: ":" + *port}
+}
+
+func waitForSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	logger.Infof("[Shutdown] Received %v signal", sig)
+}
+
+// shutdown stops accepting new requests, waits for the in-flight ones to complete and then flushes the requests
+// queued in the bulk processor, each step bounded by its own timeout.
+func shutdown(server *http.Server, handler *resources.Handler, serverTimeout time.Duration, flushTimeout time.Duration) {
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), serverTimeout)
+	defer cancelServer()
+	if err := server.Shutdown(serverCtx); err != nil {
+		log.WithError(err).Error("[Shutdown] In-flight requests did not complete in time, closing remaining connections")
+		_ = server.Close()
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	if err := handler.Close(flushCtx); err != nil {
+		log.WithError(err).Error("[Shutdown] Failed to flush the elasticsearch bulk processor")
+		return
 	}
+	logger.Info("[Shutdown] Completed")
 }
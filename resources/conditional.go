@@ -0,0 +1,59 @@
+package resources
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/concept-rw-elasticsearch/service"
+)
+
+const (
+	etagHeader            = "ETag"
+	ifMatchHeader         = "If-Match"
+	ifNoneMatchHeader     = "If-None-Match"
+	ifModifiedSinceHeader = "If-Modified-Since"
+)
+
+// setValidators sets the ETag and Last-Modified headers of the concept, no Last-Modified if lastModified is unknown
+func setValidators(w http.ResponseWriter, etag string, lastModified string) {
+	if etag != "" {
+		w.Header().Set(etagHeader, etag)
+	}
+	if t, err := time.Parse(time.RFC3339, lastModified); err == nil {
+		w.Header().Set(lastModifiedHeader, t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// validatorLastModified returns the time the concept is validated with, the empty string when it is unknown. It is the
+// time the document was last indexed, which unlike its lastModified also changes when the metrics are patched. The
+// documents indexed before indexedAt was stamped on the patches fall back to their lastModified.
+func validatorLastModified(concept *service.EsConceptModel) string {
+	switch {
+	case concept == nil:
+		return ""
+	case concept.IndexedAt != "":
+		return concept.IndexedAt
+	case concept.Metrics != nil:
+		return ""
+	}
+	return concept.LastModified
+}
+
+// notModified reports whether the conditional read of the concept can be answered with a 304. If-Modified-Since is
+// ignored when the request has an If-None-Match, as the entity tag is the more accurate of the two.
+func notModified(r *http.Request, etag string, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get(ifNoneMatchHeader); ifNoneMatch != "" {
+		return service.ETagMatches(ifNoneMatch, etag, true)
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get(ifModifiedSinceHeader))
+	if err != nil {
+		return false
+	}
+	modified, err := time.Parse(time.RFC3339, lastModified)
+	if err != nil {
+		return false
+	}
+	// the Last-Modified header has a precision of a second
+	return !modified.Truncate(time.Second).After(ifModifiedSince)
+}
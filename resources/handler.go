@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/concept-rw-elasticsearch/service"
 	log "github.com/Financial-Times/go-logger"
@@ -20,70 +23,97 @@ var (
 	errPathUUID               = errors.New("Provided path UUID does not match request body")
 	errInvalidConceptModel    = errors.New("Invalid or incomplete concept model")
 	errUnsupportedConceptType = errors.New("Unsupported or invalid concept type")
+	errReadOnlyConceptType    = errors.New("Concept type is read-only")
 	errProcessingBody         = errors.New("Request body is not in the expected concept model format")
+	errInvalidLastModified    = errors.New("Invalid Last-Modified header, expected an HTTP date or an RFC3339 timestamp")
+	errBulkIfMatch            = errors.New("If-Match is not supported by bulk writes, which are not written synchronously")
+	errBulkRecreate           = errors.New("recreate is not supported by bulk writes, which are not written synchronously")
 )
 
 const (
 	notFoundResult = "not_found"
+	// lastModifiedHeader carries when the concept was last modified upstream, it overrides the lastModifiedEpoch of
+	// the payload
+	lastModifiedHeader = "Last-Modified"
+	retryAfterHeader   = "Retry-After"
 )
 
 // Handler handles http calls
 type Handler struct {
-	elasticService      service.EsService
-	allowedConceptTypes map[string]bool
-	publicAPIHost       string
+	elasticService service.EsService
+	conceptTypes   *service.ConceptTypes
+	publicAPIHost  string
 }
 
-func NewHandler(elasticService service.EsService, allowedConceptTypes []string, publicAPIHost string) (*Handler, error) {
+func NewHandler(elasticService service.EsService, conceptTypes *service.ConceptTypes, publicAPIHost string) (*Handler, error) {
 	if _, err := url.ParseRequestURI(publicAPIHost); err != nil {
 		return nil, err
 	}
 
-	allowedTypes := make(map[string]bool)
-	for _, v := range allowedConceptTypes {
-		allowedTypes[v] = true
-	}
-
 	return &Handler{
-		elasticService:      elasticService,
-		allowedConceptTypes: allowedTypes,
-		publicAPIHost:       publicAPIHost,
+		elasticService: elasticService,
+		conceptTypes:   conceptTypes,
+		publicAPIHost:  publicAPIHost,
 	}, nil
 }
 
+// checkWritable returns an error if the concept type is not supported or cannot be written
+func (h *Handler) checkWritable(conceptType string) error {
+	if _, found := h.conceptTypes.Get(conceptType); !found {
+		return errUnsupportedConceptType
+	}
+	if !h.conceptTypes.Writable(conceptType) {
+		return errReadOnlyConceptType
+	}
+	return nil
+}
+
 // LoadData processes a single ES concept entity
 func (h *Handler) LoadData(w http.ResponseWriter, r *http.Request) {
 	transactionID := tid.GetTransactionIDFromRequest(r)
 	ctx := tid.TransactionAwareContext(r.Context(), transactionID)
 
-	conceptType, concept, esModel, err := h.processPayload(r.WithContext(ctx))
+	dryRun, err := queryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	recreate, err := queryBool(r.URL.Query(), "recreate")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
 
+	conceptType, concept, esModel, err := h.processPayload(r.WithContext(ctx))
 	if err != nil {
-		var errStatus int
-		switch err {
-		case errUnsupportedConceptType:
-			errStatus = http.StatusNotFound
-		default:
-			errStatus = http.StatusBadRequest
-		}
-		writeMessage(w, err.Error(), errStatus)
+		writeError(w, r, err)
+		return
+	}
+	if dryRun != nil && *dryRun {
+		h.writeDryRun(ctx, w, r, conceptType, concept, esModel, false)
 		return
 	}
 
-	up, _, err := h.elasticService.LoadData(ctx, conceptType, concept.PreferredUUID(), esModel)
+	if ifMatch := r.Header.Get(ifMatchHeader); ifMatch != "" {
+		ctx = service.WithIfMatch(ctx, ifMatch)
+	}
+	if recreate != nil && *recreate {
+		ctx = service.WithRecreate(ctx)
+	}
+	up, res, err := h.elasticService.LoadData(ctx, conceptType, concept.PreferredUUID(), esModel)
 
 	if err != nil {
-		if err == service.ErrNoElasticClient {
-			writeMessage(w, "ES unavailable", http.StatusServiceUnavailable)
-			return
-		}
-
-		log.WithError(err).Warn("Failed to write data to elasticsearch.")
-		writeMessage(w, "Failed to write data to ES", http.StatusInternalServerError)
+		log.WithError(err).WithTransactionID(transactionID).Warn("Failed to write data to elasticsearch.")
+		writeError(w, r, err)
 		return
 	}
 
 	if !up {
+		if res != nil && res.Result == service.UnchangedResult {
+			h.elasticService.CleanupData(ctx, concept)
+			writeMessage(w, "Concept unchanged", http.StatusNotModified)
+			return
+		}
 		writeMessage(w, "Concept dropped", http.StatusNotModified)
 		return
 	}
@@ -97,19 +127,41 @@ func (h *Handler) LoadBulkData(w http.ResponseWriter, r *http.Request) {
 	transactionID := tid.GetTransactionIDFromRequest(r)
 	ctx := tid.TransactionAwareContext(r.Context(), transactionID)
 
-	_, concept, payload, err := h.processPayload(r.WithContext(ctx))
-	if err == errUnsupportedConceptType {
-		writeMessage(w, err.Error(), http.StatusNotFound)
+	dryRun, err := queryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if r.Header.Get(ifMatchHeader) != "" {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, errBulkIfMatch.Error())
+		return
+	}
+	if r.URL.Query().Get("recreate") != "" {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, errBulkRecreate.Error())
 		return
 	}
 
+	conceptType, concept, payload, err := h.processPayload(r.WithContext(ctx))
 	if err != nil {
-		writeMessage(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+	if dryRun != nil && *dryRun {
+		h.writeDryRun(ctx, w, r, conceptType, concept, payload, true)
 		return
 	}
 
-	h.elasticService.LoadBulkData(concept.PreferredUUID(), payload)
+	written, err := h.elasticService.LoadBulkData(ctx, conceptType, concept.PreferredUUID(), payload)
+	if err != nil {
+		log.WithError(err).WithTransactionID(transactionID).Warn("Failed to queue a concept")
+		writeError(w, r, err)
+		return
+	}
 	h.elasticService.CleanupData(ctx, concept)
+	if !written {
+		writeMessage(w, "Concept unchanged", http.StatusNotModified)
+		return
+	}
 	writeMessage(w, "Concept written successfully", http.StatusOK)
 }
 
@@ -119,8 +171,8 @@ func (h *Handler) LoadMetrics(w http.ResponseWriter, r *http.Request) {
 	uuid := vars["id"]
 	conceptType := vars["concept-type"]
 
-	if !h.allowedConceptTypes[conceptType] {
-		writeMessage(w, errUnsupportedConceptType.Error(), http.StatusNotFound)
+	if err := h.checkWritable(conceptType); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -130,27 +182,61 @@ func (h *Handler) LoadMetrics(w http.ResponseWriter, r *http.Request) {
 	err := dec.Decode(&metrics)
 
 	if err != nil {
-		writeMessage(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPayload, err.Error())
 		return
 	}
 
 	if metrics.Metrics == nil {
-		writeMessage(w, "Please supply metrics as a JSON object with a single property 'metrics'", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPayload, "Please supply metrics as a JSON object with a single property 'metrics'")
 		return
 	}
 
-	h.elasticService.PatchUpdateConcept(uuid, &metrics)
+	ctx := tid.TransactionAwareContext(r.Context(), tid.GetTransactionIDFromRequest(r))
+	if err := h.elasticService.PatchUpdateConcept(ctx, conceptType, uuid, &metrics); err != nil {
+		log.WithError(err).WithUUID(uuid).Error("Failed to queue the metrics of a concept")
+		writeError(w, r, err)
+		return
+	}
 	writeMessage(w, "Concept updated with metrics successfully", http.StatusOK)
 }
 
+// dryRunResponse is the document the write of a concept would store and the actions it would take
+type dryRunResponse struct {
+	ConceptType string                  `json:"conceptType"`
+	UUID        string                  `json:"uuid"`
+	Document    service.EsModel         `json:"document"`
+	Actions     []service.PlannedAction `json:"actions"`
+}
+
+// writeDryRun responds with the document and the actions of writing the concept, which is not written
+func (h *Handler) writeDryRun(ctx context.Context, w http.ResponseWriter, r *http.Request, conceptType string, concept service.Concept, esModel service.EsModel, bulk bool) {
+	actions, err := h.elasticService.PlanWrite(ctx, conceptType, concept, esModel, bulk)
+	if err != nil {
+		log.WithError(err).WithUUID(concept.PreferredUUID()).Error("Failed to plan the write of a concept")
+		writeError(w, r, err)
+		return
+	}
+	response := dryRunResponse{
+		ConceptType: conceptType,
+		UUID:        concept.PreferredUUID(),
+		Document:    esModel,
+		Actions:     actions,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.WithError(err).WithUUID(response.UUID).Warn("Failed to write the dry run of a concept")
+	}
+}
+
 func (h *Handler) processPayload(r *http.Request) (conceptType string, concept service.Concept, esModel service.EsModel, err error) {
 	vars := mux.Vars(r)
 	uuid := vars["id"]
 	conceptType = vars["concept-type"]
 
-	if !h.allowedConceptTypes[conceptType] {
-		return "", nil, nil, errUnsupportedConceptType
+	if err := h.checkWritable(conceptType); err != nil {
+		return "", nil, nil, err
 	}
+	typeConfig, _ := h.conceptTypes.Get(conceptType)
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -158,22 +244,47 @@ func (h *Handler) processPayload(r *http.Request) (conceptType string, concept s
 		return "", nil, nil, errProcessingBody
 	}
 
-	aggConceptModel, err := isAggregateConceptModel(body)
+	aggConceptModel, err := isAggregateConceptModel(typeConfig.Converter, body)
 	if err != nil {
 		log.WithError(err).Error("Failed to check if body json is an aggregate concept model or not")
 		return "", nil, nil, errProcessingBody
 	}
 
+	if err := service.ValidatePayload(typeConfig.SchemaVersion, typeConfig.Converter, body); err != nil {
+		return "", nil, nil, err
+	}
+
+	lastModified, err := headerLastModified(r)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
 	if aggConceptModel {
-		concept, esModel, err = processAggregateConceptModel(r.Context(), uuid, conceptType, h.publicAPIHost, body)
+		concept, esModel, err = processAggregateConceptModel(r.Context(), uuid, conceptType, typeConfig.Converter, h.publicAPIHost, body, lastModified)
 	} else {
-		concept, esModel, err = processConceptModel(r.Context(), uuid, conceptType, h.publicAPIHost, body)
+		concept, esModel, err = processConceptModel(r.Context(), uuid, conceptType, typeConfig.Converter, h.publicAPIHost, body, lastModified)
 	}
 
 	return conceptType, concept, esModel, err
 }
 
-func processConceptModel(ctx context.Context, uuid, conceptType, publicAPIHost string, body []byte) (concept service.ConceptModel, payload service.EsModel, err error) {
+// headerLastModified returns the upstream modification time of the request header, the zero time if there is none
+func headerLastModified(r *http.Request) (time.Time, error) {
+	value := r.Header.Get(lastModifiedHeader)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, errInvalidLastModified
+	}
+	return t, nil
+}
+
+func processConceptModel(ctx context.Context, uuid, conceptType, converter, publicAPIHost string, body []byte, lastModified time.Time) (concept service.ConceptModel, payload service.EsModel, err error) {
 	err = json.Unmarshal(body, &concept)
 	if err != nil {
 		log.WithError(err).Info("Failed to unmarshal body into concept model.")
@@ -196,11 +307,17 @@ func processConceptModel(ctx context.Context, uuid, conceptType, publicAPIHost s
 		err = nil // blank error just in case
 	}
 
-	payload, err = service.ConvertConceptToESConceptModel(concept, conceptType, transactionID, publicAPIHost)
-	return concept, payload, err
+	if lastModified.IsZero() {
+		lastModified = concept.LastModified()
+	}
+	payload, err = service.ConvertConcept(converter, concept, conceptType, transactionID, publicAPIHost, lastModified)
+	if err != nil {
+		return concept, payload, fmt.Errorf("%w: %v", errInvalidConceptModel, err)
+	}
+	return concept, payload, nil
 }
 
-func processAggregateConceptModel(ctx context.Context, uuid, conceptType, publicAPIHost string, body []byte) (concept service.AggregateConceptModel, esModel service.EsModel, err error) {
+func processAggregateConceptModel(ctx context.Context, uuid, conceptType, converter, publicAPIHost string, body []byte, lastModified time.Time) (concept service.AggregateConceptModel, esModel service.EsModel, err error) {
 	err = json.Unmarshal(body, &concept)
 	if err != nil {
 		log.WithError(err).Info("Failed to unmarshal body into aggregate concept model.")
@@ -222,8 +339,14 @@ func processAggregateConceptModel(ctx context.Context, uuid, conceptType, public
 		log.WithError(err).WithField(tid.TransactionIDKey, transactionID).Warn("Transaction ID not found to process aggregate concept model. Generated new transaction ID")
 	}
 
-	esModel, err = service.ConvertAggregateConceptToESConceptModel(concept, conceptType, transactionID, publicAPIHost)
-	return concept, esModel, err
+	if lastModified.IsZero() {
+		lastModified = concept.LastModified()
+	}
+	esModel, err = service.ConvertAggregateConcept(converter, concept, conceptType, transactionID, publicAPIHost, lastModified)
+	if err != nil {
+		return concept, esModel, fmt.Errorf("%w: %v", errInvalidConceptModel, err)
+	}
+	return concept, esModel, nil
 }
 
 func (h *Handler) ReadData(writer http.ResponseWriter, request *http.Request) {
@@ -231,27 +354,21 @@ func (h *Handler) ReadData(writer http.ResponseWriter, request *http.Request) {
 	uuid := vars["id"]
 	conceptType := vars["concept-type"]
 
-	if !h.allowedConceptTypes[conceptType] {
-		writeMessage(writer, errUnsupportedConceptType.Error(), http.StatusBadRequest)
+	if !h.conceptTypes.Readable(conceptType) {
+		writeError(writer, request, errUnsupportedConceptType)
 		return
 	}
 
-	getResult, err := h.elasticService.ReadData(uuid)
+	getResult, err := h.elasticService.ReadData(request.Context(), uuid)
 
 	if err != nil {
 		log.Error(err.Error())
-
-		if err == service.ErrNoElasticClient {
-			writer.WriteHeader(http.StatusServiceUnavailable)
-		} else {
-			writer.WriteHeader(http.StatusInternalServerError)
-		}
-
+		writeError(writer, request, err)
 		return
 	}
 
 	if !getResult.Found {
-		writer.WriteHeader(http.StatusNotFound)
+		h.writeTombstone(writer, request, uuid)
 		return
 	}
 
@@ -260,31 +377,81 @@ func (h *Handler) ReadData(writer http.ResponseWriter, request *http.Request) {
 	err = json.Unmarshal(getResult.Source, &esModel)
 	if err != nil {
 		log.Error(err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
+		writeError(writer, request, err)
 		return
 	}
 	esModel.Type = ""
 
+	etag := getResult.ETag()
+	lastModified := validatorLastModified(esModel.EsConceptModel)
+	setValidators(writer, etag, lastModified)
+	if notModified(request, etag, lastModified) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	writer.Header().Add("Content-Type", "application/json")
 	enc := json.NewEncoder(writer)
 	err = enc.Encode(esModel)
 	if err != nil {
-		log.Error(err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
+		log.WithError(err).WithUUID(uuid).Warn("Failed to write a concept")
+	}
+}
+
+// writeTombstone responds with the tombstone of a deleted concept as a 410, or a 404 if the concept is not known
+func (h *Handler) writeTombstone(writer http.ResponseWriter, request *http.Request, uuid string) {
+	tombstone, err := h.elasticService.ReadTombstone(request.Context(), uuid)
+	if err != nil {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to read the tombstone of a concept")
+	}
+	if tombstone == nil {
+		writeProblem(writer, request, http.StatusNotFound, codeConceptNotFound, "Concept not found")
+		return
+	}
+
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusGone)
+	if err := json.NewEncoder(writer).Encode(tombstone); err != nil {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to write the tombstone of a concept")
+	}
+}
+
+// History returns the latest change records of a concept from the audit trail
+func (h *Handler) History(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	uuid := vars["id"]
+	conceptType := vars["concept-type"]
+
+	if !h.conceptTypes.Readable(conceptType) {
+		writeError(writer, request, errUnsupportedConceptType)
+		return
+	}
+
+	records, err := h.elasticService.History(request.Context(), uuid)
+	if err != nil {
+		if err != service.ErrHistoryDisabled {
+			log.WithError(err).WithUUID(uuid).Error("Failed to read the history of a concept")
+		}
+		writeError(writer, request, err)
 		return
 	}
+
+	writer.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(records); err != nil {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to write the history of a concept")
+	}
 }
 
 // DeleteData handles a delete for a concept
 func (h *Handler) DeleteData(writer http.ResponseWriter, request *http.Request) {
 	transactionID := tid.GetTransactionIDFromRequest(request)
-	ctx := tid.TransactionAwareContext(context.Background(), transactionID)
+	ctx := tid.TransactionAwareContext(request.Context(), transactionID)
 
 	uuid := mux.Vars(request)["id"]
 	conceptType := mux.Vars(request)["concept-type"]
 
-	if !h.allowedConceptTypes[conceptType] {
-		writeMessage(writer, errUnsupportedConceptType.Error(), http.StatusBadRequest)
+	if err := h.checkWritable(conceptType); err != nil {
+		writeError(writer, request, err)
 		return
 	}
 
@@ -292,49 +459,281 @@ func (h *Handler) DeleteData(writer http.ResponseWriter, request *http.Request)
 
 	if err != nil {
 		log.Errorf(err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
+		writeError(writer, request, err)
 		return
 	}
 
 	if res.Result == notFoundResult {
-		writer.WriteHeader(http.StatusNotFound)
+		writeProblem(writer, request, http.StatusNotFound, codeConceptNotFound, "Concept not found")
 		return
 	}
 
 	writer.WriteHeader(http.StatusOK)
 }
 
+// GetAllIDs streams the concept IDs, one JSON object per line. A cursor line follows every page of IDs, so that an
+// interrupted stream can be resumed with the last cursor received, and a trailer line tells whether the stream is
+// complete.
 func (h *Handler) GetAllIDs(writer http.ResponseWriter, request *http.Request) {
 	transactionID := tid.GetTransactionIDFromRequest(request)
-	ctx := tid.TransactionAwareContext(context.Background(), transactionID)
+	ctx, cancel := context.WithCancel(tid.TransactionAwareContext(request.Context(), transactionID))
+	defer cancel()
+
+	query := request.URL.Query()
+	filter, err := h.conceptFilter(query)
+	if err != nil {
+		writeProblem(writer, request, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if filter.ModifiedFrom, err = queryTime(query, "modifiedSince"); err != nil {
+		writeProblem(writer, request, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if strings.ToLower(query.Get("excludeFTPinkAuthorities")) == "true" {
+		filter.ExcludeFTPinkAuthorities()
+	}
+
+	count, err := queryBool(query, "count")
+	if err != nil {
+		writeProblem(writer, request, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if count != nil && *count {
+		h.countIDs(ctx, writer, request, filter)
+		return
+	}
 
-	includeTypes := strings.ToLower(request.URL.Query().Get("includeTypes")) == "true"
-	excludeFTPinkAuthorities := strings.ToLower(request.URL.Query().Get("excludeFTPinkAuthorities")) == "true"
+	scan := service.IDScan{
+		IncludeTypes: strings.ToLower(query.Get("includeTypes")) == "true",
+		Filter:       filter,
+		Cursor:       query.Get("cursor"),
+	}
+	if value := query.Get("slices"); value != "" {
+		slices, err := strconv.Atoi(value)
+		if err != nil || slices < 1 {
+			writeProblem(writer, request, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("invalid slices value %q, expected a positive number", value))
+			return
+		}
+		scan.Slices = slices
+	}
+
+	pages, err := h.elasticService.GetAllIDs(ctx, scan)
+	if err != nil {
+		log.WithError(err).Error("Failed to scan the concept IDs")
+		writeError(writer, request, err)
+		return
+	}
 
 	writer.Header().Set("Content-Type", "text/plain")
 	writer.WriteHeader(http.StatusOK)
-	ids := h.elasticService.GetAllIDs(ctx, includeTypes, excludeFTPinkAuthorities)
-	i := 0
-	for id := range ids {
-		if includeTypes {
-			fmt.Fprintf(writer, "{\"uuid\":\"%s\",\"type\":\"%s\"}\n", id.ID, id.Type)
-		} else {
-			fmt.Fprintf(writer, "{\"uuid\":\"%s\"}\n", id.ID)
+	trailer := idsTrailer{Complete: true}
+	for page := range pages {
+		if page.Err != nil {
+			trailer = idsTrailer{Count: trailer.Count, Cursor: page.Cursor, Error: page.Err.Error()}
+			continue
+		}
+		for _, id := range page.IDs {
+			if scan.IncludeTypes {
+				fmt.Fprintf(writer, "{\"uuid\":\"%s\",\"type\":\"%s\"}\n", id.ID, id.Type)
+			} else {
+				fmt.Fprintf(writer, "{\"uuid\":\"%s\"}\n", id.ID)
+			}
+		}
+		trailer.Count += len(page.IDs)
+		if err := writeLine(writer, idsCursor{Cursor: page.Cursor}); err != nil {
+			log.WithError(err).Warn("Failed to write the concept IDs, the client may have disconnected")
+			return
+		}
+	}
+	if err := writeLine(writer, trailer); err != nil {
+		log.WithError(err).Warn("Failed to write the concept IDs trailer")
+	}
+	log.Infof("wrote %v uuids", trailer.Count)
+}
+
+// countIDs writes the number of concepts selected by the filter
+func (h *Handler) countIDs(ctx context.Context, writer http.ResponseWriter, request *http.Request, filter service.ConceptFilter) {
+	count, err := h.elasticService.CountConcepts(ctx, filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to count the concepts")
+		writeError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	if err := writeLine(writer, idsCount{Count: count}); err != nil {
+		log.WithError(err).Warn("Failed to write the concepts count")
+	}
+}
+
+type idsCount struct {
+	Count int64 `json:"count"`
+}
+
+type idsCursor struct {
+	Cursor string `json:"cursor"`
+}
+
+// idsTrailer is the last line of the concept IDs stream
+type idsTrailer struct {
+	Complete bool   `json:"complete"`
+	Count    int    `json:"count"`
+	Cursor   string `json:"cursor,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func writeLine(w http.ResponseWriter, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Export streams the concepts selected by the query parameters as newline delimited JSON documents, followed by a
+// trailer line telling whether the export is complete
+func (h *Handler) Export(writer http.ResponseWriter, request *http.Request) {
+	transactionID := tid.GetTransactionIDFromRequest(request)
+	ctx, cancel := context.WithCancel(tid.TransactionAwareContext(request.Context(), transactionID))
+	defer cancel()
+
+	filter, err := h.exportFilter(request.URL.Query())
+	if err != nil {
+		writeProblem(writer, request, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	docs, err := h.elasticService.ExportConcepts(ctx, filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to export the concepts")
+		writeError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.WriteHeader(http.StatusOK)
+	trailer := exportTrailer{Complete: true}
+	for doc := range docs {
+		if doc.Err != nil {
+			trailer = exportTrailer{Count: trailer.Count, Error: doc.Err.Error()}
+			continue
+		}
+		if _, err := writer.Write(append(doc.Source, '\n')); err != nil {
+			log.WithError(err).Warn("Failed to write the exported concepts, the client may have disconnected")
+			return
+		}
+		trailer.Count++
+	}
+	if err := writeLine(writer, trailer); err != nil {
+		log.WithError(err).Warn("Failed to write the export trailer")
+	}
+	log.Infof("exported %v concepts", trailer.Count)
+}
+
+// exportTrailer is the last line of an export, which tells an interrupted export from a complete one
+type exportTrailer struct {
+	Complete bool   `json:"complete"`
+	Count    int    `json:"count"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (h *Handler) exportFilter(query url.Values) (service.ExportFilter, error) {
+	conceptFilter, err := h.conceptFilter(query)
+	if err != nil {
+		return service.ExportFilter{}, err
+	}
+	filter := service.ExportFilter{ConceptFilter: conceptFilter, Fields: queryList(query, "fields")}
+
+	if filter.ModifiedFrom, err = queryTime(query, "lastModifiedFrom"); err != nil {
+		return filter, err
+	}
+	if filter.ModifiedTo, err = queryTime(query, "lastModifiedTo"); err != nil {
+		return filter, err
+	}
+	if !filter.ModifiedTo.IsZero() && filter.ModifiedFrom.After(filter.ModifiedTo) {
+		return filter, errors.New("lastModifiedFrom must not be after lastModifiedTo")
+	}
+	return filter, nil
+}
+
+// conceptFilter parses the query parameters selecting the concepts streamed by /__ids and /__export
+func (h *Handler) conceptFilter(query url.Values) (service.ConceptFilter, error) {
+	filter := service.ConceptFilter{
+		Types:               queryList(query, "type"),
+		Authorities:         queryList(query, "authority"),
+		ExcludedAuthorities: queryList(query, "excludeAuthority"),
+	}
+
+	for _, conceptType := range filter.Types {
+		if !h.conceptTypes.Readable(conceptType) {
+			return filter, fmt.Errorf("%w: %s", errUnsupportedConceptType, conceptType)
+		}
+	}
+
+	var err error
+	if filter.IsDeprecated, err = queryBool(query, "isDeprecated"); err != nil {
+		return filter, err
+	}
+	if filter.IsFTAuthor, err = queryBool(query, "isFTAuthor"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// queryList returns the values of a query parameter that can be repeated or comma separated
+func queryList(query url.Values, key string) []string {
+	var values []string
+	for _, value := range query[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
 		}
-		i++
 	}
-	log.Infof("wrote %v uuids", i)
+	return values
+}
+
+// queryBool returns nil if the query parameter is not set
+func queryBool(query url.Values, key string) (*bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q, expected true or false", key, value)
+	}
+	return &b, nil
+}
+
+func queryTime(query url.Values, key string) (time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s value %q, expected an RFC3339 date", key, value)
+	}
+	return t, nil
 }
 
-// Close terminates the underlying ES bulk processor
-func (h *Handler) Close() {
-	h.elasticService.CloseBulkProcessor()
+// Close flushes and terminates the underlying ES bulk processor, waiting at most until ctx is done
+func (h *Handler) Close(ctx context.Context) error {
+	return h.elasticService.CloseBulkProcessor(ctx)
 }
 
 type responseMessage struct {
 	Msg string `json:"message"`
 }
 
+// retryAfterSeconds returns the Retry-After header value of the wait, rounded up to the second
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
 func writeMessage(w http.ResponseWriter, msg string, status int) {
 	w.Header().Add("Content-Type", "application/json")
 	data, _ := json.Marshal(responseMessage{Msg: msg})
@@ -343,13 +742,18 @@ func writeMessage(w http.ResponseWriter, msg string, status int) {
 	w.Write(data)
 }
 
-func isAggregateConceptModel(body []byte) (bool, error) {
+// isAggregateConceptModel tells whether the payload is in the aggregate concept model, which memberships always are and
+// the other concepts are when they have source representations
+func isAggregateConceptModel(converter string, body []byte) (bool, error) {
 	data := make(map[string]interface{})
 	err := json.Unmarshal(body, &data)
 	if err != nil {
 		return false, err
 	}
+	if converter == service.MembershipConverter {
+		return true, nil
+	}
 
-	_, ok := data["prefUUID"]
+	_, ok := data["sourceRepresentations"]
 	return ok, nil
 }
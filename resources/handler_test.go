@@ -4,15 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/go-logger"
 	tid "github.com/Financial-Times/transactionid-utils-go"
 	"github.com/gorilla/mux"
-	"github.com/olivere/elastic/v7"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	testLog "github.com/sirupsen/logrus/hooks/test"
@@ -35,29 +36,32 @@ func TestCreateNewESWriter(t *testing.T) {
 	dummyEsService := &dummyEsService{}
 
 	allowedTypes := []string{"organisations", "genres"}
-	writerService, err := NewHandler(dummyEsService, allowedTypes, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList(allowedTypes, nil), publicAPIHost)
 	assert.NoError(t, err)
-	assert.True(t, writerService.allowedConceptTypes["organisations"])
-	assert.True(t, writerService.allowedConceptTypes["genres"])
-	assert.False(t, writerService.allowedConceptTypes["something else"])
+	assert.True(t, writerService.conceptTypes.Writable("organisations"))
+	assert.True(t, writerService.conceptTypes.Writable("genres"))
+	assert.False(t, writerService.conceptTypes.Writable("something else"))
 }
 
 func TestCreateNewESWriterWithEmptyWhitelist(t *testing.T) {
 	dummyEsService := &dummyEsService{}
 	var allowedTypes []string
-	writerService, err := NewHandler(dummyEsService, allowedTypes, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList(allowedTypes, nil), publicAPIHost)
 	assert.NoError(t, err)
-	assert.Equal(t, 0, len(writerService.allowedConceptTypes))
+	assert.Empty(t, writerService.conceptTypes.Names())
 }
 
 func TestLoadData(t *testing.T) {
 	testCases := []struct {
-		name    string
-		path    string
-		payload string
-		status  int
-		msg     string
-		noop    bool
+		name      string
+		path      string
+		payload   string
+		status    int
+		msg       string
+		noop      bool
+		unchanged bool
+		code      string
+		details   []service.FieldError
 	}{
 		{
 			name:    "Successful write",
@@ -73,6 +77,22 @@ func TestLoadData(t *testing.T) {
 			msg:     `{"message":"Concept written successfully"}`,
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
+		{
+			name:    "Successful write of a concept model with a prefUUID",
+			payload: `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
+			status:  http.StatusOK,
+			msg:     `{"message":"Concept written successfully"}`,
+			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
+		{
+			name:    "Aggregate model body without a prefUUID",
+			payload: `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"}]}`,
+			status:  http.StatusBadRequest,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/prefUUID", Message: "is required"}},
+			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
 		{
 			name:    "Successful aggregate membership write",
 			payload: `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Membership PrefLabel","type":"Membership","membershipRoles":[{"membershipRoleUUID":"9c21f096-0af5-4c16-a513-a64a1abe42fa"}],"organisationUUID":["cf73d36c-330b-48ae-a2aa-8cb3602855cc"],"personUUID":["efcd7388-49d7-4fa1-b8f3-baf59fbf28eb"],"sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Membership PrefLabel","type":"Membership","authority":"Smartlogic","authorityValue":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","membershipRoles":[{"membershipRoleUUID":"9c21f096-0af5-4c16-a513-a64a1abe42fa"}],"organisationUUID":"cf73d36c-330b-48ae-a2aa-8cb3602855cc","personUUID":"efcd7388-49d7-4fa1-b8f3-baf59fbf28eb"}]}`,
@@ -84,7 +104,9 @@ func TestLoadData(t *testing.T) {
 			name:    "Fail - Aggregate membership is to ambiguous to write",
 			payload: `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Membership PrefLabel","type":"Membership","membershipRoles":[{"membershipRoleUUID":"9c21f096-0af5-4c16-a513-a64a1abe42fa"}],"organisationUUID":["cf73d36c-330b-48ae-a2aa-8cb3602855cc","0c6f72df-ce99-4bd2-8336-e89d7ce2d1e6"],"personUUID":[],"sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Membership PrefLabel","type":"Membership","authority":"Smartlogic","authorityValue":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","membershipRoles":[{"membershipRoleUUID":"9c21f096-0af5-4c16-a513-a64a1abe42fa"}],"organisationUUID":"cf73d36c-330b-48ae-a2aa-8cb3602855cc","personUUID":"efcd7388-49d7-4fa1-b8f3-baf59fbf28eb"}]}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"ambiguous membership concept '8ff7dfef-0330-3de0-b37a-2d6aa9c98580', it has more than one HAS_MEMBER or HAS_ORGANISATION relationships"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/membership.json",
+			details: []service.FieldError{{Field: "/organisationUUID", Message: "maximum 1 items required, but found 2 items"}, {Field: "/personUUID", Message: "minimum 1 items required, but found 0 items"}},
 			path:    "/memberships/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
@@ -95,60 +117,107 @@ func TestLoadData(t *testing.T) {
 			noop:    true,
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
+		{
+			name:      "Model unchanged",
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
+			status:    http.StatusNotModified,
+			msg:       `{"message":"Concept unchanged"}`,
+			noop:      true,
+			unchanged: true,
+			path:      "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
+		{
+			name:      "Bulk model unchanged",
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
+			status:    http.StatusNotModified,
+			msg:       `{"message":"Concept unchanged"}`,
+			unchanged: true,
+			path:      "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
 		{
 			name:    "Path contains different uuid to body",
 			payload: `{"uuid":"different-uuid","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Provided path UUID does not match request body"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/uuid", Message: "'different-uuid' is not valid 'uuid'"}},
+			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
+		{
+			name:    "Path contains a different valid uuid to body",
+			payload: `{"uuid":"2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
+			status:  http.StatusBadRequest,
+			code:    codeUUIDMismatch,
+			msg:     "Provided path UUID does not match request body",
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Path contains unsupported concept type",
 			payload: `{"uuid":"different-uuid","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
 			status:  http.StatusNotFound,
-			msg:     `{"message":"Unsupported or invalid concept type"}`,
+			code:    codeUnsupportedConceptType,
+			msg:     "Unsupported or invalid concept type",
 			path:    "/invalid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Body contains empty type",
 			payload: `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report"}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Invalid or incomplete concept model"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/type", Message: "is required"}},
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Body contains empty prefLabel",
 			payload: `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"type":"Genre"}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Invalid or incomplete concept model"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/prefLabel", Message: "is required"}},
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Path contains different uuid to aggregate model body",
 			payload: `{"prefUUID":"different-uuid","prefLabel":"Smartlogics Brands PrefLabel","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Provided path UUID does not match request body"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/prefUUID", Message: "'different-uuid' is not valid 'uuid'"}, {Field: "/type", Message: "is required"}},
+			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
+		{
+			name:    "Path contains a different valid uuid to aggregate model body",
+			payload: `{"prefUUID":"2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
+			status:  http.StatusBadRequest,
+			code:    codeUUIDMismatch,
+			msg:     "Provided path UUID does not match request body",
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Aggregate model body contains empty type",
 			payload: `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Smartlogics Brands PrefLabel","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Invalid or incomplete concept model"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/type", Message: "is required"}},
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Aggregate model body contains empty prefLabel",
 			payload: `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","type":"Brands","strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url","sourceRepresentations":[{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","prefLabel":"TMEs PrefLabel","type":"Brand","authority":"TME","authorityValue":"745212"},{"uuid":"56388858-38d6-4dfc-a001-506394259b51","prefLabel":"Smartlogics Brands PrefLabel","type":"Brand","authority":"Smartlogic","authorityValue":"123456789","lastModifiedEpoch":1498127042,"strapline":"Some strapline","descriptionXML":"Some description","_imageUrl":"Some image url"}]}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Invalid or incomplete concept model"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/prefLabel", Message: "is required"}},
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Body contains invalid json",
 			payload: `{wrong data}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Request body is not in the expected concept model format"}`,
+			code:    codeInvalidPayload,
+			msg:     "Request body is not in the expected concept model format",
 			path:    "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
@@ -162,21 +231,33 @@ func TestLoadData(t *testing.T) {
 			name:    "Bulk request body contains invalid json",
 			payload: `{wrong data}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Request body is not in the expected concept model format"}`,
+			code:    codeInvalidPayload,
+			msg:     "Request body is not in the expected concept model format",
 			path:    "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Bulk request unsupported concept type",
 			payload: `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
 			status:  http.StatusNotFound,
-			msg:     `{"message":"Unsupported or invalid concept type"}`,
+			code:    codeUnsupportedConceptType,
+			msg:     "Unsupported or invalid concept type",
 			path:    "/bulk/invalid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Bulk path contains different uuid to body",
 			payload: `{"uuid":"different-uuid","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Provided path UUID does not match request body"}`,
+			code:    codeInvalidPayload,
+			msg:     "Concept payload does not match the schema v1/concept.json",
+			details: []service.FieldError{{Field: "/uuid", Message: "'different-uuid' is not valid 'uuid'"}},
+			path:    "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		},
+		{
+			name:    "Bulk path contains a different valid uuid to body",
+			payload: `{"uuid":"2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`,
+			status:  http.StatusBadRequest,
+			code:    codeUUIDMismatch,
+			msg:     "Provided path UUID does not match request body",
 			path:    "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
@@ -190,14 +271,16 @@ func TestLoadData(t *testing.T) {
 			name:    "Metrics are not written for invalid type",
 			payload: `{"metrics":{"annotationsCount": 796, "prevWeekAnnotationsCount": 79}`,
 			status:  http.StatusNotFound,
-			msg:     `{"message":"Unsupported or invalid concept type"}`,
+			code:    codeUnsupportedConceptType,
+			msg:     "Unsupported or invalid concept type",
 			path:    "/metrics/invalid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 		{
 			name:    "Metrics are only written if they are supplied correctly",
 			payload: `{"somethingDodgy":{"annotationsCount": 796, "prevWeekAnnotationsCount": 79}}`,
 			status:  http.StatusBadRequest,
-			msg:     `{"message":"Please supply metrics as a JSON object with a single property 'metrics'"}`,
+			code:    codeInvalidPayload,
+			msg:     "Please supply metrics as a JSON object with a single property 'metrics'",
 			path:    "/metrics/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
 		},
 	}
@@ -210,7 +293,10 @@ func TestLoadData(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			dummyEsService := &dummyEsService{noop: tc.noop}
-			writerService, err := NewHandler(dummyEsService, []string{"valid-type", "memberships"}, publicAPIHost)
+			if tc.unchanged {
+				dummyEsService.result = service.UnchangedResult
+			}
+			writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"valid-type", "memberships"}, nil), publicAPIHost)
 			assert.NoError(t, err)
 
 			servicesRouter := mux.NewRouter()
@@ -219,6 +305,11 @@ func TestLoadData(t *testing.T) {
 			servicesRouter.HandleFunc("/metrics/{concept-type}/{id}", writerService.LoadMetrics).Methods("PUT")
 			servicesRouter.ServeHTTP(rr, req)
 
+			if tc.code != "" {
+				actual := assertProblem(t, rr, tc.status, tc.code, tc.msg)
+				assert.Equal(t, tc.details, actual.Details)
+				return
+			}
 			assert.Equal(t, tc.status, rr.Code, `Current test "%v"`, tc.name)
 			assert.JSONEq(t, tc.msg, rr.Body.String(), `Current test "%v"`, tc.name)
 		})
@@ -227,19 +318,53 @@ func TestLoadData(t *testing.T) {
 
 func TestLoadDataEsClientServerErrors(t *testing.T) {
 	testCases := []struct {
-		err    error
-		status int
-		msg    string
+		err        error
+		status     int
+		code       string
+		msg        string
+		retryAfter string
 	}{
 		{
 			err:    errTest,
 			status: http.StatusInternalServerError,
-			msg:    `{"message":"Failed to write data to ES"}`,
+			code:   codeInternalError,
+		},
+		{
+			err:        &service.CircuitOpenError{RetryAfter: 1500 * time.Millisecond},
+			status:     http.StatusServiceUnavailable,
+			code:       codeESOverloaded,
+			msg:        "ES overloaded",
+			retryAfter: "2",
+		},
+		{
+			err:    fmt.Errorf("writing concept: %w", context.DeadlineExceeded),
+			status: http.StatusGatewayTimeout,
+			code:   codeESTimeout,
+			msg:    "ES request timed out",
+		},
+		{
+			err:    fmt.Errorf("writing concept: %w", context.Canceled),
+			status: statusClientClosedRequest,
+			code:   codeRequestCancelled,
+			msg:    "The request was cancelled",
 		},
 		{
 			err:    service.ErrNoElasticClient,
 			status: http.StatusServiceUnavailable,
-			msg:    `{"message":"ES unavailable"}`,
+			code:   codeESUnavailable,
+			msg:    "ES unavailable",
+		},
+		{
+			err:    service.ErrConceptDeleted,
+			status: http.StatusConflict,
+			code:   codeConceptDeleted,
+			msg:    "Concept was deleted after it was last modified",
+		},
+		{
+			err:    service.ErrPreconditionFailed,
+			status: http.StatusPreconditionFailed,
+			code:   codePreconditionFailed,
+			msg:    "Concept does not match the If-Match header",
 		},
 	}
 
@@ -251,15 +376,15 @@ func TestLoadDataEsClientServerErrors(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			dummyEsService := &dummyEsService{returnsError: tc.err}
-			writerService, err := NewHandler(dummyEsService, []string{"valid-type"}, publicAPIHost)
+			writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
 			assert.NoError(t, err)
 
 			servicesRouter := mux.NewRouter()
 			servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.LoadData).Methods("PUT")
 			servicesRouter.ServeHTTP(rr, req)
 
-			assert.Equal(t, tc.status, rr.Code)
-			assert.JSONEq(t, tc.msg, rr.Body.String())
+			assertProblem(t, rr, tc.status, tc.code, tc.msg)
+			assert.Equal(t, tc.retryAfter, rr.Header().Get("Retry-After"))
 		})
 	}
 }
@@ -285,7 +410,7 @@ func TestReadData(t *testing.T) {
 
 	rawmsg := json.RawMessage(rawModel)
 	dummyEsService := &dummyEsService{found: true, source: rawmsg}
-	writerService, err := NewHandler(dummyEsService, []string{"genres"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
@@ -310,6 +435,186 @@ func TestReadData(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(respObject, esModel))
 }
 
+func TestReadDataConditional(t *testing.T) {
+	source := json.RawMessage(`{"id":"http://api.ft.com/things/8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","lastModified":"2024-05-01T08:00:00Z","indexedAt":"2024-06-01T10:00:00.5Z"}`)
+	etag := (&service.GetResult{Found: true, Source: source}).ETag()
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{
+			name:   "Unconditional",
+			status: http.StatusOK,
+		},
+		{
+			name:    "Matching If-None-Match",
+			headers: map[string]string{"If-None-Match": `"stale", ` + etag},
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "Weak If-None-Match",
+			headers: map[string]string{"If-None-Match": "W/" + etag},
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "Any If-None-Match",
+			headers: map[string]string{"If-None-Match": "*"},
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "Stale If-None-Match",
+			headers: map[string]string{"If-None-Match": `"stale"`},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "Unmodified since",
+			headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 10:00:00 GMT"},
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "Modified since",
+			headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 09:59:59 GMT"},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "If-None-Match over If-Modified-Since",
+			headers: map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": "Sat, 01 Jun 2024 10:00:00 GMT"},
+			status:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			writerService, err := NewHandler(&dummyEsService{found: true, source: source}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+			require.NoError(t, err)
+
+			servicesRouter := mux.NewRouter()
+			servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+			servicesRouter.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			assert.Equal(t, "Sat, 01 Jun 2024 10:00:00 GMT", rr.Header().Get("Last-Modified"))
+			if tc.status == http.StatusNotModified {
+				assert.Empty(t, rr.Body.String())
+			} else {
+				assert.Contains(t, rr.Body.String(), "Market Report")
+			}
+		})
+	}
+}
+
+func TestReadDataConditionalWithMetrics(t *testing.T) {
+	source := json.RawMessage(`{"id":"http://api.ft.com/things/8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","lastModified":"2024-06-01T10:00:00Z","indexedAt":"2024-06-03T12:00:00Z","metrics":{"annotationsCount":2,"prevWeekAnnotationsCount":1}}`)
+	etag := (&service.GetResult{Found: true, Source: source}).ETag()
+
+	writerService, err := NewHandler(&dummyEsService{found: true, source: source}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+
+	req := httptest.NewRequest(http.MethodGet, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+	req.Header.Set("If-Modified-Since", "Sat, 01 Jun 2024 10:00:00 GMT")
+	rr := httptest.NewRecorder()
+	servicesRouter.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "the metrics were patched since the concept was last modified")
+	assert.Equal(t, "Mon, 03 Jun 2024 12:00:00 GMT", rr.Header().Get("Last-Modified"), "the Last-Modified should be the time the metrics were patched")
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	req = httptest.NewRequest(http.MethodGet, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+	req.Header.Set("If-Modified-Since", "Mon, 03 Jun 2024 12:00:00 GMT")
+	rr = httptest.NewRecorder()
+	servicesRouter.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	servicesRouter.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestLoadDataIfMatch(t *testing.T) {
+	esService := service.NewMemoryService("concepts")
+	writerService, err := NewHandler(esService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.LoadData).Methods("PUT")
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+
+	write := func(prefLabel string, ifMatch string) *httptest.ResponseRecorder {
+		payload := `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"` + prefLabel + `","type":"Genre"}`
+		req := httptest.NewRequest(http.MethodPut, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(payload)))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		servicesRouter.ServeHTTP(rr, req)
+		return rr
+	}
+	read := func() string {
+		rr := httptest.NewRecorder()
+		servicesRouter.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header().Get("ETag")
+	}
+
+	assertProblem(t, write("Market Report", "*"), http.StatusPreconditionFailed, codePreconditionFailed, "Concept does not match the If-Match header")
+	assert.Equal(t, http.StatusOK, write("Market Report", "").Code)
+
+	etag := read()
+	assert.Equal(t, http.StatusOK, write("Market Reports", etag).Code)
+	assertProblem(t, write("Market Report", etag), http.StatusPreconditionFailed, codePreconditionFailed, "Concept does not match the If-Match header")
+	assert.Equal(t, http.StatusOK, write("Market Report", read()).Code)
+}
+
+func TestLoadBulkDataIfMatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+	req.Header.Set("If-Match", "*")
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusBadRequest, codeInvalidRequest, "If-Match is not supported by bulk writes, which are not written synchronously")
+}
+
+func TestLoadDataRecreate(t *testing.T) {
+	writerService, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.LoadData).Methods("PUT")
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+
+	write := func(path string) *httptest.ResponseRecorder {
+		payload := `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`
+		rr := httptest.NewRecorder()
+		servicesRouter.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, path, bytes.NewReader([]byte(payload))))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, write("/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580?recreate=true").Code)
+	assertProblem(t, write("/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580?recreate=maybe"), http.StatusBadRequest, codeInvalidRequest, `invalid recreate value "maybe", expected true or false`)
+	assertProblem(t, write("/bulk/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580?recreate=true"), http.StatusBadRequest, codeInvalidRequest, "recreate is not supported by bulk writes, which are not written synchronously")
+}
+
 func TestReadDataInvalidConceptType(t *testing.T) {
 	req, err := http.NewRequest("GET", "/InvalidConceptType/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
 	if err != nil {
@@ -331,22 +636,37 @@ func TestReadDataInvalidConceptType(t *testing.T) {
 
 	rawmsg := json.RawMessage(rawModel)
 	dummyEsService := &dummyEsService{found: true, source: rawmsg}
-	writerService, err := NewHandler(dummyEsService, []string{"genres"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
 	servicesRouter.ServeHTTP(rr, req)
 
-	var respObject responseMessage
-	err = json.Unmarshal(rr.Body.Bytes(), &respObject)
-	if err != nil {
-		t.Errorf("Unmarshalling request response failed. %v", err)
-	}
-	expectedResponse := responseMessage{Msg: "Unsupported or invalid concept type"}
+	assertProblem(t, rr, http.StatusNotFound, codeUnsupportedConceptType, "Unsupported or invalid concept type")
+}
+
+func TestProblemTransactionID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/animals/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+	req.Header.Set(tid.TransactionIDHeader, "tid_test")
+	rr := httptest.NewRecorder()
 
-	assert.Equal(t, respObject, expectedResponse)
-	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	writerService, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"code": "unsupported-concept-type",
+		"detail": "Unsupported or invalid concept type",
+		"instance": "/animals/8ff7dfef-0330-3de0-b37a-2d6aa9c98580",
+		"transactionId": "tid_test"
+	}`, rr.Body.String())
 }
 
 func TestReadDataNotFound(t *testing.T) {
@@ -358,20 +678,220 @@ func TestReadDataNotFound(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{found: false}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
 	servicesRouter.ServeHTTP(rr, req)
 
-	// Check the status code is what we expect.
-	if status := rr.Code; status != http.StatusNotFound {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusNotFound)
+	actual := assertProblem(t, rr, http.StatusNotFound, codeConceptNotFound, "Concept not found")
+	assert.Equal(t, "/organisations/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", actual.Instance)
+}
+
+func TestLoadBulkDataOfDeletedConcept(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{returnsError: service.ErrConceptDeleted}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusConflict, codeConceptDeleted, "Concept was deleted after it was last modified")
+}
+
+func TestLoadBulkDataBackpressure(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{returnsError: &service.BackpressureError{RetryAfter: 10 * time.Second}}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusTooManyRequests, codeTooManyRequests, "Too many concepts are queued for ES")
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+}
+
+func TestLoadDataDryRun(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		action  string
+		handler func(h *Handler) http.HandlerFunc
+	}{
+		{name: "write", path: "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", action: service.IndexAction, handler: func(h *Handler) http.HandlerFunc { return h.LoadData }},
+		{name: "bulk", path: "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", action: service.QueueAction, handler: func(h *Handler) http.HandlerFunc { return h.LoadBulkData }},
 	}
 
-	assert.Nil(t, rr.Body.Bytes(), "Response body should be empty")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tc.path+"?dryRun=true", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+			rr := httptest.NewRecorder()
+
+			// any write fails, so that the dry run is known not to write
+			writerService, err := NewHandler(&dummyEsService{returnsError: errTest}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+			require.NoError(t, err)
+
+			servicesRouter := mux.NewRouter()
+			servicesRouter.HandleFunc("/{concept-type}/{id}", tc.handler(writerService)).Methods("PUT")
+			servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", tc.handler(writerService)).Methods("PUT")
+			servicesRouter.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			var response struct {
+				ConceptType string                  `json:"conceptType"`
+				UUID        string                  `json:"uuid"`
+				Document    service.EsConceptModel  `json:"document"`
+				Actions     []service.PlannedAction `json:"actions"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, "valid-type", response.ConceptType)
+			assert.Equal(t, "8ff7dfef-0330-3de0-b37a-2d6aa9c98580", response.UUID)
+			assert.Equal(t, "Market Report", response.Document.PrefLabel)
+			assert.Equal(t, "http://www.ft.com/ontology/Genre", response.Document.DirectType)
+			assert.Equal(t, []service.PlannedAction{{Action: tc.action, ConceptType: "valid-type", UUID: "8ff7dfef-0330-3de0-b37a-2d6aa9c98580"}}, response.Actions)
+		})
+	}
+}
+
+func TestLoadDataInvalidDryRun(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580?dryRun=maybe", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.LoadData).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusBadRequest, codeInvalidRequest, `invalid dryRun value "maybe", expected true or false`)
+}
+
+func TestLoadBulkDataWithoutElasticClient(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/bulk/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`)))
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{returnsError: service.ErrNoElasticClient}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusServiceUnavailable, codeESUnavailable, "ES unavailable")
+}
+
+func TestLoadMetricsWithoutElasticClient(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/valid-type/8ff7dfef-0330-3de0-b37a-2d6aa9c98580/metrics", bytes.NewReader([]byte(`{"metrics":{"annotationsCount":10}}`)))
+	rr := httptest.NewRecorder()
+
+	writerService, err := NewHandler(&dummyEsService{returnsError: service.ErrNoElasticClient}, service.ConceptTypesFromList([]string{"valid-type"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}/metrics", writerService.LoadMetrics).Methods("PUT")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assertProblem(t, rr, http.StatusServiceUnavailable, codeESUnavailable, "ES unavailable")
+}
+
+func TestReadDataDeleted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/organisations/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", nil)
+	rr := httptest.NewRecorder()
+
+	tombstone := &service.Tombstone{ID: "8ff7dfef-0330-3de0-b37a-2d6aa9c98580", ConceptType: "organisations", DeletedAt: "2024-01-01T00:00:00Z", TransactionID: "tid_test", Reason: service.TombstoneReasonDeleted}
+	writerService, err := NewHandler(&dummyEsService{tombstone: tombstone}, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+	servicesRouter.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.JSONEq(t, `{"id":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","conceptType":"organisations","deletedAt":"2024-01-01T00:00:00Z","transactionId":"tid_test","reason":"deleted"}`, rr.Body.String())
+}
+
+func TestHistory(t *testing.T) {
+	history := []service.ChangeRecord{
+		{ConceptID: "8ff7dfef-0330-3de0-b37a-2d6aa9c98580", ConceptType: "organisations", Operation: "write", Timestamp: "2024-01-02T00:00:00Z", TransactionID: "tid_test",
+			Changes: map[string]service.FieldChange{"prefLabel": {Before: "Old label", After: "New label"}}},
+	}
+
+	testCases := []struct {
+		name           string
+		service        *dummyEsService
+		conceptType    string
+		expectedStatus int
+		expectedCode   string
+		expectedBody   string
+	}{
+		{
+			name:           "History",
+			service:        &dummyEsService{history: history},
+			conceptType:    "organisations",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"conceptId":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","conceptType":"organisations","operation":"write","timestamp":"2024-01-02T00:00:00Z","transactionId":"tid_test","changes":{"prefLabel":{"before":"Old label","after":"New label"}}}]`,
+		},
+		{
+			name:           "No history",
+			service:        &dummyEsService{history: []service.ChangeRecord{}},
+			conceptType:    "organisations",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+		},
+		{
+			name:           "History disabled",
+			service:        &dummyEsService{returnsError: service.ErrHistoryDisabled},
+			conceptType:    "organisations",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   codeHistoryDisabled,
+			expectedBody:   service.ErrHistoryDisabled.Error(),
+		},
+		{
+			name:           "No elastic client",
+			service:        &dummyEsService{returnsError: service.ErrNoElasticClient},
+			conceptType:    "organisations",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   codeESUnavailable,
+			expectedBody:   "ES unavailable",
+		},
+		{
+			name:           "Unsupported concept type",
+			service:        &dummyEsService{history: history},
+			conceptType:    "animals",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   codeUnsupportedConceptType,
+			expectedBody:   errUnsupportedConceptType.Error(),
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+test.conceptType+"/8ff7dfef-0330-3de0-b37a-2d6aa9c98580/history", nil)
+			rr := httptest.NewRecorder()
+
+			writerService, err := NewHandler(test.service, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
+			require.NoError(t, err)
+
+			servicesRouter := mux.NewRouter()
+			servicesRouter.HandleFunc("/{concept-type}/{id}/history", writerService.History).Methods("GET")
+			servicesRouter.ServeHTTP(rr, req)
+
+			if test.expectedCode != "" {
+				assertProblem(t, rr, test.expectedStatus, test.expectedCode, test.expectedBody)
+				return
+			}
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			assert.JSONEq(t, test.expectedBody, rr.Body.String())
+		})
+	}
 }
 
 func TestReadDataEsServerError(t *testing.T) {
@@ -383,20 +903,14 @@ func TestReadDataEsServerError(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{returnsError: errTest}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
 	servicesRouter.ServeHTTP(rr, req)
 
-	// Check the status code is what we expect.
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusInternalServerError)
-	}
-
-	assert.Nil(t, rr.Body.Bytes(), "Response body should be empty")
+	assertProblem(t, rr, http.StatusInternalServerError, codeInternalError, "")
 }
 
 func TestReadDataEsServerUnavailable(t *testing.T) {
@@ -408,14 +922,14 @@ func TestReadDataEsServerUnavailable(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{returnsError: service.ErrNoElasticClient}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
 	servicesRouter.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "HTTP status")
+	assertProblem(t, rr, http.StatusServiceUnavailable, codeESUnavailable, "ES unavailable")
 }
 
 func TestDeleteData(t *testing.T) {
@@ -427,7 +941,7 @@ func TestDeleteData(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{found: true}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
@@ -452,22 +966,56 @@ func TestDeleteDataInvalidConceptType(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{found: true}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.DeleteData).Methods("DELETE")
 	servicesRouter.ServeHTTP(rr, req)
 
-	var respObject responseMessage
-	err = json.Unmarshal(rr.Body.Bytes(), &respObject)
-	if err != nil {
-		t.Errorf("Unmarshalling request response failed. %v", err)
+	assertProblem(t, rr, http.StatusNotFound, codeUnsupportedConceptType, "Unsupported or invalid concept type")
+}
+
+func TestReadOnlyConceptType(t *testing.T) {
+	conceptTypes, err := service.NewConceptTypes(map[string]service.ConceptTypeConfig{
+		"genres": {Read: true},
+	})
+	require.NoError(t, err)
+
+	dummyEsService := &dummyEsService{found: true, source: json.RawMessage(`{"prefLabel":"Market Report"}`)}
+	writerService, err := NewHandler(dummyEsService, conceptTypes, publicAPIHost)
+	require.NoError(t, err)
+
+	servicesRouter := mux.NewRouter()
+	servicesRouter.HandleFunc("/bulk/{concept-type}/{id}", writerService.LoadBulkData).Methods("PUT")
+	servicesRouter.HandleFunc("/{concept-type}/{id}/metrics", writerService.LoadMetrics).Methods("PUT")
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.LoadData).Methods("PUT")
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.ReadData).Methods("GET")
+	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.DeleteData).Methods("DELETE")
+
+	testCases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: "PUT", path: "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", status: http.StatusMethodNotAllowed},
+		{method: "PUT", path: "/bulk/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", status: http.StatusMethodNotAllowed},
+		{method: "PUT", path: "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580/metrics", status: http.StatusMethodNotAllowed},
+		{method: "DELETE", path: "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", status: http.StatusMethodNotAllowed},
+		{method: "GET", path: "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", status: http.StatusOK},
 	}
-	expectedResponse := responseMessage{Msg: "Unsupported or invalid concept type"}
 
-	assert.Equal(t, respObject, expectedResponse)
-	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(`{}`)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			servicesRouter.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+		})
+	}
 }
 
 func TestDeleteDataNotFound(t *testing.T) {
@@ -479,20 +1027,15 @@ func TestDeleteDataNotFound(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{result: "not_found"}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.DeleteData).Methods("DELETE")
 	servicesRouter.ServeHTTP(rr, req)
 
-	// Check the status code is what we expect.
-	if status := rr.Code; status != http.StatusNotFound {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusNotFound)
-	}
-
-	assert.Nil(t, rr.Body.Bytes(), "Response body should be empty")
+	actual := assertProblem(t, rr, http.StatusNotFound, codeConceptNotFound, "Concept not found")
+	assert.Equal(t, "/organisations/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", actual.Instance)
 }
 
 func TestDeleteDataEsServerError(t *testing.T) {
@@ -504,20 +1047,70 @@ func TestDeleteDataEsServerError(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	dummyEsService := &dummyEsService{returnsError: errTest}
-	writerService, err := NewHandler(dummyEsService, []string{"organisations"}, publicAPIHost)
+	writerService, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"organisations"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	servicesRouter := mux.NewRouter()
 	servicesRouter.HandleFunc("/{concept-type}/{id}", writerService.DeleteData).Methods("DELETE")
 	servicesRouter.ServeHTTP(rr, req)
 
-	// Check the status code is what we expect.
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusInternalServerError)
+	assertProblem(t, rr, http.StatusInternalServerError, codeInternalError, "")
+}
+
+func TestUpstreamLastModified(t *testing.T) {
+	testCases := []struct {
+		name         string
+		header       string
+		body         string
+		lastModified string
+		err          error
+	}{
+		{
+			name:         "Latest source representation epoch",
+			body:         `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Brand","type":"Brand","sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","authority":"Smartlogic","lastModifiedEpoch":1498127042},{"uuid":"4ebbd9c4-3bb7-4d18-a14c-4c45aac5d966","authority":"TME","lastModifiedEpoch":1498127000}]}`,
+			lastModified: "2017-06-22T10:24:02Z",
+		},
+		{
+			name:         "Header overrides the payload",
+			header:       "Wed, 21 Oct 2015 07:28:00 GMT",
+			body:         `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Brand","type":"Brand","sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","authority":"Smartlogic","lastModifiedEpoch":1498127042}]}`,
+			lastModified: "2015-10-21T07:28:00Z",
+		},
+		{
+			name:         "RFC3339 header",
+			header:       "2015-10-21T07:28:00Z",
+			body:         `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`,
+			lastModified: "2015-10-21T07:28:00Z",
+		},
+		{
+			name:   "Invalid header",
+			header: "yesterday",
+			body:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`,
+			err:    errInvalidLastModified,
+		},
 	}
 
-	assert.Nil(t, rr.Body.Bytes(), "Response body should be empty")
+	handler, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/genres/8ff7dfef-0330-3de0-b37a-2d6aa9c98580", bytes.NewReader([]byte(tc.body)))
+			req = mux.SetURLVars(req, map[string]string{"concept-type": "genres", "id": "8ff7dfef-0330-3de0-b37a-2d6aa9c98580"})
+			if tc.header != "" {
+				req.Header.Set("Last-Modified", tc.header)
+			}
+
+			_, _, esModel, err := handler.processPayload(req)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.lastModified, esModel.(*service.EsConceptModel).LastModified)
+			assert.NotEmpty(t, esModel.(*service.EsConceptModel).IndexedAt)
+		})
+	}
 }
 
 func TestProcessConceptModelWithoutTransactionID(t *testing.T) {
@@ -525,7 +1118,7 @@ func TestProcessConceptModelWithoutTransactionID(t *testing.T) {
 	testUUID := "8ff7dfef-0330-3de0-b37a-2d6aa9c98580"
 	testBody := []byte(`{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]},"prefLabel":"Market Report","type":"Genre"}`)
 
-	_, payload, err := processConceptModel(context.Background(), testUUID, "genres", publicAPIHost, testBody)
+	_, payload, err := processConceptModel(context.Background(), testUUID, "genres", service.ConceptConverter, publicAPIHost, testBody, time.Time{})
 	assert.NoError(t, err)
 	assert.NotNil(t, payload)
 	assert.NotEmpty(t, payload.(*service.EsConceptModel).PublishReference)
@@ -536,67 +1129,223 @@ func TestProcessConceptModelWithoutTransactionID(t *testing.T) {
 }
 
 func TestIDsEndpointReturnsIDsWithInvalidIncludeTypesValue(t *testing.T) {
-	ids := make(chan service.EsIDTypePair, 4)
-	dummyEsService := &dummyEsService{ids: ids}
+	dummyEsService := &dummyEsService{pages: []service.IDScanPage{{IDs: []service.EsIDTypePair{{ID: "1", Type: "people"}}, Cursor: "cursor-1"}}}
 
-	h, err := NewHandler(dummyEsService, []string{"genres"}, publicAPIHost)
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/?includeTypes=somethingDodgy", nil)
 
-	go func() {
-		ids <- service.EsIDTypePair{ID: "1", Type: "people"}
-		close(ids)
-	}()
-
 	h.GetAllIDs(w, req)
 
-	for {
-		line, err := w.Body.ReadString('\n')
-		if err != nil {
-			break
-		}
-
-		j := make(map[string]string)
-		err = json.Unmarshal([]byte(line), &j)
-		require.NoError(t, err)
-		assert.Equal(t, "1", j["uuid"])
-
-		_, ok := j["type"]
-		assert.False(t, ok)
-	}
+	lines := readLines(t, w)
+	require.Len(t, lines, 3)
+	assert.Equal(t, map[string]interface{}{"uuid": "1"}, lines[0])
+	assert.Equal(t, map[string]interface{}{"cursor": "cursor-1"}, lines[1])
+	assert.Equal(t, map[string]interface{}{"complete": true, "count": float64(1)}, lines[2])
+	assert.False(t, dummyEsService.scan.IncludeTypes)
 }
 
 func TestIDsEndpointReturnsTypes(t *testing.T) {
-	ids := make(chan service.EsIDTypePair, 4)
-	dummyEsService := &dummyEsService{ids: ids}
+	dummyEsService := &dummyEsService{pages: []service.IDScanPage{
+		{IDs: []service.EsIDTypePair{{ID: "1", Type: "people"}}, Cursor: "cursor-1"},
+		{IDs: []service.EsIDTypePair{{ID: "2", Type: "genres"}}, Cursor: "cursor-2"},
+	}}
 
-	h, err := NewHandler(dummyEsService, []string{"genres"}, publicAPIHost)
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
 	assert.NoError(t, err)
 
 	w := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", "/?includeTypes=true", nil)
+	req := httptest.NewRequest("GET", "/?includeTypes=true&slices=4&cursor=cursor-0", nil)
+
+	h.GetAllIDs(w, req)
+
+	lines := readLines(t, w)
+	require.Len(t, lines, 5)
+	assert.Equal(t, map[string]interface{}{"uuid": "1", "type": "people"}, lines[0])
+	assert.Equal(t, map[string]interface{}{"uuid": "2", "type": "genres"}, lines[2])
+	assert.Equal(t, map[string]interface{}{"complete": true, "count": float64(2)}, lines[4])
+	assert.Equal(t, service.IDScan{IncludeTypes: true, Slices: 4, Cursor: "cursor-0"}, dummyEsService.scan)
+}
 
-	go func() {
-		ids <- service.EsIDTypePair{ID: "1", Type: "people"}
-		close(ids)
-	}()
+func TestIDsEndpointFilters(t *testing.T) {
+	dummyEsService := &dummyEsService{}
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"people", "organisations"}, nil), publicAPIHost)
+	require.NoError(t, err)
 
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/__ids?type=people,organisations&authority=Wikidata&excludeAuthority=FACTSET&excludeFTPinkAuthorities=true&modifiedSince=2024-01-01T00:00:00Z&isDeprecated=false&isFTAuthor=true", nil)
 	h.GetAllIDs(w, req)
 
+	assert.Equal(t, http.StatusOK, w.Code)
+	isDeprecated, isFTAuthor := false, true
+	assert.Equal(t, service.ConceptFilter{
+		Types:               []string{"people", "organisations"},
+		Authorities:         []string{"Wikidata"},
+		ExcludedAuthorities: []string{"FACTSET", "TME", "Smartlogic"},
+		ModifiedFrom:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsDeprecated:        &isDeprecated,
+		IsFTAuthor:          &isFTAuthor,
+	}, dummyEsService.scan.Filter)
+}
+
+func TestIDsEndpointCount(t *testing.T) {
+	dummyEsService := &dummyEsService{count: 42}
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"people"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.GetAllIDs(w, httptest.NewRequest("GET", "/__ids?count=true&type=people", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"count":42}`, w.Body.String())
+	assert.Equal(t, []string{"people"}, dummyEsService.countFilter.Types)
+	assert.Empty(t, dummyEsService.scan, "the IDs should not be scanned")
+}
+
+func TestIDsEndpointReportsIncompleteScans(t *testing.T) {
+	dummyEsService := &dummyEsService{pages: []service.IDScanPage{
+		{IDs: []service.EsIDTypePair{{ID: "1"}}, Cursor: "cursor-1"},
+		{Cursor: "cursor-1", Err: errTest},
+	}}
+
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.GetAllIDs(w, httptest.NewRequest("GET", "/__ids", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := readLines(t, w)
+	require.Len(t, lines, 3)
+	assert.Equal(t, map[string]interface{}{"complete": false, "count": float64(1), "cursor": "cursor-1", "error": errTest.Error()}, lines[2])
+}
+
+func TestIDsEndpointErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		err      error
+		expected int
+	}{
+		{name: "Invalid slices", query: "slices=none", expected: http.StatusBadRequest},
+		{name: "Unsupported concept type", query: "type=topics", expected: http.StatusBadRequest},
+		{name: "Invalid modifiedSince", query: "modifiedSince=yesterday", expected: http.StatusBadRequest},
+		{name: "Invalid isDeprecated", query: "isDeprecated=maybe", expected: http.StatusBadRequest},
+		{name: "Invalid isFTAuthor", query: "isFTAuthor=maybe", expected: http.StatusBadRequest},
+		{name: "Invalid count", query: "count=maybe", expected: http.StatusBadRequest},
+		{name: "Count without elastic client", query: "count=true", err: service.ErrNoElasticClient, expected: http.StatusServiceUnavailable},
+		{name: "No slices", query: "slices=0", expected: http.StatusBadRequest},
+		{name: "Invalid scan", err: fmt.Errorf("%w: too many slices", service.ErrInvalidIDScan), expected: http.StatusBadRequest},
+		{name: "Invalid cursor", err: fmt.Errorf("%w: not base64", service.ErrInvalidCursor), expected: http.StatusBadRequest},
+		{name: "Expired cursor", err: service.ErrCursorExpired, expected: http.StatusGone},
+		{name: "No elastic client", err: service.ErrNoElasticClient, expected: http.StatusServiceUnavailable},
+		{name: "Elastic error", err: errTest, expected: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := NewHandler(&dummyEsService{returnsError: tc.err}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			h.GetAllIDs(w, httptest.NewRequest("GET", "/__ids?"+tc.query, nil))
+
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
+
+func readLines(t *testing.T, w *httptest.ResponseRecorder) []map[string]interface{} {
+	var lines []map[string]interface{}
 	for {
 		line, err := w.Body.ReadString('\n')
 		if err != nil {
-			break
+			return lines
 		}
+		j := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(line), &j))
+		lines = append(lines, j)
+	}
+}
+
+func TestExportStreamsConcepts(t *testing.T) {
+	dummyEsService := &dummyEsService{exported: []json.RawMessage{
+		json.RawMessage(`{"id":"http://api.ft.com/things/1","prefLabel":"Lex"}`),
+		json.RawMessage(`{"id":"http://api.ft.com/things/2","prefLabel":"Markets"}`),
+	}}
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres", "sections"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/__export?type=genres,sections&authority=TME&authority=Smartlogic&lastModifiedFrom=2024-01-01T00:00:00Z&isDeprecated=false&fields=prefLabel", nil)
+	h.Export(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"id\":\"http://api.ft.com/things/1\",\"prefLabel\":\"Lex\"}\n{\"id\":\"http://api.ft.com/things/2\",\"prefLabel\":\"Markets\"}\n{\"complete\":true,\"count\":2}\n", w.Body.String())
+
+	filter := dummyEsService.exportFilter
+	assert.Equal(t, []string{"genres", "sections"}, filter.Types)
+	assert.Equal(t, []string{"TME", "Smartlogic"}, filter.Authorities)
+	assert.Equal(t, []string{"prefLabel"}, filter.Fields)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), filter.ModifiedFrom)
+	assert.True(t, filter.ModifiedTo.IsZero())
+	require.NotNil(t, filter.IsDeprecated)
+	assert.False(t, *filter.IsDeprecated)
+}
 
-		j := make(map[string]string)
-		err = json.Unmarshal([]byte(line), &j)
-		require.NoError(t, err)
-		assert.Equal(t, "1", j["uuid"])
-		assert.Equal(t, "people", j["type"])
+func TestExportInterrupted(t *testing.T) {
+	dummyEsService := &dummyEsService{
+		exported:  []json.RawMessage{json.RawMessage(`{"id":"http://api.ft.com/things/1","prefLabel":"Lex"}`)},
+		exportErr: errTest,
 	}
+	h, err := NewHandler(dummyEsService, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.Export(w, httptest.NewRequest("GET", "/__export", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"id\":\"http://api.ft.com/things/1\",\"prefLabel\":\"Lex\"}\n{\"complete\":false,\"count\":1,\"error\":\"test error\"}\n", w.Body.String(),
+		"an interrupted export ends with the error rather than looking complete")
+}
+
+func TestExportInvalidParameters(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{name: "Unsupported concept type", query: "type=genres,topics"},
+		{name: "Invalid lastModifiedFrom", query: "lastModifiedFrom=yesterday"},
+		{name: "Invalid lastModifiedTo", query: "lastModifiedTo=2024-01-01"},
+		{name: "Empty lastModified range", query: "lastModifiedFrom=2024-02-01T00:00:00Z&lastModifiedTo=2024-01-01T00:00:00Z"},
+		{name: "Invalid isDeprecated", query: "isDeprecated=maybe"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := NewHandler(&dummyEsService{}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			h.Export(w, httptest.NewRequest("GET", "/__export?"+tc.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestExportWithoutElasticClient(t *testing.T) {
+	h, err := NewHandler(&dummyEsService{returnsError: service.ErrNoElasticClient}, service.ConceptTypesFromList([]string{"genres"}, nil), publicAPIHost)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.Export(w, httptest.NewRequest("GET", "/__export", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 }
 
 type dummyEsService struct {
@@ -605,59 +1354,153 @@ type dummyEsService struct {
 	found        bool
 	result       string
 	source       json.RawMessage
-	ids          chan service.EsIDTypePair
+	pages        []service.IDScanPage
+	scan         service.IDScan
+	exported     []json.RawMessage
+	exportFilter service.ExportFilter
+	exportErr    error
+	count        int64
+	countFilter  service.ConceptFilter
+	tombstone    *service.Tombstone
+	history      []service.ChangeRecord
 }
 
-func (service *dummyEsService) LoadData(ctx context.Context, conceptType string, uuid string, payload service.EsModel) (bool, *elastic.IndexResponse, error) {
-	if service.returnsError != nil {
-		return false, nil, service.returnsError
+func (dummy *dummyEsService) LoadData(ctx context.Context, conceptType string, uuid string, payload service.EsModel) (bool, *service.IndexResult, error) {
+	if dummy.returnsError != nil {
+		return false, nil, dummy.returnsError
 	}
-	if service.noop {
+	if dummy.noop {
+		if dummy.result != "" {
+			return false, &service.IndexResult{Result: dummy.result}, nil
+		}
 		return false, nil, nil
 	}
-	return true, &elastic.IndexResponse{}, nil
+	return true, &service.IndexResult{}, nil
 }
 
-func (service *dummyEsService) CleanupData(ctx context.Context, concept service.Concept) {
+func (dummy *dummyEsService) CleanupData(ctx context.Context, concept service.Concept) {
 }
 
-func (service *dummyEsService) ReadData(uuid string) (*elastic.GetResult, error) {
-	if service.returnsError != nil {
-		return nil, service.returnsError
+func (dummy *dummyEsService) ReadData(ctx context.Context, uuid string) (*service.GetResult, error) {
+	if dummy.returnsError != nil {
+		return nil, dummy.returnsError
 	}
-	return &elastic.GetResult{Found: service.found, Source: service.source}, nil
+	return &service.GetResult{Found: dummy.found, Source: dummy.source}, nil
 }
 
-func (service *dummyEsService) DeleteData(ctx context.Context, conceptType string, uuid string) (*elastic.DeleteResponse, error) {
-	if service.returnsError != nil {
-		return nil, service.returnsError
+func (dummy *dummyEsService) DeleteData(ctx context.Context, conceptType string, uuid string) (*service.DeleteResult, error) {
+	if dummy.returnsError != nil {
+		return nil, dummy.returnsError
 	}
-	return &elastic.DeleteResponse{Result: service.result}, nil
+	return &service.DeleteResult{Result: dummy.result}, nil
 }
 
-func (service *dummyEsService) LoadBulkData(uuid string, payload interface{}) {
+func (dummy *dummyEsService) LoadBulkData(ctx context.Context, conceptType string, uuid string, payload interface{}) (bool, error) {
+	if dummy.returnsError == service.ErrConceptDeleted || dummy.returnsError == service.ErrNoElasticClient || errors.Is(dummy.returnsError, service.ErrBulkBackpressure) {
+		return false, dummy.returnsError
+	}
+	return dummy.result != service.UnchangedResult, nil
+}
 
+func (dummy *dummyEsService) ReadTombstone(ctx context.Context, uuid string) (*service.Tombstone, error) {
+	return dummy.tombstone, nil
 }
 
-func (service *dummyEsService) PatchUpdateConcept(uuid string, payload service.PayloadPatch) {
+func (dummy *dummyEsService) History(ctx context.Context, uuid string) ([]service.ChangeRecord, error) {
+	if dummy.returnsError != nil {
+		return nil, dummy.returnsError
+	}
+	return dummy.history, nil
+}
+
+func (dummy *dummyEsService) CircuitBreaker() service.CircuitBreakerState {
+	return service.CircuitBreakerState{State: service.CircuitDisabled}
+}
+
+func (dummy *dummyEsService) BulkUsage() service.BulkUsage {
+	return service.BulkUsage{}
+}
 
+func (dummy *dummyEsService) PlanWrite(_ context.Context, conceptType string, concept service.Concept, payload service.EsModel, bulk bool) ([]service.PlannedAction, error) {
+	action := service.IndexAction
+	if bulk {
+		action = service.QueueAction
+	}
+	return []service.PlannedAction{{Action: action, ConceptType: conceptType, UUID: concept.PreferredUUID()}}, nil
+}
+
+func (dummy *dummyEsService) PatchUpdateConcept(ctx context.Context, conceptType string, uuid string, payload service.PayloadPatch) error {
+	if dummy.returnsError == service.ErrNoElasticClient {
+		return dummy.returnsError
+	}
+	return nil
 }
 
-func (service *dummyEsService) IsIndexReadOnly() (bool, string, error) {
+func (dummy *dummyEsService) IsIndexReadOnly(_ context.Context) (bool, string, error) {
 	return true, "", nil
 }
 
-func (service *dummyEsService) CloseBulkProcessor() error {
-	if service.returnsError != nil {
-		return service.returnsError
+func (dummy *dummyEsService) CloseBulkProcessor(_ context.Context) error {
+	if dummy.returnsError != nil {
+		return dummy.returnsError
 	}
 	return nil
 }
 
-func (service *dummyEsService) GetClusterHealth() (*elastic.ClusterHealthResponse, error) {
+func (dummy *dummyEsService) GetClusterHealth(_ context.Context) (*service.ClusterHealth, error) {
 	return nil, nil
 }
 
-func (service *dummyEsService) GetAllIDs(_ context.Context, _ bool, _ bool) chan service.EsIDTypePair {
-	return service.ids
+func (dummy *dummyEsService) GetAllIDs(_ context.Context, scan service.IDScan) (chan service.IDScanPage, error) {
+	if dummy.returnsError != nil {
+		return nil, dummy.returnsError
+	}
+	dummy.scan = scan
+	pages := make(chan service.IDScanPage, len(dummy.pages))
+	for _, page := range dummy.pages {
+		pages <- page
+	}
+	close(pages)
+	return pages, nil
+}
+
+func (dummy *dummyEsService) CountConcepts(_ context.Context, filter service.ConceptFilter) (int64, error) {
+	if dummy.returnsError != nil {
+		return 0, dummy.returnsError
+	}
+	dummy.countFilter = filter
+	return dummy.count, nil
+}
+
+func (dummy *dummyEsService) ExportConcepts(_ context.Context, filter service.ExportFilter) (chan service.ExportedConcept, error) {
+	if dummy.returnsError != nil {
+		return nil, dummy.returnsError
+	}
+	dummy.exportFilter = filter
+	docs := make(chan service.ExportedConcept, len(dummy.exported)+1)
+	for _, doc := range dummy.exported {
+		docs <- service.ExportedConcept{Source: doc}
+	}
+	if dummy.exportErr != nil {
+		docs <- service.ExportedConcept{Err: dummy.exportErr}
+	}
+	close(docs)
+	return docs, nil
+}
+
+// assertProblem checks the problem response of a request and returns it
+func assertProblem(t *testing.T, rr *httptest.ResponseRecorder, status int, code, detail string) problem {
+	t.Helper()
+	assert.Equal(t, status, rr.Code)
+	assert.Equal(t, problemContentType, rr.Header().Get("Content-Type"))
+
+	var actual problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actual), rr.Body.String())
+	assert.Equal(t, "about:blank", actual.Type)
+	assert.Equal(t, statusTitle(status), actual.Title)
+	assert.Equal(t, status, actual.Status)
+	assert.Equal(t, code, actual.Code)
+	assert.Equal(t, detail, actual.Detail)
+	assert.NotEmpty(t, actual.TransactionID)
+	return actual
 }
@@ -0,0 +1,129 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Financial-Times/concept-rw-elasticsearch/service"
+	log "github.com/Financial-Times/go-logger"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// statusClientClosedRequest is the nginx status of a request the client cancelled before its response
+	statusClientClosedRequest = 499
+)
+
+// The codes of the error responses, which are stable unlike their details
+const (
+	codeInvalidRequest         = "invalid-request"
+	codeInvalidPayload         = "invalid-payload"
+	codeUUIDMismatch           = "uuid-mismatch"
+	codeUnsupportedConceptType = "unsupported-concept-type"
+	codeReadOnlyConceptType    = "read-only-concept-type"
+	codeConceptNotFound        = "concept-not-found"
+	codeConceptDeleted         = "concept-deleted"
+	codePreconditionFailed     = "precondition-failed"
+	codeHistoryDisabled        = "history-disabled"
+	codeCursorExpired          = "cursor-expired"
+	codeReconcileRunning       = "reconcile-running"
+	codeNoReconcileReport      = "no-reconcile-report"
+	codeTooManyRequests        = "too-many-requests"
+	codeDuplicateConcept       = "duplicate-concept"
+	codeInternalError          = "internal-error"
+	codeESUnavailable          = "es-unavailable"
+	codeESOverloaded           = "es-overloaded"
+	codeESTimeout              = "es-timeout"
+	codeRequestCancelled       = "request-cancelled"
+)
+
+// problem is an RFC 7807 error response. Its type is about:blank, the code telling the errors apart.
+type problem struct {
+	Type          string               `json:"type"`
+	Title         string               `json:"title"`
+	Status        int                  `json:"status"`
+	Code          string               `json:"code"`
+	Detail        string               `json:"detail,omitempty"`
+	Instance      string               `json:"instance"`
+	TransactionID string               `json:"transactionId"`
+	Details       []service.FieldError `json:"details,omitempty"`
+}
+
+// writeProblem responds with the error of the request, details lists the invalid fields of the request if any
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, details ...service.FieldError) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(problem{
+		Type:          "about:blank",
+		Title:         statusTitle(status),
+		Status:        status,
+		Code:          code,
+		Detail:        detail,
+		Instance:      r.URL.Path,
+		TransactionID: tid.GetTransactionIDFromRequest(r),
+		Details:       details,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to write an error response")
+	}
+}
+
+// statusTitle returns the title of the status, which net/http only knows for the registered statuses
+func statusTitle(status int) string {
+	if status == statusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// writeError responds with the status and code of the error, which is the same whatever the endpoint. The errors
+// unknown to the handlers are internal errors, their details are left to the logs.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var payloadErr *service.PayloadError
+	var backpressureErr *service.BackpressureError
+	var circuitErr *service.CircuitOpenError
+
+	switch {
+	case errors.As(err, &payloadErr):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPayload, "Concept payload does not match the schema "+payloadErr.Schema, payloadErr.Fields...)
+	case errors.Is(err, errProcessingBody), errors.Is(err, errInvalidConceptModel), errors.Is(err, service.ErrInvalidPayload):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPayload, err.Error())
+	case errors.Is(err, errPathUUID):
+		writeProblem(w, r, http.StatusBadRequest, codeUUIDMismatch, err.Error())
+	case errors.Is(err, errInvalidLastModified), errors.Is(err, service.ErrInvalidIDScan), errors.Is(err, service.ErrInvalidCursor):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	case errors.Is(err, errUnsupportedConceptType):
+		writeProblem(w, r, http.StatusNotFound, codeUnsupportedConceptType, err.Error())
+	case errors.Is(err, errReadOnlyConceptType):
+		writeProblem(w, r, http.StatusMethodNotAllowed, codeReadOnlyConceptType, err.Error())
+	case errors.Is(err, service.ErrHistoryDisabled):
+		writeProblem(w, r, http.StatusNotFound, codeHistoryDisabled, err.Error())
+	case errors.Is(err, service.ErrConceptDeleted):
+		writeProblem(w, r, http.StatusConflict, codeConceptDeleted, "Concept was deleted after it was last modified")
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "Concept does not match the If-Match header")
+	case errors.Is(err, service.ErrReconcileRunning):
+		writeProblem(w, r, http.StatusConflict, codeReconcileRunning, err.Error())
+	case errors.Is(err, service.ErrCursorExpired):
+		writeProblem(w, r, http.StatusGone, codeCursorExpired, err.Error())
+	case errors.As(err, &backpressureErr):
+		w.Header().Set(retryAfterHeader, retryAfterSeconds(backpressureErr.RetryAfter))
+		writeProblem(w, r, http.StatusTooManyRequests, codeTooManyRequests, "Too many concepts are queued for ES")
+	case errors.As(err, &circuitErr):
+		w.Header().Set(retryAfterHeader, retryAfterSeconds(circuitErr.RetryAfter))
+		writeProblem(w, r, http.StatusServiceUnavailable, codeESOverloaded, "ES overloaded")
+	case errors.Is(err, context.Canceled):
+		writeProblem(w, r, statusClientClosedRequest, codeRequestCancelled, "The request was cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		writeProblem(w, r, http.StatusGatewayTimeout, codeESTimeout, "ES request timed out")
+	case errors.Is(err, service.ErrDuplicateConcept):
+		writeProblem(w, r, http.StatusInternalServerError, codeDuplicateConcept, err.Error())
+	case errors.Is(err, service.ErrNoElasticClient), errors.Is(err, service.ErrBulkProcessorClosed):
+		writeProblem(w, r, http.StatusServiceUnavailable, codeESUnavailable, "ES unavailable")
+	default:
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "")
+	}
+}
@@ -0,0 +1,49 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Financial-Times/concept-rw-elasticsearch/service"
+	log "github.com/Financial-Times/go-logger"
+)
+
+// ReconcileHandler handles the reconciliation of the index with the upstream concept store
+type ReconcileHandler struct {
+	reconciler *service.Reconciler
+}
+
+func NewReconcileHandler(reconciler *service.Reconciler) *ReconcileHandler {
+	return &ReconcileHandler{reconciler: reconciler}
+}
+
+// Reconcile runs a reconciliation and returns its report
+func (h *ReconcileHandler) Reconcile(writer http.ResponseWriter, request *http.Request) {
+	report, err := h.reconciler.Run(request.Context())
+	if err != nil {
+		if err != service.ErrReconcileRunning {
+			log.WithError(err).Error("Reconciliation failed")
+		}
+		writeError(writer, request, err)
+		return
+	}
+	writeReport(writer, report)
+}
+
+// LastReport returns the report of the last reconciliation
+func (h *ReconcileHandler) LastReport(writer http.ResponseWriter, request *http.Request) {
+	report := h.reconciler.LastReport()
+	if report == nil {
+		writeProblem(writer, request, http.StatusNotFound, codeNoReconcileReport, "No reconciliation has completed yet")
+		return
+	}
+	writeReport(writer, report)
+}
+
+func writeReport(writer http.ResponseWriter, report *service.ReconcileReport) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(writer).Encode(report); err != nil {
+		log.WithError(err).Warn("Failed to write the reconciliation report")
+	}
+}
@@ -0,0 +1,55 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/concept-rw-elasticsearch/service"
+)
+
+func TestReconcileEndpoints(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"uuid":"1","hash":"hash-1"}` + "\n"))
+	}))
+	defer upstream.Close()
+
+	esService := service.NewMemoryService("concepts")
+	esService.LoadBulkData(context.Background(), "genres", "2", service.EsConceptModel{Type: "genres"})
+	h := NewReconcileHandler(service.NewReconciler(esService, http.DefaultClient, service.ReconcileConfig{SourceURL: upstream.URL}))
+
+	w := httptest.NewRecorder()
+	h.LastReport(w, httptest.NewRequest("GET", "/__reconcile", nil))
+	assertProblem(t, w, http.StatusNotFound, codeNoReconcileReport, "No reconciliation has completed yet")
+
+	w = httptest.NewRecorder()
+	h.Reconcile(w, httptest.NewRequest("POST", "/__reconcile", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	report := service.ReconcileReport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, []string{"1"}, report.Missing)
+	assert.Equal(t, []string{"2"}, report.Extra)
+
+	w = httptest.NewRecorder()
+	h.LastReport(w, httptest.NewRequest("GET", "/__reconcile", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestReconcileEndpointWithFailingUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	h := NewReconcileHandler(service.NewReconciler(service.NewMemoryService("concepts"), http.DefaultClient, service.ReconcileConfig{SourceURL: upstream.URL}))
+
+	w := httptest.NewRecorder()
+	h.Reconcile(w, httptest.NewRequest("POST", "/__reconcile", nil))
+	assertProblem(t, w, http.StatusInternalServerError, codeInternalError, "")
+}
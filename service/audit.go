@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrHistoryDisabled = errors.New("the history of the concepts is not recorded")
+
+const (
+	cleanupOperation = "cleanup"
+
+	// MaxHistoryRecords is the maximum number of change records returned for a concept
+	MaxHistoryRecords    = 100
+	auditConceptIDField  = "conceptId"
+	auditTimestampField  = "timestamp"
+	auditTimestampFormat = time.RFC3339Nano
+)
+
+// auditIgnoredFields change on every write or are not sourced from the concept payload
+var auditIgnoredFields = map[string]bool{
+	"lastModified":     true,
+	"indexedAt":        true,
+	"publishReference": true,
+	"contentHash":      true,
+	"metrics":          true,
+}
+
+// AuditConfig enables the audit trail: a change record is appended to Index for every write and delete of a concept
+type AuditConfig struct {
+	Index string
+}
+
+// ChangeRecord is an entry of the audit trail of a concept
+type ChangeRecord struct {
+	ConceptID     string `json:"conceptId"`
+	ConceptType   string `json:"conceptType,omitempty"`
+	Operation     string `json:"operation"`
+	Timestamp     string `json:"timestamp"`
+	TransactionID string `json:"transactionId"`
+	// Changes are the fields of the concept document that changed, by name
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+	// ConcordedUUIDs are the concorded concepts deleted by a cleanup
+	ConcordedUUIDs []string `json:"concordedUUIDs,omitempty"`
+}
+
+// FieldChange is the value of a field before and after a change, a missing value is omitted
+type FieldChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+func newChangeRecord(ctx context.Context, conceptType string, uuid string, operation string, now time.Time) ChangeRecord {
+	transactionID, err := tid.GetTransactionIDFromContext(ctx)
+	if err != nil {
+		transactionID = tidNotFound
+	}
+	return ChangeRecord{
+		ConceptID:     uuid,
+		ConceptType:   conceptType,
+		Operation:     operation,
+		Timestamp:     now.Format(auditTimestampFormat),
+		TransactionID: transactionID,
+	}
+}
+
+// diffDocuments returns the fields that differ between the stored document and the new one, either of them may be
+// empty
+func diffDocuments(before json.RawMessage, after interface{}) (map[string]FieldChange, error) {
+	beforeFields, err := documentFields(before)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := documentFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+	for name, value := range afterFields {
+		if previous := beforeFields[name]; !reflect.DeepEqual(previous, value) {
+			changes[name] = FieldChange{Before: previous, After: value}
+		}
+	}
+	for name, previous := range beforeFields {
+		if _, found := afterFields[name]; !found {
+			changes[name] = FieldChange{Before: previous}
+		}
+	}
+	return changes, nil
+}
+
+func documentFields(source json.RawMessage) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if len(source) == 0 || string(source) == "null" {
+		return fields, nil
+	}
+	if err := json.Unmarshal(source, &fields); err != nil {
+		return nil, err
+	}
+	for name := range auditIgnoredFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// historyQuery searches the latest change records of a concept
+func historyQuery(client *elastic.Client, index string, uuid string) *elastic.SearchService {
+	return client.Search(index).
+		Query(elastic.NewTermQuery(auditConceptIDField, uuid)).
+		Sort(auditTimestampField, false).
+		Size(MaxHistoryRecords)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// ftPinkAuthorities are the authorities of the concepts curated by the FT
+var ftPinkAuthorities = []string{"TME", "Smartlogic"}
+
+// ConceptFilter selects concepts by their fields. Empty fields do not filter.
+type ConceptFilter struct {
+	// Types are the concept types to select
+	Types []string
+	// Authorities selects the concepts identified by any of these authorities
+	Authorities []string
+	// ExcludedAuthorities leaves out the concepts identified by any of these authorities
+	ExcludedAuthorities []string
+	// ModifiedFrom and ModifiedTo are the inclusive bounds of the lastModified date of the concepts
+	ModifiedFrom time.Time
+	ModifiedTo   time.Time
+	// IsDeprecated selects only the deprecated concepts when true, or only the ones that are not deprecated when false
+	IsDeprecated *bool
+	// IsFTAuthor selects only the people who are FT authors when true, or only the concepts that are not when false
+	IsFTAuthor *bool
+}
+
+// ExcludeFTPinkAuthorities leaves out the concepts identified by the FT authorities
+func (f *ConceptFilter) ExcludeFTPinkAuthorities() {
+	for _, authority := range ftPinkAuthorities {
+		if !contains(f.ExcludedAuthorities, authority) {
+			f.ExcludedAuthorities = append(f.ExcludedAuthorities, authority)
+		}
+	}
+}
+
+func (f ConceptFilter) query() elastic.Query {
+	query := elastic.NewBoolQuery()
+	if len(f.Types) > 0 {
+		query.Filter(elastic.NewTermsQuery("type", toInterfaces(f.Types)...))
+	}
+	if len(f.Authorities) > 0 {
+		query.Filter(elastic.NewTermsQuery("authorities", toInterfaces(f.Authorities)...))
+	}
+	if len(f.ExcludedAuthorities) > 0 {
+		query.MustNot(elastic.NewTermsQuery("authorities", toInterfaces(f.ExcludedAuthorities)...))
+	}
+	if !f.ModifiedFrom.IsZero() || !f.ModifiedTo.IsZero() {
+		lastModified := elastic.NewRangeQuery("lastModified")
+		if !f.ModifiedFrom.IsZero() {
+			lastModified.Gte(f.ModifiedFrom.Format(time.RFC3339))
+		}
+		if !f.ModifiedTo.IsZero() {
+			lastModified.Lte(f.ModifiedTo.Format(time.RFC3339))
+		}
+		query.Filter(lastModified)
+	}
+	// isDeprecated and isFTAuthor are only stored when true
+	if f.IsDeprecated != nil {
+		filterFlag(query, elastic.NewTermQuery("isDeprecated", true), *f.IsDeprecated)
+	}
+	if f.IsFTAuthor != nil {
+		filterFlag(query, elastic.NewTermQuery("isFTAuthor", "true"), *f.IsFTAuthor)
+	}
+	return query
+}
+
+func filterFlag(query *elastic.BoolQuery, isSet elastic.Query, expected bool) {
+	if expected {
+		query.Filter(isSet)
+	} else {
+		query.MustNot(isSet)
+	}
+}
+
+// matches reports whether the document is selected by the filter, the same way query does in elasticsearch
+func (f ConceptFilter) matches(source json.RawMessage) bool {
+	doc := EsPersonConceptModel{EsConceptModel: &EsConceptModel{}}
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return false
+	}
+
+	if len(f.Types) > 0 && !contains(f.Types, doc.Type) {
+		return false
+	}
+	if len(f.Authorities) > 0 && !containsAny(f.Authorities, doc.Authorities) {
+		return false
+	}
+	if containsAny(f.ExcludedAuthorities, doc.Authorities) {
+		return false
+	}
+	if !f.ModifiedFrom.IsZero() || !f.ModifiedTo.IsZero() {
+		lastModified, err := time.Parse(time.RFC3339, doc.LastModified)
+		if err != nil || lastModified.Before(f.ModifiedFrom) || (!f.ModifiedTo.IsZero() && lastModified.After(f.ModifiedTo)) {
+			return false
+		}
+	}
+	if f.IsDeprecated != nil && *f.IsDeprecated != doc.IsDeprecated {
+		return false
+	}
+	return f.IsFTAuthor == nil || *f.IsFTAuthor == (doc.IsFTAuthor == "true")
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if contains(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
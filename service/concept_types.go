@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+)
+
+const (
+	// ConceptConverter converts the payload into the generic concept document
+	ConceptConverter = "concept"
+	// PersonConverter converts the payload into a person document, which also tracks whether the person is an FT author
+	PersonConverter = "person"
+	// OrganisationConverter converts the payload into an organisation document with the public company details
+	OrganisationConverter = "organisation"
+	// MembershipConverter converts the payload into a membership, which updates the person it belongs to
+	MembershipConverter = "membership"
+)
+
+// ConceptTypeConfig is the configuration of a single concept type
+type ConceptTypeConfig struct {
+	// Index is the index or alias the concepts are written to, the service index is used when empty
+	Index string `json:"index,omitempty"`
+	// Read allows the concepts to be read
+	Read bool `json:"read"`
+	// Write allows the concepts to be written and deleted
+	Write bool `json:"write"`
+	// Converter is the name of the conversion from the payload to the elasticsearch document, it defaults to the
+	// conversion the concept type had before being configurable
+	Converter string `json:"converter,omitempty"`
+	// SchemaVersion is the version of the schema the payloads are validated against, it defaults to
+	// DefaultSchemaVersion
+	SchemaVersion string `json:"schemaVersion,omitempty"`
+}
+
+// ConceptTypes holds the configuration of the supported concept types. It is safe for concurrent use and can be
+// reloaded from its configuration file while the service is running.
+type ConceptTypes struct {
+	sync.RWMutex
+	types map[string]ConceptTypeConfig
+	// source is the content of the configuration file the types were loaded from
+	source []byte
+}
+
+// NewConceptTypes validates the configuration of the concept types
+func NewConceptTypes(types map[string]ConceptTypeConfig) (*ConceptTypes, error) {
+	validated, err := validateConceptTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	return &ConceptTypes{types: validated}, nil
+}
+
+// ConceptTypesFromList returns readable and writable concept types using their default converter, as configured by
+// the whitelisted concepts option. The concept types found in indices are written to the given index.
+func ConceptTypesFromList(conceptTypes []string, indices map[string]string) *ConceptTypes {
+	types := make(map[string]ConceptTypeConfig)
+	for _, conceptType := range conceptTypes {
+		types[conceptType] = ConceptTypeConfig{Index: indices[conceptType], Read: true, Write: true, Converter: DefaultConverter(conceptType), SchemaVersion: DefaultSchemaVersion}
+	}
+	return &ConceptTypes{types: types}
+}
+
+// ParseTypeIndices parses a comma separated list of concept type to index mappings, e.g.
+// people=concepts-people,organisations=concepts-orgs
+func ParseTypeIndices(value string) (map[string]string, error) {
+	indices := make(map[string]string)
+	for _, mapping := range strings.Split(value, ",") {
+		mapping = strings.TrimSpace(mapping)
+		if mapping == "" {
+			continue
+		}
+		conceptType, index, found := strings.Cut(mapping, "=")
+		conceptType, index = strings.TrimSpace(conceptType), strings.TrimSpace(index)
+		if !found || conceptType == "" || index == "" {
+			return nil, fmt.Errorf("invalid concept type index mapping %q, expected <concept type>=<index>", mapping)
+		}
+		indices[conceptType] = index
+	}
+	return indices, nil
+}
+
+// LoadConceptTypes reads the concept types from a JSON file mapping each concept type to its configuration
+func LoadConceptTypes(path string) (*ConceptTypes, error) {
+	ct := &ConceptTypes{}
+	if _, err := ct.Reload(path); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// Reload reads the configuration file again and replaces the concept types if the file changed. An invalid file,
+// or one moving a configured concept type to another index, is reported and the current concept types are kept:
+// the concepts already written would stay in the old index, so an index change needs a migration and a restart.
+func (ct *ConceptTypes) Reload(path string) (bool, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading concept types from %s: %w", path, err)
+	}
+
+	ct.RLock()
+	unchanged := ct.types != nil && bytes.Equal(source, ct.source)
+	ct.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	types := make(map[string]ConceptTypeConfig)
+	if err := json.Unmarshal(source, &types); err != nil {
+		return false, fmt.Errorf("parsing concept types from %s: %w", path, err)
+	}
+	validated, err := validateConceptTypes(types)
+	if err != nil {
+		return false, fmt.Errorf("invalid concept types in %s: %w", path, err)
+	}
+
+	ct.Lock()
+	defer ct.Unlock()
+	for name, current := range ct.types {
+		if config, found := validated[name]; found && config.Index != current.Index {
+			return false, fmt.Errorf("invalid concept types in %s: the index of %s cannot change from %q to %q on reload", path, name, current.Index, config.Index)
+		}
+	}
+	ct.types = validated
+	ct.source = source
+	return true, nil
+}
+
+// Watch reloads the configuration file at every interval until ctx is done
+func (ct *ConceptTypes) Watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		reloaded, err := ct.Reload(path)
+		if err != nil {
+			log.WithError(err).Error("Failed to reload the concept types, keeping the current ones")
+			continue
+		}
+		if reloaded {
+			log.Infof("Reloaded the concept types, the writer handles the following concept types: %v", ct.Names())
+		}
+	}
+}
+
+// Get returns the configuration of the concept type, if it is supported
+func (ct *ConceptTypes) Get(conceptType string) (ConceptTypeConfig, bool) {
+	if ct == nil {
+		return ConceptTypeConfig{}, false
+	}
+	ct.RLock()
+	defer ct.RUnlock()
+
+	config, found := ct.types[conceptType]
+	return config, found
+}
+
+// Readable reports whether the concept type is supported and can be read
+func (ct *ConceptTypes) Readable(conceptType string) bool {
+	config, found := ct.Get(conceptType)
+	return found && config.Read
+}
+
+// Writable reports whether the concept type is supported and can be written
+func (ct *ConceptTypes) Writable(conceptType string) bool {
+	config, found := ct.Get(conceptType)
+	return found && config.Write
+}
+
+// Index returns the index the concept type is written to, empty if the concept type has no index of its own
+func (ct *ConceptTypes) Index(conceptType string) string {
+	config, _ := ct.Get(conceptType)
+	return config.Index
+}
+
+// Converter returns the converter of the concept type, its default converter if the concept type is not configured
+func (ct *ConceptTypes) Converter(conceptType string) string {
+	if config, found := ct.Get(conceptType); found && config.Converter != "" {
+		return config.Converter
+	}
+	return DefaultConverter(conceptType)
+}
+
+// PersonType returns the concept type of the people the memberships update: people if it is configured as a person,
+// otherwise the first concept type that is, and people when none is
+func (ct *ConceptTypes) PersonType() string {
+	if config, found := ct.Get(person); found && config.Converter == PersonConverter {
+		return person
+	}
+	for _, name := range ct.Names() {
+		if ct.Converter(name) == PersonConverter {
+			return name
+		}
+	}
+	return person
+}
+
+// Indices returns the sorted indices configured for the concept types
+func (ct *ConceptTypes) Indices() []string {
+	if ct == nil {
+		return nil
+	}
+	ct.RLock()
+	defer ct.RUnlock()
+
+	unique := make(map[string]bool)
+	for _, config := range ct.types {
+		if config.Index != "" {
+			unique[config.Index] = true
+		}
+	}
+	indices := make([]string, 0, len(unique))
+	for index := range unique {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices
+}
+
+// Names returns the sorted names of the supported concept types
+func (ct *ConceptTypes) Names() []string {
+	if ct == nil {
+		return nil
+	}
+	ct.RLock()
+	defer ct.RUnlock()
+
+	names := make([]string, 0, len(ct.types))
+	for name := range ct.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateConceptTypes(types map[string]ConceptTypeConfig) (map[string]ConceptTypeConfig, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no concept types configured")
+	}
+
+	validated := make(map[string]ConceptTypeConfig, len(types))
+	for conceptType, config := range types {
+		if conceptType == "" {
+			return nil, fmt.Errorf("empty concept type name")
+		}
+		if config.Converter == "" {
+			config.Converter = DefaultConverter(conceptType)
+		}
+		switch config.Converter {
+		case ConceptConverter, PersonConverter, OrganisationConverter, MembershipConverter:
+		default:
+			return nil, fmt.Errorf("unknown converter %q for concept type %s", config.Converter, conceptType)
+		}
+		if config.SchemaVersion == "" {
+			config.SchemaVersion = DefaultSchemaVersion
+		}
+		if _, err := payloadSchema(config.SchemaVersion, config.Converter); err != nil {
+			return nil, fmt.Errorf("invalid schema for concept type %s: %w", conceptType, err)
+		}
+		validated[conceptType] = config
+	}
+	return validated, nil
+}
+
+// DefaultConverter returns the converter of the concept type when it is not configured
+func DefaultConverter(conceptType string) string {
+	switch conceptType {
+	case person:
+		return PersonConverter
+	case organisation:
+		return OrganisationConverter
+	case memberships:
+		return MembershipConverter
+	default:
+		return ConceptConverter
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConceptTypes(t *testing.T) {
+	path := writeConceptTypes(t, `{
+		"genres": {"read": true, "write": true},
+		"people": {"index": "concepts-people", "read": true, "write": true},
+		"fta-people": {"read": true, "write": false, "converter": "person"}
+	}`)
+
+	conceptTypes, err := LoadConceptTypes(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"fta-people", "genres", "people"}, conceptTypes.Names())
+	assert.Equal(t, "concepts-people", conceptTypes.Index(person))
+	assert.Empty(t, conceptTypes.Index("genres"))
+	assert.True(t, conceptTypes.Readable("fta-people"))
+	assert.False(t, conceptTypes.Writable("fta-people"))
+	assert.False(t, conceptTypes.Readable("topics"))
+
+	genres, found := conceptTypes.Get("genres")
+	require.True(t, found)
+	assert.Equal(t, ConceptConverter, genres.Converter, "the default converter should be used")
+	ftaPeople, _ := conceptTypes.Get("fta-people")
+	assert.Equal(t, PersonConverter, ftaPeople.Converter)
+	assert.Equal(t, DefaultSchemaVersion, ftaPeople.SchemaVersion, "the default schema version should be used")
+}
+
+func TestConceptTypeConverters(t *testing.T) {
+	conceptTypes, err := NewConceptTypes(map[string]ConceptTypeConfig{
+		"authors":   {Read: true, Write: true, Converter: PersonConverter},
+		"companies": {Read: true, Write: true, Converter: OrganisationConverter},
+		"roles":     {Read: true, Write: true, Converter: MembershipConverter},
+		"labels":    {Read: true, Write: true, Converter: ConceptConverter},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, PersonConverter, conceptTypes.Converter("authors"))
+	assert.Equal(t, OrganisationConverter, conceptTypes.Converter("companies"))
+	assert.Equal(t, MembershipConverter, conceptTypes.Converter("roles"))
+	assert.Equal(t, ConceptConverter, conceptTypes.Converter("labels"))
+	assert.Equal(t, MembershipConverter, conceptTypes.Converter(memberships), "a type not configured has its default converter")
+	assert.Equal(t, "authors", conceptTypes.PersonType())
+
+	assert.Equal(t, person, (*ConceptTypes)(nil).PersonType())
+	withPeople, err := NewConceptTypes(map[string]ConceptTypeConfig{
+		"authors": {Converter: PersonConverter},
+		"people":  {},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, person, withPeople.PersonType(), "people are preferred when they are converted as people")
+}
+
+func TestLoadConceptTypesErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+	}{
+		{name: "Invalid JSON", config: `{"genres":`},
+		{name: "No concept types", config: `{}`},
+		{name: "Unknown converter", config: `{"genres": {"read": true, "converter": "genre"}}`},
+		{name: "Unknown schema version", config: `{"genres": {"read": true, "schemaVersion": "v0"}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadConceptTypes(writeConceptTypes(t, tc.config))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := LoadConceptTypes(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestReloadConceptTypes(t *testing.T) {
+	path := writeConceptTypes(t, `{"genres": {"read": true, "write": true}}`)
+	conceptTypes, err := LoadConceptTypes(path)
+	require.NoError(t, err)
+
+	reloaded, err := conceptTypes.Reload(path)
+	require.NoError(t, err)
+	assert.False(t, reloaded, "an unchanged file should not be reloaded")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"genres": {"read": true}, "fta-brands": {"read": true, "write": true}}`), 0600))
+	reloaded, err = conceptTypes.Reload(path)
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.False(t, conceptTypes.Writable("genres"))
+	assert.True(t, conceptTypes.Writable("fta-brands"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"genres": {"converter": "unknown"}}`), 0600))
+	_, err = conceptTypes.Reload(path)
+	assert.Error(t, err)
+	assert.True(t, conceptTypes.Writable("fta-brands"), "the current concept types should be kept")
+}
+
+func TestReloadConceptTypesKeepsTheIndices(t *testing.T) {
+	path := writeConceptTypes(t, `{"genres": {"read": true, "write": true}, "people": {"index": "concepts-people", "read": true, "write": true}}`)
+	conceptTypes, err := LoadConceptTypes(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"genres": {"read": true, "write": true}, "people": {"index": "people", "read": true, "write": true}}`), 0600))
+	_, err = conceptTypes.Reload(path)
+	assert.Error(t, err)
+	assert.Equal(t, "concepts-people", conceptTypes.Index(person), "the current index should be kept")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"genres": {"index": "concepts-genres", "read": true, "write": true}}`), 0600))
+	_, err = conceptTypes.Reload(path)
+	assert.Error(t, err, "a concept type cannot move out of the default index")
+	assert.Empty(t, conceptTypes.Index("genres"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"genres": {"read": true}, "topics": {"index": "concepts-topics", "read": true, "write": true}}`), 0600))
+	reloaded, err := conceptTypes.Reload(path)
+	require.NoError(t, err)
+	assert.True(t, reloaded, "new concept types can have an index of their own")
+	assert.Equal(t, "concepts-topics", conceptTypes.Index("topics"))
+}
+
+func TestWatchConceptTypes(t *testing.T) {
+	path := writeConceptTypes(t, `{"genres": {"read": true, "write": true}}`)
+	conceptTypes, err := LoadConceptTypes(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go conceptTypes.Watch(ctx, path, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"topics": {"read": true, "write": true}}`), 0600))
+	assert.Eventually(t, func() bool {
+		return conceptTypes.Writable("topics")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, conceptTypes.Readable("genres"))
+}
+
+func TestConceptTypesFromList(t *testing.T) {
+	conceptTypes := ConceptTypesFromList([]string{"genres", "memberships"}, map[string]string{"memberships": "concepts"})
+
+	memberships, found := conceptTypes.Get("memberships")
+	require.True(t, found)
+	assert.Equal(t, ConceptTypeConfig{Index: "concepts", Read: true, Write: true, Converter: MembershipConverter, SchemaVersion: DefaultSchemaVersion}, memberships)
+	assert.True(t, conceptTypes.Writable("genres"))
+	assert.Empty(t, conceptTypes.Index("genres"))
+
+	var unconfigured *ConceptTypes
+	assert.False(t, unconfigured.Readable("genres"))
+	assert.Empty(t, unconfigured.Index("genres"))
+}
+
+func TestParseTypeIndices(t *testing.T) {
+	indices, err := ParseTypeIndices("people=concepts-people, organisations=concepts-orgs,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"people": "concepts-people", "organisations": "concepts-orgs"}, indices)
+
+	indices, err = ParseTypeIndices("")
+	require.NoError(t, err)
+	assert.Empty(t, indices)
+
+	for _, invalid := range []string{"people", "people=", "=concepts-people"} {
+		_, err = ParseTypeIndices(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func writeConceptTypes(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "concept-types.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+	return path
+}
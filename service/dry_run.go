@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"sort"
+)
+
+const (
+	IndexAction  = "index"
+	QueueAction  = "queue"
+	UpdateAction = "update"
+	DeleteAction = "delete"
+	DropAction   = "drop"
+)
+
+// PlannedAction is a change the write of a concept would make, reported by a dry run. The actions depending on the
+// stored concept have a condition, as a dry run does not read it.
+type PlannedAction struct {
+	Action      string `json:"action"`
+	ConceptType string `json:"conceptType,omitempty"`
+	UUID        string `json:"uuid"`
+	Index       string `json:"index,omitempty"`
+	Condition   string `json:"condition,omitempty"`
+}
+
+// planWrite returns the actions of writing the payload of the concept, with LoadData or with LoadBulkData when bulk
+// is set, followed by the cleanup of its concorded concepts
+func planWrite(writeIndex func(conceptType string) string, conceptTypes *ConceptTypes, conceptType string, concept Concept, payload EsModel, bulk bool, cleanup []PlannedAction) []PlannedAction {
+	uuid := concept.PreferredUUID()
+	if bulk {
+		return append([]PlannedAction{{
+			Action:      QueueAction,
+			ConceptType: conceptType,
+			UUID:        uuid,
+			Index:       writeIndex(conceptType),
+			Condition:   "unless the stored concept is unchanged or was deleted after it was last modified",
+		}}, cleanup...)
+	}
+
+	if conceptTypes.Converter(conceptType) == MembershipConverter {
+		personType := conceptTypes.PersonType()
+		membership, ok := payload.(*EsMembershipModel)
+		if !ok || membership.OrganisationId != ftOrgUUID || len(membership.Memberships) < 1 || !isFtAuthor(membership.Memberships) {
+			return []PlannedAction{{Action: DropAction, ConceptType: conceptType, UUID: uuid, Condition: "not the membership of an FT author"}}
+		}
+		return append([]PlannedAction{{
+			Action:      UpdateAction,
+			ConceptType: personType,
+			UUID:        membership.PersonId,
+			Index:       writeIndex(personType),
+			Condition:   "flags the stored person as an FT author, or writes a person if there is none",
+		}}, cleanup...)
+	}
+
+	return append([]PlannedAction{
+		{
+			Action:      IndexAction,
+			ConceptType: conceptType,
+			UUID:        uuid,
+			Index:       writeIndex(conceptType),
+			Condition:   "unless the stored concept is unchanged or was deleted after it was last modified",
+		},
+		{
+			Action:      UpdateAction,
+			ConceptType: conceptType,
+			UUID:        uuid,
+			Index:       writeIndex(conceptType),
+			Condition:   "if the concept is stored, to keep its metrics",
+		},
+	}, cleanup...)
+}
+
+// planCleanup returns the deletes done by CleanupData of the concorded concepts that are stored, sorted by uuid
+func planCleanup(stored map[string]storedConcept) []PlannedAction {
+	actions := make([]PlannedAction, 0, len(stored))
+	for uuid, found := range stored {
+		actions = append(actions, PlannedAction{Action: DeleteAction, ConceptType: found.conceptType, UUID: uuid, Index: found.index})
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].UUID < actions[j].UUID })
+	return actions
+}
+
+// PlanWrite returns the actions of writing the concept without writing to elasticsearch. The concorded concepts are
+// looked up like CleanupData does, so that only the ones it would delete are reported.
+func (es *esService) PlanWrite(ctx context.Context, conceptType string, concept Concept, payload EsModel, bulk bool) ([]PlannedAction, error) {
+	client, err := es.client()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := es.findConcepts(ctx, client, concept.ConcordedUUIDs())
+	if err != nil {
+		return nil, err
+	}
+	return planWrite(es.writeIndex, es.conceptTypes, conceptType, concept, payload, bulk, planCleanup(stored)), nil
+}
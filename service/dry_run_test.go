@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanWrite(t *testing.T) {
+	writeIndex := func(conceptType string) string { return "concepts-" + conceptType }
+	prefUUID := uuid.New().String()
+	concordedUUID := uuid.New().String()
+	concept := AggregateConceptModel{
+		PrefUUID:              prefUUID,
+		SourceRepresentations: []SourceConcept{{UUID: prefUUID}, {UUID: concordedUUID}},
+	}
+	cleanup := PlannedAction{Action: DeleteAction, ConceptType: "genres", UUID: concordedUUID, Index: "concepts-genres"}
+
+	actions := planWrite(writeIndex, nil, organisationsType, concept, &EsConceptModel{Id: prefUUID}, false, []PlannedAction{cleanup})
+	if assert.Len(t, actions, 3) {
+		assert.Equal(t, IndexAction, actions[0].Action)
+		assert.Equal(t, prefUUID, actions[0].UUID)
+		assert.Equal(t, "concepts-"+organisationsType, actions[0].Index)
+		assert.Equal(t, UpdateAction, actions[1].Action)
+		assert.Equal(t, cleanup, actions[2])
+	}
+
+	actions = planWrite(writeIndex, nil, organisationsType, concept, &EsConceptModel{Id: prefUUID}, true, []PlannedAction{cleanup})
+	if assert.Len(t, actions, 2) {
+		assert.Equal(t, QueueAction, actions[0].Action)
+		assert.Equal(t, "concepts-"+organisationsType, actions[0].Index)
+		assert.Equal(t, cleanup, actions[1])
+	}
+}
+
+func TestPlanWriteMembership(t *testing.T) {
+	writeIndex := func(conceptType string) string { return "concepts-" + conceptType }
+	membershipUUID := uuid.New().String()
+	personUUID := uuid.New().String()
+	concept := ConceptModel{UUID: membershipUUID}
+
+	actions := planWrite(writeIndex, nil, memberships, concept, &EsMembershipModel{
+		Id:             membershipUUID,
+		PersonId:       personUUID,
+		OrganisationId: ftOrgUUID,
+		Memberships:    []string{journalistUUID},
+	}, false, nil)
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, UpdateAction, actions[0].Action)
+		assert.Equal(t, person, actions[0].ConceptType)
+		assert.Equal(t, personUUID, actions[0].UUID)
+		assert.Equal(t, "concepts-"+person, actions[0].Index)
+	}
+
+	actions = planWrite(writeIndex, nil, memberships, concept, &EsMembershipModel{
+		Id:             membershipUUID,
+		PersonId:       personUUID,
+		OrganisationId: uuid.New().String(),
+		Memberships:    []string{journalistUUID},
+	}, false, nil)
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, DropAction, actions[0].Action)
+		assert.Equal(t, membershipUUID, actions[0].UUID)
+	}
+}
+
+func TestPlanWriteReportsTheStoredConcordedConcepts(t *testing.T) {
+	prefUUID := uuid.New().String()
+	storedUUID := uuid.New().String()
+	missingUUID := uuid.New().String()
+	var requests []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"hits":{"hits":[{"_index":"concepts-genres","_id":"%s","_source":{"type":"genres"}}]}}`, storedUUID)
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now}
+	concept := AggregateConceptModel{
+		PrefUUID:              prefUUID,
+		SourceRepresentations: []SourceConcept{{UUID: prefUUID}, {UUID: storedUUID}, {UUID: missingUUID}},
+	}
+
+	actions, err := service.PlanWrite(newTestContext(), organisationsType, concept, &EsConceptModel{Id: prefUUID}, false)
+	require.NoError(t, err)
+	if assert.Len(t, actions, 3) {
+		assert.Equal(t, PlannedAction{Action: DeleteAction, ConceptType: "genres", UUID: storedUUID, Index: "concepts-genres"}, actions[2], "only the concorded concepts that are stored are deleted")
+	}
+	assert.Equal(t, []string{"POST /" + indexName + "/_search"}, requests, "a dry run only looks up the concorded concepts")
+}
+
+func TestMemoryPlanWrite(t *testing.T) {
+	service := NewMemoryService(indexName)
+	prefUUID := uuid.New().String()
+	storedUUID := uuid.New().String()
+	_, _, _, err := writeTestDocument(service, "genres", storedUUID)
+	require.NoError(t, err)
+
+	concept := AggregateConceptModel{
+		PrefUUID:              prefUUID,
+		SourceRepresentations: []SourceConcept{{UUID: prefUUID}, {UUID: storedUUID}, {UUID: uuid.New().String()}},
+	}
+	actions, err := service.PlanWrite(newTestContext(), organisationsType, concept, &EsConceptModel{Id: prefUUID}, true)
+	require.NoError(t, err)
+	if assert.Len(t, actions, 2) {
+		assert.Equal(t, PlannedAction{Action: DeleteAction, ConceptType: "genres", UUID: storedUUID}, actions[1])
+	}
+	stored, err := service.ReadData(newTestContext(), storedUUID)
+	require.NoError(t, err)
+	assert.True(t, stored.Found, "a dry run deletes nothing")
+}
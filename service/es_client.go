@@ -1,7 +1,8 @@
 package service
 
 import (
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,21 +19,36 @@ type EsAccessConfig struct {
 	awsCreds     *credentials.Credentials
 	esEndpoint   string
 	traceLogging bool
+	auth         AuthConfig
 }
 
-func NewAccessConfig(awsCreds *credentials.Credentials, endpoint string, tracelogging bool) EsAccessConfig {
-	return EsAccessConfig{awsCreds: awsCreds, esEndpoint: endpoint, traceLogging: tracelogging}
+func NewAccessConfig(awsCreds *credentials.Credentials, endpoint string, tracelogging bool, auth AuthConfig) EsAccessConfig {
+	return EsAccessConfig{awsCreds: awsCreds, esEndpoint: endpoint, traceLogging: tracelogging, auth: auth}
 }
 
+const (
+	// SigningServiceES is the SigV4 service name of Amazon Elasticsearch and Amazon OpenSearch managed domains
+	SigningServiceES = "es"
+	// SigningServiceAOSS is the SigV4 service name of Amazon OpenSearch Serverless collections
+	SigningServiceAOSS = "aoss"
+)
+
 type AWSSigningTransport struct {
 	HTTPClient  *http.Client
 	Credentials *credentials.Credentials
 	Region      string
+	// Service is the SigV4 signing service name, SigningServiceES is used when empty
+	Service string
 }
 
 // RoundTrip implementation
 func (a AWSSigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	signer := awsSigner.NewSigner(a.Credentials)
+	service := a.Service
+	if service == "" {
+		service = SigningServiceES
+	}
+
 	if req.Body != nil {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
@@ -40,12 +56,19 @@ func (a AWSSigningTransport) RoundTrip(req *http.Request) (*http.Response, error
 		}
 		body := strings.NewReader(string(b))
 		defer req.Body.Close()
-		_, err = signer.Sign(req, body, "es", a.Region, time.Now())
+		if service == SigningServiceAOSS {
+			// OpenSearch Serverless requires the payload hash to be sent along with the signature
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash(b))
+		}
+		_, err = signer.Sign(req, body, service, a.Region, time.Now())
 		if err != nil {
 			return nil, fmt.Errorf("failed to sign request: %w", err)
 		}
 	} else {
-		_, err := signer.Sign(req, nil, "es", a.Region, time.Now())
+		if service == SigningServiceAOSS {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash(nil))
+		}
+		_, err := signer.Sign(req, nil, service, a.Region, time.Now())
 		if err != nil {
 			return nil, fmt.Errorf("failed to sign request: %w", err)
 		}
@@ -54,28 +77,38 @@ func (a AWSSigningTransport) RoundTrip(req *http.Request) (*http.Response, error
 	return a.HTTPClient.Do(req)
 }
 
-func newAmazonClient(config EsAccessConfig, region string) (*elastic.Client, error) {
-	signingTransport := AWSSigningTransport{
-		Credentials: config.awsCreds,
-		HTTPClient: &http.Client{
-			Transport: &http.Transport{
-				TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
-			},
-		},
-		Region: region,
+func payloadHash(payload []byte) string {
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])
+}
+
+func newAuthenticatedClient(config EsAccessConfig, region string) (*elastic.Client, error) {
+	strategy, err := newAuthStrategy(config.auth, region, config.awsCreds)
+	if err != nil {
+		return nil, err
 	}
-	signingClient := &http.Client{Transport: http.RoundTripper(signingTransport)}
 
-	log.Infof("connecting with AWSSigningTransport to %s", config.esEndpoint)
-	return newClient(config.esEndpoint, config.traceLogging,
-		elastic.SetScheme("https"),
-		elastic.SetHttpClient(signingClient),
-	)
-}
+	base, err := newBaseTransport(config.auth.CABundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := strategy.transport(base)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []elastic.ClientOptionFunc{elastic.SetHttpClient(&http.Client{Transport: transport})}
+	if config.auth.StrategyName(region) == AuthAWSSigV4 {
+		options = append(options, elastic.SetScheme("https"))
+		if config.auth.SigningService == SigningServiceAOSS {
+			// serverless collections do not expose the root endpoint used by the client healthcheck
+			options = append(options, elastic.SetHealthcheck(false))
+		}
+	}
 
-func newSimpleClient(config EsAccessConfig) (*elastic.Client, error) {
-	log.Infof("connecting with default transport to %s", config.esEndpoint)
-	return newClient(config.esEndpoint, config.traceLogging)
+	log.Infof("connecting with %s authentication to %s", config.auth.StrategyName(region), config.esEndpoint)
+	return newClient(config.esEndpoint, config.traceLogging, options...)
 }
 
 func newClient(endpoint string, traceLogging bool, options ...elastic.ClientOptionFunc) (*elastic.Client, error) {
@@ -93,9 +126,5 @@ func newClient(endpoint string, traceLogging bool, options ...elastic.ClientOpti
 }
 
 func NewElasticClient(region string, config EsAccessConfig) (*elastic.Client, error) {
-	if region == "local" {
-		return newSimpleClient(config)
-	} else {
-		return newAmazonClient(config, region)
-	}
+	return newAuthenticatedClient(config, region)
 }
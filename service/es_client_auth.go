@@ -0,0 +1,191 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+const (
+	AuthNone     = "none"
+	AuthBasic    = "basic"
+	AuthAPIKey   = "api-key"
+	AuthBearer   = "bearer"
+	AuthMTLS     = "mtls"
+	AuthAWSSigV4 = "aws-sigv4"
+
+	localRegion = "local"
+)
+
+// AuthConfig holds the settings of all the supported authentication strategies, only the ones required by Strategy are used.
+// An empty Strategy keeps the historical behaviour: no authentication for the "local" region and AWS signing otherwise.
+type AuthConfig struct {
+	Strategy       string
+	Username       string
+	Password       string
+	APIKey         string
+	BearerToken    string
+	CABundlePath   string
+	ClientCertPath string
+	ClientKeyPath  string
+	SigningService string
+}
+
+// authStrategy builds the transport used by the elasticsearch client from a base transport,
+// adding whatever the cluster requires to authenticate the requests.
+type authStrategy interface {
+	transport(base *http.Transport) (http.RoundTripper, error)
+}
+
+// StrategyName returns the authentication strategy that will be used for the given region.
+func (c AuthConfig) StrategyName(region string) string {
+	if c.Strategy != "" {
+		return c.Strategy
+	}
+	if region == localRegion {
+		return AuthNone
+	}
+	return AuthAWSSigV4
+}
+
+// Validate checks that the settings required by the selected authentication strategy are present.
+func (c AuthConfig) Validate(region string) error {
+	switch c.StrategyName(region) {
+	case AuthNone:
+		return nil
+	case AuthAWSSigV4:
+		if c.SigningService != "" && c.SigningService != SigningServiceES && c.SigningService != SigningServiceAOSS {
+			return fmt.Errorf("unknown AWS signing service %q", c.SigningService)
+		}
+	case AuthBasic:
+		if c.Username == "" || c.Password == "" {
+			return errors.New("basic authentication requires a username and a password")
+		}
+	case AuthAPIKey:
+		if c.APIKey == "" {
+			return errors.New("api-key authentication requires an API key")
+		}
+	case AuthBearer:
+		if c.BearerToken == "" {
+			return errors.New("bearer authentication requires a token")
+		}
+	case AuthMTLS:
+		if c.ClientCertPath == "" || c.ClientKeyPath == "" {
+			return errors.New("mtls authentication requires a client certificate and key")
+		}
+	default:
+		return fmt.Errorf("unknown authentication strategy %q", c.Strategy)
+	}
+	return nil
+}
+
+func newAuthStrategy(c AuthConfig, region string, awsCreds *credentials.Credentials) (authStrategy, error) {
+	if err := c.Validate(region); err != nil {
+		return nil, err
+	}
+
+	switch c.StrategyName(region) {
+	case AuthBasic:
+		userInfo := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+		return headerAuth{value: "Basic " + userInfo}, nil
+	case AuthAPIKey:
+		return headerAuth{value: "ApiKey " + c.APIKey}, nil
+	case AuthBearer:
+		return headerAuth{value: "Bearer " + c.BearerToken}, nil
+	case AuthMTLS:
+		return mtlsAuth{certPath: c.ClientCertPath, keyPath: c.ClientKeyPath}, nil
+	case AuthAWSSigV4:
+		return awsSigV4Auth{credentials: awsCreds, region: region, service: c.SigningService}, nil
+	default:
+		return noAuth{}, nil
+	}
+}
+
+type noAuth struct{}
+
+func (noAuth) transport(base *http.Transport) (http.RoundTripper, error) {
+	return base, nil
+}
+
+// headerAuth sets the Authorization header on every request
+type headerAuth struct {
+	value string
+}
+
+func (a headerAuth) transport(base *http.Transport) (http.RoundTripper, error) {
+	return authorizationTransport{next: base, value: a.value}, nil
+}
+
+type authorizationTransport struct {
+	next  http.RoundTripper
+	value string
+}
+
+// RoundTrip implementation
+func (t authorizationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.value)
+	return t.next.RoundTrip(req)
+}
+
+// mtlsAuth presents a client certificate during the TLS handshake
+type mtlsAuth struct {
+	certPath string
+	keyPath  string
+}
+
+func (a mtlsAuth) transport(base *http.Transport) (http.RoundTripper, error) {
+	cert, err := tls.LoadX509KeyPair(a.certPath, a.keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+	base.TLSClientConfig.Certificates = append(base.TLSClientConfig.Certificates, cert)
+	return base, nil
+}
+
+// awsSigV4Auth signs every request with the AWS credentials, see AWSSigningTransport
+type awsSigV4Auth struct {
+	credentials *credentials.Credentials
+	region      string
+	service     string
+}
+
+func (a awsSigV4Auth) transport(base *http.Transport) (http.RoundTripper, error) {
+	if a.credentials == nil {
+		return nil, errors.New("aws-sigv4 authentication requires AWS credentials")
+	}
+	base.ForceAttemptHTTP2 = false
+	base.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	return AWSSigningTransport{
+		HTTPClient:  &http.Client{Transport: base},
+		Credentials: a.credentials,
+		Region:      a.region,
+		Service:     a.service,
+	}, nil
+}
+
+func newBaseTransport(caBundlePath string) (*http.Transport, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caBundlePath == "" {
+		return base, nil
+	}
+
+	pem, err := os.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caBundlePath)
+	}
+	base.TLSClientConfig.RootCAs = pool
+	return base, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthStrategyName(t *testing.T) {
+	assert.Equal(t, AuthNone, AuthConfig{}.StrategyName("local"))
+	assert.Equal(t, AuthAWSSigV4, AuthConfig{}.StrategyName("eu-west-1"))
+	assert.Equal(t, AuthBasic, AuthConfig{Strategy: AuthBasic}.StrategyName("eu-west-1"))
+}
+
+func TestAuthConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config AuthConfig
+		err    string
+	}{
+		{
+			name:   "Basic without password",
+			config: AuthConfig{Strategy: AuthBasic, Username: "user"},
+			err:    "basic authentication requires a username and a password",
+		},
+		{
+			name:   "API key missing",
+			config: AuthConfig{Strategy: AuthAPIKey},
+			err:    "api-key authentication requires an API key",
+		},
+		{
+			name:   "Bearer token missing",
+			config: AuthConfig{Strategy: AuthBearer},
+			err:    "bearer authentication requires a token",
+		},
+		{
+			name:   "Client key missing",
+			config: AuthConfig{Strategy: AuthMTLS, ClientCertPath: "cert.pem"},
+			err:    "mtls authentication requires a client certificate and key",
+		},
+		{
+			name:   "Unknown strategy",
+			config: AuthConfig{Strategy: "kerberos"},
+			err:    `unknown authentication strategy "kerberos"`,
+		},
+		{
+			name:   "Default strategy",
+			config: AuthConfig{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate("local")
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestAuthorizationHeader(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   AuthConfig
+		expected string
+	}{
+		{
+			name:     "None",
+			config:   AuthConfig{Strategy: AuthNone},
+			expected: "",
+		},
+		{
+			name:     "Basic",
+			config:   AuthConfig{Strategy: AuthBasic, Username: "user", Password: "secret"},
+			expected: "Basic dXNlcjpzZWNyZXQ=",
+		},
+		{
+			name:     "API key",
+			config:   AuthConfig{Strategy: AuthAPIKey, APIKey: "a2V5LWlkOmtleQ=="},
+			expected: "ApiKey a2V5LWlkOmtleQ==",
+		},
+		{
+			name:     "Bearer",
+			config:   AuthConfig{Strategy: AuthBearer, BearerToken: "token"},
+			expected: "Bearer token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received []string
+			es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = append(received, r.Header.Get("Authorization"))
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":"green"}`))
+			}))
+			defer es.Close()
+
+			ec, err := NewElasticClient("local", EsAccessConfig{esEndpoint: es.URL, auth: tc.config})
+			require.NoError(t, err)
+
+			_, err = ec.ClusterHealth().Do(context.Background())
+			require.NoError(t, err)
+
+			require.NotEmpty(t, received)
+			for _, header := range received {
+				assert.Equal(t, tc.expected, header)
+			}
+		})
+	}
+}
+
+func TestAWSSigV4RequiresCredentials(t *testing.T) {
+	_, err := NewElasticClient("eu-west-1", EsAccessConfig{esEndpoint: "http://localhost:9200"})
+	assert.EqualError(t, err, "aws-sigv4 authentication requires AWS credentials")
+}
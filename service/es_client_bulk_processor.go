@@ -2,11 +2,29 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Financial-Times/go-logger"
+	tid "github.com/Financial-Times/transactionid-utils-go"
 	"github.com/olivere/elastic/v7"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrBulkBackpressure = errors.New("too many bulk requests are queued for elasticsearch")
+
+const (
+	// maxPendingBulkRequests bounds the bulk requests kept until the first bulk processor is created
+	maxPendingBulkRequests = 10000
+
+	metricsOperation = "metrics"
+	auditOperation   = "audit"
+
+	conceptsBulkProcessor = "concepts"
+	metricsBulkProcessor  = "metrics"
 )
 
 type BulkProcessorConfig struct {
@@ -14,31 +32,377 @@ type BulkProcessorConfig struct {
 	nrOfRequests  int
 	bulkSize      int
 	flushInterval time.Duration
+	// maxInFlightBytes and maxInFlightActions bound the bulk requests queued or being committed, 0 is unlimited
+	maxInFlightBytes   int64
+	maxInFlightActions int64
+	// metrics is the config of the bulk processor of the metrics, which share the bulk processor of the concepts
+	// when it is nil
+	metrics *BulkProcessorConfig
 }
 
 func NewBulkProcessorConfig(nrWorkers int, nrOfRequests int, bulkSize int, flushInterval time.Duration) BulkProcessorConfig {
 	return BulkProcessorConfig{nrWorkers: nrWorkers, nrOfRequests: nrOfRequests, bulkSize: bulkSize, flushInterval: flushInterval}
 }
 
-func newBulkProcessor(client *elastic.Client, bulkConfig *BulkProcessorConfig) (*elastic.BulkProcessor, error) {
-	return client.BulkProcessor().Name("BackgroundWorker-1").
+// WithInFlightLimits returns the config rejecting the concepts of the bulk endpoint once the bulk requests queued or
+// being committed reach maxBytes or maxActions, 0 is unlimited
+func (c BulkProcessorConfig) WithInFlightLimits(maxBytes int64, maxActions int64) BulkProcessorConfig {
+	c.maxInFlightBytes = maxBytes
+	c.maxInFlightActions = maxActions
+	return c
+}
+
+// WithMetricsProcessor returns the config queueing the metrics in a bulk processor of their own, tuned with metrics,
+// so that a large metrics update does not delay the concepts
+func (c BulkProcessorConfig) WithMetricsProcessor(metrics BulkProcessorConfig) BulkProcessorConfig {
+	c.metrics = &metrics
+	return c
+}
+
+func bulkProcessorService(client *elastic.Client, name string, bulkConfig *BulkProcessorConfig) *elastic.BulkProcessorService {
+	return client.BulkProcessor().Name(name).
 		Workers(bulkConfig.nrWorkers).
 		BulkActions(bulkConfig.nrOfRequests).
 		BulkSize(bulkConfig.bulkSize).
 		FlushInterval(bulkConfig.flushInterval).
-		After(handleBulkFailures).
-		Do(context.Background())
+		Stats(true)
+}
+
+// closeBulkProcessor flushes and stops the bulk processor. If ctx is done before all the queued requests are committed,
+// an error reporting the number of requests that could not be flushed is returned.
+func closeBulkProcessor(ctx context.Context, bulkProcessor *elastic.BulkProcessor) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- bulkProcessor.Close()
+	}()
+
+	select {
+	case err := <-done:
+		stats := bulkProcessor.Stats()
+		log.Infof("Bulk processor closed after committing %d requests, %d succeeded and %d failed", stats.Indexed, stats.Succeeded, stats.Failed)
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %d requests could not be flushed: %v", ErrBulkProcessorFlushTimeout, queuedBulkRequests(bulkProcessor), ctx.Err())
+	}
+}
+
+func queuedBulkRequests(bulkProcessor *elastic.BulkProcessor) int64 {
+	var queued int64
+	for _, w := range bulkProcessor.Stats().Workers {
+		queued += w.Queued
+	}
+	return queued
+}
+
+// bulkPipeline queues the bulk requests in the bulk processor of the current elastic client. The requests queued
+// before a bulk processor exists are kept pending and moved to the first one.
+type bulkPipeline struct {
+	sync.RWMutex
+	name      string
+	config    *BulkProcessorConfig
+	processor *elastic.BulkProcessor
+	// current is the processor as read by the After callbacks without the lock, which may be held by a request
+	// waiting for the workers running the callbacks
+	current atomic.Pointer[elastic.BulkProcessor]
+	pending []elastic.BulkableRequest
+	closed  bool
+}
+
+func newBulkPipeline(name string, config *BulkProcessorConfig, processor *elastic.BulkProcessor) *bulkPipeline {
+	p := &bulkPipeline{name: name, config: config, processor: processor}
+	p.current.Store(processor)
+	return p
+}
+
+// add queues the request in the bulk processor, or keeps it pending until there is one. ErrNoElasticClient is
+// returned once too many requests are pending, or when there is no pipeline at all.
+func (p *bulkPipeline) add(r elastic.BulkableRequest) error {
+	if p == nil {
+		return ErrNoElasticClient
+	}
+	p.RLock()
+	if p.processor != nil {
+		p.processor.Add(r)
+		p.RUnlock()
+		return nil
+	}
+	p.RUnlock()
+
+	p.Lock()
+	defer p.Unlock()
+
+	switch {
+	case p.closed:
+		return ErrBulkProcessorClosed
+	case p.processor != nil:
+		p.processor.Add(r)
+	case len(p.pending) >= maxPendingBulkRequests:
+		return ErrNoElasticClient
+	default:
+		p.pending = append(p.pending, r)
+	}
+	return nil
+}
+
+// replace makes processor the bulk processor of the pipeline, moving the pending requests to it, and returns the
+// previous one to be closed. Once the pipeline is closed processor itself is returned.
+func (p *bulkPipeline) replace(processor *elastic.BulkProcessor) *elastic.BulkProcessor {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return processor
+	}
+	previous := p.processor
+	p.processor = processor
+	p.current.Store(processor)
+	if processor != nil && len(p.pending) > 0 {
+		log.Infof("Queueing %d requests received before the bulk processor was created", len(p.pending))
+		// the pending requests are queued under the lock, ahead of the requests that follow
+		for _, r := range p.pending {
+			processor.Add(r)
+		}
+		p.pending = nil
+	}
+	return previous
+}
+
+// replaced reports whether processor is not the bulk processor of the pipeline anymore
+func (p *bulkPipeline) replaced(processor *elastic.BulkProcessor) bool {
+	return p != nil && p.current.Load() != processor
+}
+
+// close stops queueing the requests and returns the bulk processor to be closed, if any, with the requests that
+// were still pending
+func (p *bulkPipeline) close() (*elastic.BulkProcessor, []elastic.BulkableRequest) {
+	if p == nil {
+		return nil, nil
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	processor, pending := p.processor, p.pending
+	p.processor, p.pending, p.closed = nil, nil, true
+	p.current.Store(nil)
+	return processor, pending
+}
+
+// stats returns the counts of the current bulk processor, which start again from zero with every client
+func (p *bulkPipeline) stats() BulkProcessorStats {
+	p.RLock()
+	defer p.RUnlock()
+
+	stats := BulkProcessorStats{Name: p.name, Pending: int64(len(p.pending))}
+	if p.processor == nil {
+		return stats
+	}
+	processorStats := p.processor.Stats()
+	stats.Committed = processorStats.Committed
+	stats.Indexed = processorStats.Indexed
+	stats.Succeeded = processorStats.Succeeded
+	stats.Failed = processorStats.Failed
+	stats.Queued = queuedBulkRequests(p.processor)
+	return stats
+}
+
+// BackpressureError is returned when a concept cannot be queued because the bulk requests in flight reached the limits
+type BackpressureError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("%s, retry after %v", ErrBulkBackpressure, e.RetryAfter)
+}
+
+func (e *BackpressureError) Unwrap() error {
+	return ErrBulkBackpressure
+}
+
+// BulkUsage is the size of the bulk requests of the concepts queued or being committed and their limits, with the
+// stats of every bulk processor, reported by the health details
+type BulkUsage struct {
+	InFlightBytes      int64                `json:"inFlightBytes"`
+	InFlightActions    int64                `json:"inFlightActions"`
+	MaxInFlightBytes   int64                `json:"maxInFlightBytes,omitempty"`
+	MaxInFlightActions int64                `json:"maxInFlightActions,omitempty"`
+	Processors         []BulkProcessorStats `json:"processors,omitempty"`
+}
+
+// BulkProcessorStats are the counts of a bulk processor since the current elastic client was created
+type BulkProcessorStats struct {
+	Name string `json:"name"`
+	// Committed is the number of bulk requests sent to elasticsearch, the other counts are the documents of their
+	// responses which were indexed, written or failed
+	Committed int64 `json:"committed"`
+	Indexed   int64 `json:"indexed"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	// Queued are the requests waiting in the bulk processor, Pending the ones waiting for a bulk processor
+	Queued  int64 `json:"queued"`
+	Pending int64 `json:"pending"`
+}
+
+// bulkLimiter accounts the bulk requests from the time they are queued until they are committed. A request is
+// accounted once however many times it is queued, as the failed commits are retried with the same requests.
+type bulkLimiter struct {
+	sync.Mutex
+	maxBytes   int64
+	maxActions int64
+	retryAfter time.Duration
+	requests   map[elastic.BulkableRequest]int64
+	bytes      int64
+}
+
+func newBulkLimiter(config *BulkProcessorConfig) *bulkLimiter {
+	l := &bulkLimiter{requests: make(map[elastic.BulkableRequest]int64)}
+	if config != nil {
+		l.maxBytes, l.maxActions, l.retryAfter = config.maxInFlightBytes, config.maxInFlightActions, config.flushInterval
+	}
+	return l
+}
+
+// acquire accounts the request, unless it would exceed the limits. A request is always accepted when nothing is in
+// flight, so that a single request larger than the limits is not rejected forever.
+func (l *bulkLimiter) acquire(r elastic.BulkableRequest) error {
+	if l == nil {
+		return nil
+	}
+	size := bulkRequestSize(r)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if err := l.exceeded(1, size); err != nil {
+		return err
+	}
+	l.add(r, size)
+	return nil
+}
+
+// track accounts the request without checking the limits
+func (l *bulkLimiter) track(r elastic.BulkableRequest) {
+	if l == nil {
+		return
+	}
+	size := bulkRequestSize(r)
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.add(r, size)
+}
+
+// release stops accounting the committed requests
+func (l *bulkLimiter) release(requests []elastic.BulkableRequest) {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	for _, r := range requests {
+		if size, found := l.requests[r]; found {
+			l.bytes -= size
+			delete(l.requests, r)
+		}
+	}
+}
+
+func (l *bulkLimiter) usage() BulkUsage {
+	if l == nil {
+		return BulkUsage{}
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	return BulkUsage{InFlightBytes: l.bytes, InFlightActions: int64(len(l.requests)), MaxInFlightBytes: l.maxBytes, MaxInFlightActions: l.maxActions}
+}
+
+func (l *bulkLimiter) add(r elastic.BulkableRequest, size int64) {
+	if _, found := l.requests[r]; found {
+		return
+	}
+	l.requests[r] = size
+	l.bytes += size
+}
+
+func (l *bulkLimiter) exceeded(actions int64, bytes int64) error {
+	if len(l.requests) == 0 {
+		return nil
+	}
+	if (l.maxActions > 0 && int64(len(l.requests))+actions > l.maxActions) || (l.maxBytes > 0 && l.bytes+bytes > l.maxBytes) {
+		return &BackpressureError{RetryAfter: l.retryAfter}
+	}
+	return nil
+}
+
+// bulkRequestSize returns the size of the request in the body of a bulk request
+func bulkRequestSize(r elastic.BulkableRequest) int64 {
+	lines, err := r.Source()
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, line := range lines {
+		size += int64(len(line)) + 1
+	}
+	return size
+}
+
+// bulkRequest is a bulk request with the publish it was queued by, which is logged when the request fails
+type bulkRequest struct {
+	elastic.BulkableRequest
+	transactionID string
+	conceptType   string
+	uuid          string
+	operation     string
+}
+
+// newBulkRequest returns r with the transaction ID of ctx and the concept it writes
+func newBulkRequest(ctx context.Context, r elastic.BulkableRequest, conceptType string, uuid string, operation string) *bulkRequest {
+	transactionID, err := tid.GetTransactionIDFromContext(ctx)
+	if err != nil {
+		transactionID = tidNotFound
+	}
+	return &bulkRequest{BulkableRequest: r, transactionID: transactionID, conceptType: conceptType, uuid: uuid, operation: operation}
+}
+
+// bulkRequestLog returns the log entry of the request, with its publish when it is known
+func bulkRequestLog(r elastic.BulkableRequest) *logrus.Entry {
+	br, ok := r.(*bulkRequest)
+	if !ok {
+		return log.WithField(tid.TransactionIDKey, tidNotFound).WithField("request", fmt.Sprint(r))
+	}
+	return log.WithField(tid.TransactionIDKey, br.transactionID).
+		WithField(conceptTypeField, br.conceptType).
+		WithField(uuidField, br.uuid).
+		WithField(operationField, br.operation)
 }
 
 func handleBulkFailures(executionId int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
 	if err != nil {
 		// Something went badly wrong, ES reported HTTP status outside [200,300), even after retrying
-		log.Errorf("Bulk request failed with error: %v, for the following requests: %v", err, requests)
+		for _, r := range requests {
+			bulkRequestLog(r).WithError(err).Error("Bulk request failed")
+		}
 		return // response is probably nil
 	}
 
-	for _, failedItem := range response.Failed() {
-		errorDetails := fmt.Sprintf("elastic: %s [type=%s] caused by %s, failed shard details: %v", failedItem.Error.Reason, failedItem.Error.Type, failedItem.Error.CausedBy, failedItem.Error.FailedShards)
-		log.Errorf("Concept %s with uuid %s failed with status code %v and the following details: %v", failedItem.Type, failedItem.Id, failedItem.Status, errorDetails)
+	// the items of the response are in the order of the requests
+	for i, item := range response.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status <= 299 {
+				continue
+			}
+			var r elastic.BulkableRequest
+			if i < len(requests) {
+				r = requests[i]
+			}
+			entry := bulkRequestLog(r).WithField("status", result.Status)
+			if result.Error != nil {
+				entry = entry.WithField("error", fmt.Sprintf("elastic: %s [type=%s] caused by %s, failed shard details: %v", result.Error.Reason, result.Error.Type, result.Error.CausedBy, result.Error.FailedShards))
+			}
+			entry.Errorf("Concept %s of index %s failed in the bulk request", result.Id, result.Index)
+		}
 	}
 }
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/olivere/elastic/v7"
+)
+
+// ClientFactory connects a new elastic client. The AWS credentials signing the client requests are returned along
+// with it, nil when the client does not sign them.
+type ClientFactory func() (*elastic.Client, *credentials.Credentials, error)
+
+type ClientSupervisorConfig struct {
+	// CheckInterval is how frequently the health of the current client is checked
+	CheckInterval time.Duration
+	// MaxFailures is the number of consecutive failed checks after which the client is rebuilt
+	MaxFailures int
+	// RetryInterval is how long to wait before retrying to connect a client
+	RetryInterval time.Duration
+}
+
+// ClientSupervisor connects the elastic client and keeps it usable: the client is rebuilt when its AWS credentials
+// expired and cannot be refreshed, or after repeated connection errors. Every new client is sent to the channel
+// the EsService reads its clients from.
+type ClientSupervisor struct {
+	newClient ClientFactory
+	clients   chan<- *elastic.Client
+	config    ClientSupervisorConfig
+}
+
+func NewClientSupervisor(newClient ClientFactory, clients chan<- *elastic.Client, config ClientSupervisorConfig) *ClientSupervisor {
+	return &ClientSupervisor{newClient: newClient, clients: clients, config: config}
+}
+
+// Run supervises the clients until ctx is done, then closes the clients channel.
+func (s *ClientSupervisor) Run(ctx context.Context) {
+	defer close(s.clients)
+
+	for {
+		ec, creds, ok := s.connect(ctx)
+		if !ok {
+			return
+		}
+		select {
+		case s.clients <- ec:
+		case <-ctx.Done():
+			ec.Stop()
+			return
+		}
+		if !s.watch(ctx, ec, creds) {
+			return
+		}
+		log.Warn("Rebuilding the ElasticSearch client")
+	}
+}
+
+// connect retries to create a client until it succeeds or ctx is done
+func (s *ClientSupervisor) connect(ctx context.Context) (*elastic.Client, *credentials.Credentials, bool) {
+	for {
+		ec, creds, err := s.newClient()
+		if err == nil {
+			log.Info("connected to ElasticSearch")
+			return ec, creds, true
+		}
+		log.Errorf("could not connect to ElasticSearch: %s", err.Error())
+
+		select {
+		case <-time.After(s.config.RetryInterval):
+		case <-ctx.Done():
+			return nil, nil, false
+		}
+	}
+}
+
+// watch checks the client health until the client needs to be rebuilt, returning false if ctx is done first
+func (s *ClientSupervisor) watch(ctx context.Context, ec *elastic.Client, creds *credentials.Credentials) bool {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+
+		if credentialsExpired(creds) {
+			return true
+		}
+
+		if err := s.checkConnection(ctx, ec); err != nil {
+			failures++
+			log.Errorf("ElasticSearch connection check failed (%d/%d): %v", failures, s.config.MaxFailures, err)
+			if failures >= s.config.MaxFailures {
+				return true
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+func (s *ClientSupervisor) checkConnection(ctx context.Context, ec *elastic.Client) error {
+	checkCtx, cancel := context.WithTimeout(ctx, s.config.CheckInterval)
+	defer cancel()
+
+	_, err := ec.ClusterHealth().Do(checkCtx)
+	return connectionError(err)
+}
+
+// connectionError filters out the errors the cluster responded with, only rejected credentials mean that the
+// connection is not usable. Serverless collections, for example, answer the cluster health request with a 404.
+func connectionError(err error) error {
+	var esErr *elastic.Error
+	if errors.As(err, &esErr) && esErr.Status != http.StatusUnauthorized && esErr.Status != http.StatusForbidden {
+		return nil
+	}
+	return err
+}
+
+// credentialsExpired reports whether the credentials expired and could not be refreshed by their provider
+func credentialsExpired(creds *credentials.Credentials) bool {
+	if creds == nil || !creds.IsExpired() {
+		return false
+	}
+	if _, err := creds.Get(); err != nil {
+		log.WithError(err).Error("AWS credentials expired and could not be refreshed")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSupervisorRebuildsClientAfterConnectionFailures(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_cluster/health" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer rejecting.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"green"}`))
+	}))
+	defer healthy.Close()
+
+	var connections int32
+	newClient := func() (*elastic.Client, *credentials.Credentials, error) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			return getElasticClient(t, rejecting.URL), nil, nil
+		}
+		return getElasticClient(t, healthy.URL), nil, nil
+	}
+
+	clients := make(chan *elastic.Client)
+	ctx, cancel := context.WithCancel(context.Background())
+	go NewClientSupervisor(newClient, clients, ClientSupervisorConfig{
+		CheckInterval: 10 * time.Millisecond,
+		MaxFailures:   2,
+		RetryInterval: time.Millisecond,
+	}).Run(ctx)
+
+	first := receiveClient(t, clients)
+	second := receiveClient(t, clients)
+	assert.NotSame(t, first, second, "the client should have been rebuilt")
+
+	cancel()
+	select {
+	case _, open := <-clients:
+		assert.False(t, open, "the clients channel should be closed")
+	case <-time.After(time.Second):
+		t.Fatal("the supervisor did not stop")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&connections), "the healthy client should not be rebuilt")
+}
+
+func TestClientSupervisorRetriesFailedConnections(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer es.Close()
+
+	var connections int32
+	newClient := func() (*elastic.Client, *credentials.Credentials, error) {
+		if atomic.AddInt32(&connections, 1) < 3 {
+			return nil, nil, errors.New("failed to initialize AWS session")
+		}
+		return getElasticClient(t, es.URL), nil, nil
+	}
+
+	clients := make(chan *elastic.Client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewClientSupervisor(newClient, clients, ClientSupervisorConfig{
+		CheckInterval: time.Minute,
+		MaxFailures:   1,
+		RetryInterval: time.Millisecond,
+	}).Run(ctx)
+
+	receiveClient(t, clients)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&connections))
+}
+
+func TestConnectionError(t *testing.T) {
+	assert.NoError(t, connectionError(nil))
+	assert.NoError(t, connectionError(&elastic.Error{Status: http.StatusNotFound}), "the cluster responded")
+	assert.Error(t, connectionError(&elastic.Error{Status: http.StatusForbidden}), "credentials were rejected")
+	assert.Error(t, connectionError(elastic.ErrNoClient))
+}
+
+func TestCredentialsExpired(t *testing.T) {
+	assert.False(t, credentialsExpired(nil))
+	assert.False(t, credentialsExpired(credentials.NewStaticCredentials("id", "secret", "")))
+
+	expired := credentials.NewCredentials(&credentials.ErrorProvider{Err: errors.New("token file not found"), ProviderName: "test"})
+	assert.True(t, credentialsExpired(expired))
+}
+
+func TestRequeueBulkRequests(t *testing.T) {
+	var mu sync.Mutex
+	var bulkBodies []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			return
+		}
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		mu.Lock()
+		bulkBodies = append(bulkBodies, string(body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute)
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	defer service.CloseBulkProcessor(context.Background())
+
+	replaced, err := service.newBulkProcessor(getElasticClient(t, es.URL), service.bulk)
+	require.NoError(t, err)
+	defer replaced.Close()
+
+	request := elastic.NewBulkIndexRequest().Index(indexName).Id("requeued").Doc(EsConceptModel{Id: "requeued"})
+	service.requeueBulkRequests(service.bulk, service.bulk.processor, []elastic.BulkableRequest{elastic.NewBulkIndexRequest().Index(indexName).Id("current").Doc(EsConceptModel{})})
+	service.requeueBulkRequests(service.bulk, replaced, []elastic.BulkableRequest{request})
+	require.NoError(t, flushBulkPipeline(service.bulk))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, bulkBodies, 1)
+	assert.True(t, strings.Contains(bulkBodies[0], `"requeued"`), "the requests of the replaced bulk processor should be requeued")
+	assert.False(t, strings.Contains(bulkBodies[0], `"current"`), "the current bulk processor retries its own requests")
+}
+
+func receiveClient(t *testing.T, clients chan *elastic.Client) *elastic.Client {
+	select {
+	case ec := <-clients:
+		require.NotNil(t, ec)
+		return ec
+	case <-time.After(5 * time.Second):
+		t.Fatal("no client was connected")
+		return nil
+	}
+}
+
+func TestBulkRequestsQueuedBeforeClient(t *testing.T) {
+	var mu sync.Mutex
+	var bulkBodies []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			return
+		}
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		mu.Lock()
+		bulkBodies = append(bulkBodies, string(body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute)
+	service := newEsService(make(chan *elastic.Client), indexName, &bulkProcessorConfig, nil, nil, nil, nil, nil)
+
+	written, err := service.LoadBulkData(newTestContext(), organisationsType, "pending", EsConceptModel{Id: "pending"})
+	require.NoError(t, err)
+	assert.True(t, written)
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), organisationsType, "pending", &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 1}}))
+	assert.Equal(t, int64(2), service.BulkUsage().InFlightActions)
+
+	service.setElasticClient(getElasticClient(t, es.URL))
+	defer service.CloseBulkProcessor(context.Background())
+	require.NoError(t, flushBulkPipeline(service.bulk))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, bulkBodies, 1)
+	assert.Equal(t, 2, strings.Count(bulkBodies[0], `"_id":"pending"`), "the requests queued before the client should be committed with it")
+	assert.Zero(t, service.BulkUsage().InFlightActions)
+}
+
+func TestCloseBulkProcessorWithPendingRequests(t *testing.T) {
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute)
+	service := newEsService(make(chan *elastic.Client), indexName, &bulkProcessorConfig, nil, nil, nil, nil, nil)
+
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), organisationsType, "1", &EsConceptModelPatch{Metrics: &ConceptMetrics{}}))
+
+	err := service.CloseBulkProcessor(context.Background())
+	assert.ErrorIs(t, err, ErrBulkProcessorFlushTimeout)
+	assert.Zero(t, service.BulkUsage().InFlightActions)
+	assert.ErrorIs(t, service.PatchUpdateConcept(context.Background(), organisationsType, "1", &EsConceptModelPatch{Metrics: &ConceptMetrics{}}), ErrBulkProcessorClosed)
+}
+
+func TestBulkRequestsWithoutBulkProcessor(t *testing.T) {
+	service := &esService{indexName: indexName, getCurrentTime: time.Now}
+
+	_, err := service.LoadBulkData(newTestContext(), organisationsType, "1", EsConceptModel{Id: "1"})
+	assert.ErrorIs(t, err, ErrNoElasticClient)
+	assert.ErrorIs(t, service.PatchUpdateConcept(context.Background(), organisationsType, "1", &EsConceptModelPatch{Metrics: &ConceptMetrics{}}), ErrNoElasticClient)
+}
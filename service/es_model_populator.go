@@ -1,6 +1,9 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -18,6 +21,12 @@ const (
 )
 
 func ConvertConceptToESConceptModel(concept ConceptModel, conceptType, publishRef, publicAPIHost string) (EsModel, error) {
+	return ConvertConcept(DefaultConverter(conceptType), concept, conceptType, publishRef, publicAPIHost, concept.LastModified())
+}
+
+// ConvertConcept converts the concept with the given converter, lastModified is when the concept was modified upstream
+// and the conversion time is used when it is zero
+func ConvertConcept(converter string, concept ConceptModel, conceptType, publishRef, publicAPIHost string, lastModified time.Time) (EsModel, error) {
 	esModel, err := newESConceptModel(
 		concept.UUID,
 		conceptType,
@@ -29,14 +38,15 @@ func ConvertConceptToESConceptModel(concept ConceptModel, conceptType, publishRe
 		concept.Aliases,
 		concept.GetAuthorities(),
 		concept.IsDeprecated,
-		nil)
+		nil,
+		lastModified)
 
 	if err != nil {
 		return nil, err
 	}
 
-	switch conceptType {
-	case person: // person type should not come through as the old model.
+	switch converter {
+	case PersonConverter: // person type should not come through as the old model.
 		esPersonModel := &EsPersonConceptModel{
 			EsConceptModel: esModel,
 		}
@@ -47,12 +57,18 @@ func ConvertConceptToESConceptModel(concept ConceptModel, conceptType, publishRe
 }
 
 func ConvertAggregateConceptToESConceptModel(concept AggregateConceptModel, conceptType, publishRef, publicAPIHost string) (EsModel, error) {
+	return ConvertAggregateConcept(DefaultConverter(conceptType), concept, conceptType, publishRef, publicAPIHost, concept.LastModified())
+}
+
+// ConvertAggregateConcept converts the aggregate concept with the given converter, lastModified is when the concept was
+// modified upstream and the conversion time is used when it is zero
+func ConvertAggregateConcept(converter string, concept AggregateConceptModel, conceptType, publishRef, publicAPIHost string, lastModified time.Time) (EsModel, error) {
 	var esModel EsModel
 	var esConceptModel *EsConceptModel
 	var err error
 
-	switch conceptType {
-	case memberships:
+	switch converter {
+	case MembershipConverter:
 		if len(concept.PersonUUID) != 1 || len(concept.OrganisationUUID) != 1 {
 			return nil, fmt.Errorf("ambiguous membership concept '%s', it has more than one HAS_MEMBER or HAS_ORGANISATION relationships", concept.PreferredUUID())
 		}
@@ -66,30 +82,31 @@ func ConvertAggregateConceptToESConceptModel(concept AggregateConceptModel, conc
 			OrganisationId: concept.OrganisationUUID[0],
 			Memberships:    ms,
 		}
-	case person:
-		esConceptModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost)
+	case PersonConverter:
+		esConceptModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost, lastModified)
 
 		esModel = &EsPersonConceptModel{
 			EsConceptModel: esConceptModel,
 			IsFTAuthor:     defaultIsFTAuthor, // default as controlled by memberships concept
 		}
-	case organisation:
-		esConceptModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost)
+	case OrganisationConverter:
+		esConceptModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost, lastModified)
 
-		if concept.DirectType == directTypePublicCompany {
+		if err == nil && concept.DirectType == directTypePublicCompany {
 			esConceptModel.CountryCode = concept.CountryCode
 			esConceptModel.CountryOfIncorporation = concept.CountryOfIncorporation
+			esConceptModel.updateContentHash()
 		}
 		esModel = esConceptModel
 	default:
-		esModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost)
+		esModel, err = getEsConcept(concept, conceptType, publishRef, publicAPIHost, lastModified)
 	}
 
 	return esModel, err
 }
 
-func getEsConcept(concept AggregateConceptModel, conceptType, publishRef, publicAPIHost string) (*EsConceptModel, error) {
-	return newESConceptModel(
+func getEsConcept(concept AggregateConceptModel, conceptType, publishRef, publicAPIHost string, lastModified time.Time) (*EsConceptModel, error) {
+	esModel, err := newESConceptModel(
 		concept.PrefUUID,
 		conceptType,
 		concept.DirectType,
@@ -101,10 +118,17 @@ func getEsConcept(concept AggregateConceptModel, conceptType, publishRef, public
 		concept.GetAuthorities(),
 		concept.IsDeprecated,
 		concept.NAICS,
+		lastModified,
 	)
+	if err != nil {
+		return nil, err
+	}
+	esModel.AggregateHash = concept.AggregateHash
+	esModel.updateContentHash()
+	return esModel, nil
 }
 
-func newESConceptModel(uuid, conceptType, directType, prefLabel, publishRef, scopeNote, publicAPIHost string, aliases, authorities []string, isDeprecated bool, naics []NAICS) (*EsConceptModel, error) {
+func newESConceptModel(uuid, conceptType, directType, prefLabel, publishRef, scopeNote, publicAPIHost string, aliases, authorities []string, isDeprecated bool, naics []NAICS, lastModified time.Time) (*EsConceptModel, error) {
 	apiURL, err := ontology.APIURL(uuid, []string{directType}, publicAPIHost)
 	if err != nil {
 		return nil, err
@@ -140,7 +164,13 @@ func newESConceptModel(uuid, conceptType, directType, prefLabel, publishRef, sco
 	esModel.Aliases = aliases
 	esModel.PrefLabel = prefLabel
 	esModel.Authorities = authorities
-	esModel.LastModified = time.Now().Format(time.RFC3339)
+	indexedAt := time.Now()
+	if lastModified.IsZero() {
+		lastModified = indexedAt
+		esModel.lastModifiedUnknown = true
+	}
+	esModel.LastModified = lastModified.Format(time.RFC3339)
+	esModel.IndexedAt = indexedAt.Format(time.RFC3339)
 	esModel.PublishReference = publishRef
 	esModel.IsDeprecated = isDeprecated
 	esModel.ScopeNote = scopeNote
@@ -148,10 +178,71 @@ func newESConceptModel(uuid, conceptType, directType, prefLabel, publishRef, sco
 	if conceptType == organisation {
 		esModel.NAICS = naics
 	}
+	esModel.updateContentHash()
 
 	return esModel, nil
 }
 
+// updateContentHash hashes the fields of the concept sourced from its payload, which leaves out the publish
+// reference and the modification and indexing dates of every write as well as the metrics, so that republishing an
+// identical concept can be detected
+func (m *EsConceptModel) updateContentHash() {
+	content := *m
+	content.LastModified = ""
+	content.IndexedAt = ""
+	content.PublishReference = ""
+	content.Metrics = nil
+	content.ContentHash = ""
+
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	m.ContentHash = hex.EncodeToString(sum[:])
+}
+
+// upstreamLastModified returns when the concept was last modified upstream, false if the payload had no lastModified
+func (m *EsConceptModel) upstreamLastModified() (time.Time, bool) {
+	if m.lastModifiedUnknown {
+		return time.Time{}, false
+	}
+	lastModified, err := time.Parse(time.RFC3339, m.LastModified)
+	return lastModified, err == nil
+}
+
+// conceptModel returns the concept fields of the document, nil if it is not a concept document
+func conceptModel(payload interface{}) *EsConceptModel {
+	switch m := payload.(type) {
+	case *EsConceptModel:
+		return m
+	case EsConceptModel:
+		return &m
+	case *EsPersonConceptModel:
+		return m.EsConceptModel
+	case EsPersonConceptModel:
+		return m.EsConceptModel
+	}
+	return nil
+}
+
+// contentHash returns the content hash of the concept document, empty if it has none
+func contentHash(payload interface{}) string {
+	if esModel := conceptModel(payload); esModel != nil {
+		return esModel.ContentHash
+	}
+	return ""
+}
+
+// unchanged reports whether the stored document has the content hash of the concept document
+func unchanged(stored json.RawMessage, payload interface{}) bool {
+	hash := contentHash(payload)
+	if hash == "" || len(stored) == 0 {
+		return false
+	}
+	doc := struct {
+		ContentHash string `json:"contentHash"`
+	}{}
+	return json.Unmarshal(stored, &doc) == nil && doc.ContentHash == hash
+}
+
 func reverse(strings []string) []string {
 	if strings == nil {
 		return nil
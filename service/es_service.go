@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -18,7 +22,10 @@ import (
 )
 
 var (
-	ErrNoElasticClient = errors.New("no ElasticSearch client available")
+	ErrNoElasticClient           = errors.New("no ElasticSearch client available")
+	ErrBulkProcessorFlushTimeout = errors.New("bulk processor was not flushed before the deadline")
+	ErrBulkProcessorClosed       = errors.New("bulk processor is closed")
+	ErrDuplicateConcept          = errors.New("the concept is stored in more than one index")
 )
 
 const (
@@ -36,33 +43,85 @@ const (
 	columnistUUID      = "7ef75a6a-b6bf-4eb7-a1da-03e0acabef1b"
 	journalistUUID     = "33ee38a4-c677-4952-a141-2ae14da3aedd"
 	notFoundResult     = "not_found"
-	allConceptsAlias   = "all-concepts"
+	// UnchangedResult is the result of writing a concept identical to the stored one, which is skipped
+	UnchangedResult  = "unchanged"
+	allConceptsAlias = "all-concepts"
+	// scrollClearTimeout bounds the release of a scroll context, which happens after the request context is done
+	scrollClearTimeout = 10 * time.Second
 )
 
 type esService struct {
 	sync.RWMutex
-	elasticClient       *elastic.Client
-	bulkProcessor       *elastic.BulkProcessor
+	elasticClient *elastic.Client
+	bulk          *bulkPipeline
+	// metricsBulk queues the metrics apart from the concepts when it is set
+	metricsBulk         *bulkPipeline
 	indexName           string
 	bulkProcessorConfig *BulkProcessorConfig
 	getCurrentTime      func() time.Time
+	conceptTypes        *ConceptTypes
+	// pointInTime is set when the backend supports scans in a point in time
+	pointInTime bool
+	// tombstones enables soft deletes when set
+	tombstones *TombstoneConfig
+	// audit enables the audit trail of the concepts when set
+	audit *AuditConfig
+	// retry holds the retry policies of the synchronous calls, which are attempted once when it is nil
+	retry   *RetryConfig
+	breaker *circuitBreaker
+	// timeouts bounds the elasticsearch operations, which are left to the request context when it is nil
+	timeouts *TimeoutConfig
+	// bulkInFlight accounts the bulk requests until they are committed
+	bulkInFlight *bulkLimiter
 }
 
 type EsService interface {
-	LoadData(ctx context.Context, conceptType string, uuid string, payload EsModel) (bool, *elastic.IndexResponse, error)
-	ReadData(uuid string) (*elastic.GetResult, error)
-	DeleteData(ctx context.Context, conceptType string, uuid string) (*elastic.DeleteResponse, error)
-	LoadBulkData(uuid string, payload interface{})
+	// LoadData writes the concept, ErrPreconditionFailed is returned if the context sets an If-Match the stored
+	// concept does not match, see WithIfMatch
+	LoadData(ctx context.Context, conceptType string, uuid string, payload EsModel) (bool, *IndexResult, error)
+	// ReadData returns the stored concept, whose entity tag is GetResult.ETag
+	ReadData(ctx context.Context, uuid string) (*GetResult, error)
+	DeleteData(ctx context.Context, conceptType string, uuid string) (*DeleteResult, error)
+	// ReadTombstone returns the tombstone of a deleted concept, nil if there is none or soft deletes are disabled
+	ReadTombstone(ctx context.Context, uuid string) (*Tombstone, error)
+	LoadBulkData(ctx context.Context, conceptType string, uuid string, payload interface{}) (bool, error)
 	CleanupData(ctx context.Context, concept Concept)
-	PatchUpdateConcept(uuid string, payload PayloadPatch)
-	CloseBulkProcessor() error
-	GetClusterHealth() (*elastic.ClusterHealthResponse, error)
-	IsIndexReadOnly() (bool, string, error)
-	GetAllIDs(ctx context.Context, includeTypes bool, excludeFTPinkAuthorities bool) chan EsIDTypePair
+	// History returns the latest change records of a concept, ErrHistoryDisabled if they are not recorded
+	History(ctx context.Context, uuid string) ([]ChangeRecord, error)
+	// PatchUpdateConcept queues the update, ErrNoElasticClient is returned if it cannot be queued until a client exists
+	PatchUpdateConcept(ctx context.Context, conceptType string, uuid string, payload PayloadPatch) error
+	CloseBulkProcessor(ctx context.Context) error
+	GetClusterHealth(ctx context.Context) (*ClusterHealth, error)
+	IsIndexReadOnly(ctx context.Context) (bool, string, error)
+	GetAllIDs(ctx context.Context, scan IDScan) (chan IDScanPage, error)
+	// ExportConcepts streams the concepts selected by the filter, an export interrupted by an error ends with it
+	ExportConcepts(ctx context.Context, filter ExportFilter) (chan ExportedConcept, error)
+	CountConcepts(ctx context.Context, filter ConceptFilter) (int64, error)
+	// CircuitBreaker returns the state of the circuit breaker of the elasticsearch calls
+	CircuitBreaker() CircuitBreakerState
+	// BulkUsage returns the size of the bulk requests in flight and their limits
+	BulkUsage() BulkUsage
+	// PlanWrite returns the actions of writing the concept, with LoadBulkData when bulk is set, without writing it
+	PlanWrite(ctx context.Context, conceptType string, concept Concept, payload EsModel, bulk bool) ([]PlannedAction, error)
 }
 
-func NewEsService(ch chan *elastic.Client, indexName string, bulkProcessorConfig *BulkProcessorConfig) EsService {
-	es := &esService{bulkProcessorConfig: bulkProcessorConfig, indexName: indexName, getCurrentTime: time.Now}
+// NewEsService returns an EsService reading the concepts from indexName and writing them to the index of their
+// concept type, or indexName when the concept type has no index of its own. Deleted concepts leave a tombstone when
+// tombstones is not nil, and the changes of the concepts are recorded when audit is not nil. The synchronous calls are
+// retried with the policies of retry while the cluster is overloaded, and bounded by the timeouts.
+func NewEsService(ch chan *elastic.Client, indexName string, bulkProcessorConfig *BulkProcessorConfig, conceptTypes *ConceptTypes, tombstones *TombstoneConfig, audit *AuditConfig, retry *RetryConfig, timeouts *TimeoutConfig) EsService {
+	return newEsService(ch, indexName, bulkProcessorConfig, conceptTypes, tombstones, audit, retry, timeouts)
+}
+
+func newEsService(ch chan *elastic.Client, indexName string, bulkProcessorConfig *BulkProcessorConfig, conceptTypes *ConceptTypes, tombstones *TombstoneConfig, audit *AuditConfig, retry *RetryConfig, timeouts *TimeoutConfig) *esService {
+	es := &esService{bulkProcessorConfig: bulkProcessorConfig, indexName: indexName, getCurrentTime: time.Now, conceptTypes: conceptTypes, pointInTime: true, tombstones: tombstones, audit: audit,
+		retry: retry, breaker: newCircuitBreaker(retry), timeouts: timeouts, bulkInFlight: newBulkLimiter(bulkProcessorConfig)}
+	if bulkProcessorConfig != nil {
+		es.bulk = newBulkPipeline(conceptsBulkProcessor, bulkProcessorConfig, nil)
+		if bulkProcessorConfig.metrics != nil {
+			es.metricsBulk = newBulkPipeline(metricsBulkProcessor, bulkProcessorConfig.metrics, nil)
+		}
+	}
 	go func() {
 		for ec := range ch {
 			es.setElasticClient(ec)
@@ -71,70 +130,205 @@ func NewEsService(ch chan *elastic.Client, indexName string, bulkProcessorConfig
 	return es
 }
 
+// setElasticClient replaces the elastic client and the bulk processors. The requests queued in the previous bulk
+// processors are committed with the previous client and requeued in the new bulk processors if that fails, so
+// that a client rebuilt after a credential expiry or connection errors does not drop them. If a new bulk processor
+// cannot be created its requests are kept pending until the next client.
 func (es *esService) setElasticClient(ec *elastic.Client) {
 	es.Lock()
-	defer es.Unlock()
-
+	previousClient := es.elasticClient
 	es.elasticClient = ec
+	es.Unlock()
 
-	if es.bulkProcessor != nil {
-		err := es.CloseBulkProcessor()
+	var previousBulkProcessors []*elastic.BulkProcessor
+	for _, pipeline := range es.bulkPipelines() {
+		bulkProcessor, err := es.newBulkProcessor(ec, pipeline)
 		if err != nil {
-			log.Errorf("Error closing bulk processor: %v", err)
+			log.Errorf("Creating %s bulk processor failed with error=[%v]", pipeline.name, err)
+			bulkProcessor = nil
+		}
+		if previous := pipeline.replace(bulkProcessor); previous != nil {
+			previousBulkProcessors = append(previousBulkProcessors, previous)
 		}
 	}
 
-	if es.bulkProcessorConfig != nil {
-		bulkProcessor, err := newBulkProcessor(ec, es.bulkProcessorConfig)
+	// closing flushes the queued requests, which may requeue them and therefore must not happen under a lock
+	for _, previousBulkProcessor := range previousBulkProcessors {
+		err := previousBulkProcessor.Close()
 		if err != nil {
-			log.Errorf("Creating bulk processor failed with error=[%v]", err)
+			log.Errorf("Error closing bulk processor: %v", err)
 		}
-		es.bulkProcessor = bulkProcessor
+	}
+	if previousClient != nil && previousClient != ec {
+		previousClient.Stop()
 	}
 }
 
-func (es *esService) GetClusterHealth() (*elastic.ClusterHealthResponse, error) {
-	es.RLock()
-	defer es.RUnlock()
+// bulkPipelines returns the bulk pipelines of the concepts and, when they have their own, of the metrics
+func (es *esService) bulkPipelines() []*bulkPipeline {
+	var pipelines []*bulkPipeline
+	for _, pipeline := range []*bulkPipeline{es.bulk, es.metricsBulk} {
+		if pipeline != nil {
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+	return pipelines
+}
 
-	if err := es.checkElasticClient(); err != nil {
+func (es *esService) newBulkProcessor(ec *elastic.Client, pipeline *bulkPipeline) (*elastic.BulkProcessor, error) {
+	var self atomic.Pointer[elastic.BulkProcessor]
+	bulkProcessor, err := bulkProcessorService(ec, pipeline.name, pipeline.config).
+		After(func(executionId int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+			handleBulkFailures(executionId, requests, response, err)
+			if err != nil {
+				es.requeueBulkRequests(pipeline, self.Load(), requests)
+				return
+			}
+			es.bulkInFlight.release(requests)
+		}).
+		Do(context.Background())
+	self.Store(bulkProcessor)
+	return bulkProcessor, err
+}
+
+// requeueBulkRequests adds the requests of a failed commit to the current bulk processor of the pipeline if the one
+// that failed to commit them has been replaced meanwhile. The current bulk processor retries its own failed commits.
+func (es *esService) requeueBulkRequests(pipeline *bulkPipeline, failed *elastic.BulkProcessor, requests []elastic.BulkableRequest) {
+	if !pipeline.replaced(failed) {
+		return
+	}
+	log.Infof("Requeueing %d requests of a replaced %s bulk processor", len(requests), pipeline.name)
+	for _, r := range requests {
+		if err := pipeline.add(r); err != nil {
+			log.WithError(err).Errorf("Dropping a request of a replaced bulk processor: %v", r)
+			es.bulkInFlight.release([]elastic.BulkableRequest{r})
+		}
+	}
+}
+
+// readIndex returns the index or alias the concepts are read from. Once concept types are written to indices of
+// their own, the concepts are only found across all of them through the all-concepts alias.
+func (es *esService) readIndex() string {
+	for _, index := range es.conceptTypes.Indices() {
+		if index != es.indexName {
+			return allConceptsAlias
+		}
+	}
+	return es.indexName
+}
+
+// writeIndices returns all the indices the concepts are written to
+func (es *esService) writeIndices() []string {
+	indices := []string{es.indexName}
+	for _, index := range es.conceptTypes.Indices() {
+		if index != es.indexName {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+// writeIndex returns the index the concepts of the given type are written to
+func (es *esService) writeIndex(conceptType string) string {
+	if es.conceptTypes.Converter(conceptType) == MembershipConverter {
+		conceptType = es.conceptTypes.PersonType() // memberships are written into the person they belong to
+	}
+	if index := es.conceptTypes.Index(conceptType); index != "" {
+		return index
+	}
+	return es.indexName
+}
+
+func (es *esService) GetClusterHealth(ctx context.Context) (*ClusterHealth, error) {
+	ctx, cancel := es.withDeadline(ctx, healthOperation)
+	defer cancel()
+
+	client, err := es.client()
+	if err != nil {
 		return nil, err
 	}
 
-	return es.elasticClient.ClusterHealth().Do(context.Background())
+	var resp *elastic.ClusterHealthResponse
+	err = es.do(ctx, healthOperation, func() (err error) {
+		resp, err = client.ClusterHealth().Do(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterHealth{
+		ClusterName:                    resp.ClusterName,
+		Status:                         resp.Status,
+		TimedOut:                       resp.TimedOut,
+		NumberOfNodes:                  resp.NumberOfNodes,
+		NumberOfDataNodes:              resp.NumberOfDataNodes,
+		ActivePrimaryShards:            resp.ActivePrimaryShards,
+		ActiveShards:                   resp.ActiveShards,
+		RelocatingShards:               resp.RelocatingShards,
+		InitializingShards:             resp.InitializingShards,
+		UnassignedShards:               resp.UnassignedShards,
+		DelayedUnassignedShards:        resp.DelayedUnassignedShards,
+		NumberOfPendingTasks:           resp.NumberOfPendingTasks,
+		NumberOfInFlightFetch:          resp.NumberOfInFlightFetch,
+		TaskMaxWaitTimeInQueueInMillis: resp.TaskMaxWaitTimeInQueueInMillis,
+		ActiveShardsPercentAsNumber:    resp.ActiveShardsPercentAsNumber,
+	}, nil
 }
 
-func (es *esService) IsIndexReadOnly() (bool, string, error) {
-	es.RLock()
-	defer es.RUnlock()
+func (es *esService) IsIndexReadOnly(ctx context.Context) (bool, string, error) {
+	ctx, cancel := es.withDeadline(ctx, healthOperation)
+	defer cancel()
 
-	if err := es.checkElasticClient(); err != nil {
+	client, err := es.client()
+	if err != nil {
 		return false, "", err
 	}
 
-	resp, err := es.elasticClient.IndexGetSettings(es.indexName).Do(context.Background())
+	var resp map[string]*elastic.IndicesGetSettingsResponse
+	err = es.do(ctx, healthOperation, func() (err error) {
+		resp, err = client.IndexGetSettings(es.writeIndices()...).Do(ctx)
+		return err
+	})
 	if err != nil {
 		return false, "", err
 	}
 
-	for k, v := range resp {
-		readOnly, err := es.isIndexReadOnly(v.Settings)
-		return readOnly, k, err
+	return readOnlyIndex(resp, es.isIndexReadOnly)
+}
+
+// readOnlyIndex returns the first read-only index, or the names of all the indices if they are all writable
+func readOnlyIndex(resp map[string]*elastic.IndicesGetSettingsResponse, isReadOnly func(settings map[string]interface{}) (bool, error)) (bool, string, error) {
+	if len(resp) == 0 {
+		return false, "", errors.New("no index settings found")
+	}
+
+	names := make([]string, 0, len(resp))
+	for name := range resp {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	return false, "", errors.New("no index settings found")
+	for _, name := range names {
+		readOnly, err := isReadOnly(resp[name].Settings)
+		if err != nil || readOnly {
+			return readOnly, name, err
+		}
+	}
+	return false, strings.Join(names, ", "), nil
 }
 
+// isIndexReadOnly reads the index.blocks.write setting, which OpenSearch may omit or return as a boolean
 func (es *esService) isIndexReadOnly(settings map[string]interface{}) (bool, error) {
-	indexSettings := settings["index"].(map[string]interface{})
-	if block, hasBlockSetting := indexSettings["blocks"]; hasBlockSetting {
-		if writeBlocked, hasWriteBlockSetting := block.(map[string]interface{})["write"]; hasWriteBlockSetting {
-			readOnly, err := strconv.ParseBool(writeBlocked.(string))
-			return readOnly, err
-		}
+	indexSettings, _ := settings["index"].(map[string]interface{})
+	blocks, _ := indexSettings["blocks"].(map[string]interface{})
+	switch writeBlocked := blocks["write"].(type) {
+	case bool:
+		return writeBlocked, nil
+	case string:
+		return strconv.ParseBool(writeBlocked)
+	default:
+		return false, nil
 	}
-
-	return false, nil
 }
 
 func isFtAuthor(memberships []string) bool {
@@ -147,7 +341,7 @@ func isFtAuthor(memberships []string) bool {
 }
 
 func (es *esService) LoadData(ctx context.Context, conceptType string, uuid string, payload EsModel) (
-	updated bool, resp *elastic.IndexResponse, err error) {
+	updated bool, resp *IndexResult, err error) {
 
 	loadDataLog := log.WithField(conceptTypeField, conceptType).
 		WithField(uuidField, uuid).
@@ -160,66 +354,140 @@ func (es *esService) LoadData(ctx context.Context, conceptType string, uuid stri
 	}
 	loadDataLog = loadDataLog.WithField(tid.TransactionIDKey, transactionID)
 
-	es.RLock()
-	defer es.RUnlock()
+	ctx, cancel := es.withDeadline(ctx, writeOperation)
+	defer cancel()
 
-	if err = es.checkElasticClient(); err != nil {
+	client, err := es.client()
+	if err != nil {
 		loadDataLog.WithError(err).WithField(statusField, unknownStatus).Error("Failed operation to Elasticsearch")
 		return updated, resp, err
 	}
+	if err = es.breaker.check(); err != nil {
+		loadDataLog.WithError(err).WithField(statusField, unknownStatus).Warn("Failed operation to Elasticsearch")
+		return updated, resp, err
+	}
+
+	converter := es.conceptTypes.Converter(conceptType)
+	isMembership := converter == MembershipConverter
 
 	// Check if membership is FT
-	if conceptType == memberships {
+	if isMembership {
 		emm := payload.(*EsMembershipModel)
 		if emm.OrganisationId != ftOrgUUID || len(emm.Memberships) < 1 || !isFtAuthor(emm.Memberships) { // drop as not FT Author
 			return updated, resp, err
 		}
 		uuid = emm.PersonId // membership is for person
 	}
-	readResult, err := es.ReadData(uuid)
 
-	patchData := getPatchData(err, loadDataLog, conceptType, readResult)
+	var tombstone *Tombstone
+	if !isMembership {
+		if tombstone, err = es.readTombstone(ctx, client, uuid); err != nil {
+			loadDataLog.WithError(err).WithField(statusField, unknownStatus).Error("Failed to read the tombstone of the concept")
+			return false, nil, err
+		}
+		if tombstone != nil && tombstone.rejects(ctx, payload) {
+			loadDataLog.WithField(tombstoneDeletedAtField, tombstone.DeletedAt).Warn("Rejecting the write of a concept last modified before it was deleted")
+			return false, nil, ErrConceptDeleted
+		}
+		if tombstone != nil && recreateFromContext(ctx) {
+			loadDataLog.WithField(tombstoneDeletedAtField, tombstone.DeletedAt).Info("Re-creating a deleted concept")
+		}
+	}
+	// the concept of the same type is read from its own index, which unlike the all-concepts alias is realtime
+	var readResult *GetResult
+	err = es.do(ctx, readOperation, func() (err error) {
+		readResult, err = es.getDocument(ctx, client, es.writeIndex(conceptType), uuid)
+		return err
+	})
+	if ifMatchFromContext(ctx) != "" {
+		if err != nil {
+			loadDataLog.WithError(err).WithField(statusField, unknownStatus).Error("Failed to read the concept to check its If-Match precondition")
+			return false, nil, err
+		}
+		if err = checkIfMatch(ctx, readResult); err != nil {
+			return false, nil, err
+		}
+	}
+
+	if err == nil && readResult.Found && unchanged(readResult.Source, payload) {
+		loadDataLog.Debug("Skipping the write of an unchanged concept")
+		return false, &IndexResult{Index: readResult.Index, ID: uuid, Result: UnchangedResult}, nil
+	}
+
+	patchData := getPatchData(err, loadDataLog, converter, readResult)
 
-	if readResult != nil && !readResult.Found && conceptType == memberships {
+	if readResult != nil && !readResult.Found && isMembership {
 		//we write a dummy person
+		personType := es.conceptTypes.PersonType()
+		now := es.getCurrentTime().Format(time.RFC3339)
 		p := EsPersonConceptModel{
 			EsConceptModel: &EsConceptModel{
 				Id:           uuid,
-				Type:         person,
-				LastModified: es.getCurrentTime().Format(time.RFC3339),
+				Type:         personType,
+				LastModified: now,
+				IndexedAt:    now,
 			},
 			IsFTAuthor: "true",
 		}
 		logDebugPersonData(loadDataLog, &p, "Writing a dummy person")
-		return es.writeToEs(ctx, loadDataLog, uuid, p)
+		updated, resp, err = es.writeToEs(ctx, client, loadDataLog, es.writeIndex(personType), uuid, p, nil)
+		if err == nil {
+			es.recordWrite(ctx, client, personType, uuid, nil, p)
+		}
+		return updated, resp, err
 	}
 
-	if conceptType != memberships {
-		updated, resp, err = es.writeToEs(ctx, loadDataLog, uuid, payload)
+	if !isMembership {
+		var revision *GetResult
+		if ifMatchFromContext(ctx) != "" {
+			revision = readResult
+		}
+		updated, resp, err = es.writeToEs(ctx, client, loadDataLog, es.writeIndex(conceptType), uuid, payload, revision)
+		if err == nil && tombstone != nil {
+			es.deleteTombstone(ctx, client, uuid)
+		}
+		if err == nil && readResult != nil {
+			es.recordWrite(ctx, client, conceptType, uuid, readResult.Source, payload)
+		}
 	}
 
 	//check if patchData is empty
 	if patchData != nil {
-		if conceptType == memberships {
+		if isMembership {
 			// `patchData` is for a person
 			logDebugPatchData(loadDataLog, patchData, "patch for person ")
 		} else {
 			logDebugPatchData(loadDataLog, patchData, "patch for concept ")
 		}
-		es.PatchUpdateConcept(uuid, patchData)
+		if err := es.PatchUpdateConcept(ctx, conceptType, uuid, patchData); err != nil {
+			loadDataLog.WithError(err).Warn("Failed to queue the metrics of the concept")
+		}
 		updated = true
 	}
 	return updated, resp, err
 }
 
-func (es *esService) writeToEs(ctx context.Context, loadDataLog *logrus.Entry, uuid string, payload EsModel) (updated bool, resp *elastic.IndexResponse, err error) {
+// writeToEs indexes the concept, only over the revision of the stored document when it is set so that a concurrent
+// write fails the If-Match precondition
+func (es *esService) writeToEs(ctx context.Context, client *elastic.Client, loadDataLog *logrus.Entry, index string, uuid string, payload EsModel, revision *GetResult) (updated bool, resp *IndexResult, err error) {
 	loadDataLog.Debugf("Writing: %s", uuid)
-	resp, err = es.elasticClient.Index().
-		Index(es.indexName).
-		Id(uuid).
-		BodyJson(payload).
-		Do(ctx)
+	var indexResp *elastic.IndexResponse
+	err = es.do(ctx, writeOperation, func() (err error) {
+		indexService := client.Index().
+			Index(index).
+			Id(uuid).
+			BodyJson(payload)
+		if revision != nil && revision.SeqNo != nil && revision.PrimaryTerm != nil {
+			indexService = indexService.IfSeqNo(*revision.SeqNo).IfPrimaryTerm(*revision.PrimaryTerm)
+		}
+		indexResp, err = indexService.Do(ctx)
+		return err
+	})
 
+	if revision != nil && elastic.IsConflict(err) {
+		loadDataLog.WithError(err).Warn("The concept was modified concurrently with its conditional write")
+		return false, nil, ErrPreconditionFailed
+	}
 	if err != nil {
 		status := unknownStatus
 		var esErr *elastic.Error
@@ -227,12 +495,13 @@ func (es *esService) writeToEs(ctx context.Context, loadDataLog *logrus.Entry, u
 			status = strconv.Itoa(esErr.Status)
 		}
 		loadDataLog.WithError(err).WithField(statusField, status).Error("Failed operation to Elasticsearch")
-		return false, resp, err
+		return false, nil, err
 	}
-	return true, resp, nil
+	return true, &IndexResult{Index: indexResp.Index, ID: indexResp.Id, Result: indexResp.Result, Version: indexResp.Version}, nil
 }
 
-func getPatchData(err error, loadDataLog *logrus.Entry, conceptType string, readResult *elastic.GetResult) (patchData PayloadPatch) {
+// getPatchData returns the fields of the stored document to keep after writing a concept of the given converter
+func getPatchData(err error, loadDataLog *logrus.Entry, converter string, readResult *GetResult) (patchData PayloadPatch) {
 	if err != nil {
 		loadDataLog.WithError(err).Error("Failed operation to Elasticsearch, could not retrieve current values before write")
 		return patchData
@@ -240,15 +509,15 @@ func getPatchData(err error, loadDataLog *logrus.Entry, conceptType string, read
 		//we need to write the annotation count separately as it is sourced from neo.
 		//there is a race condition between the dataload and the patchData patch this will be solved by querying for the latest patchData
 		//from neo before writing the patchData back
-		switch conceptType {
-		case person, memberships:
+		switch converter {
+		case PersonConverter, MembershipConverter:
 			esConcept := new(EsPersonConceptModel)
 			if readResult.Found {
 				if err := json.Unmarshal(readResult.Source, esConcept); err != nil {
 					loadDataLog.WithError(err).Error("Failed to read patchData from Elasticsearch")
 					return patchData
 				} else {
-					if conceptType == memberships {
+					if converter == MembershipConverter {
 						return &EsPersonConceptPatch{Metrics: esConcept.Metrics, IsFTAuthor: "true"} // we only process FT members who are FT authors
 					}
 					return &EsPersonConceptPatch{Metrics: esConcept.Metrics, IsFTAuthor: esConcept.IsFTAuthor}
@@ -276,24 +545,207 @@ func (es *esService) checkElasticClient() error {
 	return nil
 }
 
-func (es *esService) ReadData(uuid string) (*elastic.GetResult, error) {
+// client returns the current elastic client, ErrNoElasticClient if there is none. The calls of an operation use the
+// client it started with: a client replaced meanwhile is stopped by setElasticClient, which ends its health checks
+// and sniffing but still lets it answer them.
+func (es *esService) client() (*elastic.Client, error) {
 	es.RLock()
 	defer es.RUnlock()
 
 	if err := es.checkElasticClient(); err != nil {
 		return nil, err
 	}
+	return es.elasticClient, nil
+}
+
+func (es *esService) ReadData(ctx context.Context, uuid string) (*GetResult, error) {
+	ctx, cancel := es.withDeadline(ctx, readOperation)
+	defer cancel()
+
+	client, err := es.client()
+	if err != nil {
+		return nil, err
+	}
+
+	indices := es.writeIndices()
+	if len(indices) == 1 {
+		var result *GetResult
+		err := es.do(ctx, readOperation, func() (err error) {
+			result, err = es.getDocument(ctx, client, indices[0], uuid)
+			return err
+		})
+		return result, err
+	}
+
+	// the concept is fetched from every index rather than searched for through the all-concepts alias, as unlike a
+	// search a multi get is realtime
+	items := make([]*elastic.MultiGetItem, 0, len(indices))
+	for _, index := range indices {
+		items = append(items, elastic.NewMultiGetItem().Index(index).Id(uuid))
+	}
+	var resp *elastic.MgetResponse
+	err = es.do(ctx, readOperation, func() (err error) {
+		resp, err = client.Mget().Realtime(true).Add(items...).Do(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := es.elasticClient.Get().
-		Index(es.indexName).
+	var found []*elastic.GetResult
+	for _, doc := range resp.Docs {
+		switch {
+		case doc.Error != nil && doc.Error.Type == "index_not_found_exception":
+		case doc.Error != nil:
+			return nil, fmt.Errorf("reading %s from %s: %s", uuid, doc.Index, doc.Error.Reason)
+		case doc.Found:
+			found = append(found, doc)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return &GetResult{Found: false}, nil
+	case 1:
+		doc := found[0]
+		return &GetResult{Found: true, Index: doc.Index, Source: doc.Source, SeqNo: doc.SeqNo, PrimaryTerm: doc.PrimaryTerm}, nil
+	}
+	foundIndices := make([]string, 0, len(found))
+	for _, doc := range found {
+		foundIndices = append(foundIndices, doc.Index)
+	}
+	log.WithField("indices", foundIndices).WithUUID(uuid).Error("The concept is stored in more than one index")
+	return nil, fmt.Errorf("%w: %s is in %s", ErrDuplicateConcept, uuid, strings.Join(foundIndices, ", "))
+}
+
+func (es *esService) getDocument(ctx context.Context, client *elastic.Client, index string, uuid string) (*GetResult, error) {
+	resp, err := client.Get().
+		Index(index).
 		Id(uuid).
-		Do(context.Background())
+		Do(ctx)
 
 	if elastic.IsNotFound(err) {
-		return &elastic.GetResult{Found: false}, nil
-	} else {
-		return resp, err
+		return &GetResult{Found: false}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &GetResult{Found: resp.Found, Index: resp.Index, Source: resp.Source, SeqNo: resp.SeqNo, PrimaryTerm: resp.PrimaryTerm}, nil
+}
+
+// readTombstone returns the tombstone of the concept, nil if there is none or soft deletes are disabled
+func (es *esService) readTombstone(ctx context.Context, client *elastic.Client, uuid string) (*Tombstone, error) {
+	if es.tombstones == nil {
+		return nil, nil
+	}
+
+	var result *GetResult
+	err := es.do(ctx, readOperation, func() (err error) {
+		result, err = es.getDocument(ctx, client, es.tombstones.Index, uuid)
+		return err
+	})
+	if err != nil || !result.Found {
+		return nil, err
+	}
+	tombstone := &Tombstone{}
+	if err := json.Unmarshal(result.Source, tombstone); err != nil {
+		return nil, err
+	}
+	return tombstone, nil
+}
+
+// deleteTombstone removes the tombstone of a concept written again after it was deleted
+func (es *esService) deleteTombstone(ctx context.Context, client *elastic.Client, uuid string) {
+	err := es.do(ctx, deleteOperation, func() error {
+		_, err := client.Delete().
+			Index(es.tombstones.Index).
+			Id(uuid).
+			Do(ctx)
+		return err
+	})
+	if err != nil && !elastic.IsNotFound(err) {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to remove the tombstone of a concept written again")
+	}
+}
+
+// recordWrite appends the change record of writing the concept over the stored document to the audit trail
+func (es *esService) recordWrite(ctx context.Context, client *elastic.Client, conceptType string, uuid string, before json.RawMessage, after interface{}) {
+	if es.audit == nil {
+		return
+	}
+	es.recordChange(ctx, client, es.writeRecord(ctx, conceptType, uuid, before, after))
+}
+
+func (es *esService) writeRecord(ctx context.Context, conceptType string, uuid string, before json.RawMessage, after interface{}) ChangeRecord {
+	record := newChangeRecord(ctx, conceptType, uuid, writeOperation, es.getCurrentTime())
+	changes, err := diffDocuments(before, after)
+	if err != nil {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to compare the concept with the stored one")
+	}
+	record.Changes = changes
+	return record
+}
+
+// recordChange appends the change record to the audit trail, a failure is logged but does not fail the change
+func (es *esService) recordChange(ctx context.Context, client *elastic.Client, record ChangeRecord) {
+	if es.audit == nil {
+		return
+	}
+	err := es.do(ctx, writeOperation, func() error {
+		_, err := client.Index().
+			Index(es.audit.Index).
+			BodyJson(record).
+			Do(ctx)
+		return err
+	})
+	if err != nil {
+		log.WithError(err).WithUUID(record.ConceptID).WithField(operationField, record.Operation).Warn("Failed to record the change of the concept")
+	}
+}
+
+func (es *esService) History(ctx context.Context, uuid string) ([]ChangeRecord, error) {
+	if es.audit == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	ctx, cancel := es.withDeadline(ctx, readOperation)
+	defer cancel()
+
+	client, err := es.client()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *elastic.SearchResult
+	err = es.do(ctx, readOperation, func() (err error) {
+		result, err = historyQuery(client, es.audit.Index, uuid).Do(ctx)
+		return err
+	})
+	if elastic.IsNotFound(err) {
+		return []ChangeRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]ChangeRecord, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		var record ChangeRecord
+		if err := json.Unmarshal(hit.Source, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (es *esService) ReadTombstone(ctx context.Context, uuid string) (*Tombstone, error) {
+	ctx, cancel := es.withDeadline(ctx, readOperation)
+	defer cancel()
+
+	client, err := es.client()
+	if err != nil {
+		return nil, err
 	}
+	return es.readTombstone(ctx, client, uuid)
 }
 
 func (es *esService) CleanupData(ctx context.Context, concept Concept) {
@@ -304,50 +756,94 @@ func (es *esService) CleanupData(ctx context.Context, concept Concept) {
 	}
 	cleanupDataLog = cleanupDataLog.WithTransactionID(transactionID)
 
-	conceptTypeMap, err := es.findConceptTypes(ctx, concept.ConcordedUUIDs())
+	client, err := es.client()
+	if err != nil {
+		cleanupDataLog.WithError(err).Error("Impossible to clean up the concorded concepts")
+		return
+	}
+
+	concordedConcepts, err := es.findConcepts(ctx, client, concept.ConcordedUUIDs())
 	if err != nil {
 		cleanupDataLog.WithError(err).Error("Impossible to find concorded concepts in elasticsearch")
 		return
 	}
 
-	for concordedUUID, conceptType := range conceptTypeMap {
+	var cleaned []string
+	for concordedUUID, found := range concordedConcepts {
+		conceptType := found.conceptType
 		cleanupDataLog.WithField(concordedUUIDField, concordedUUID).
 			WithField(conceptTypeField, conceptType).
 			Info("Cleaning up concorded uuids")
-		_, err := es.DeleteData(ctx, conceptType, concordedUUID)
+		// the concept is deleted from the index it was found in, which may not be the index of its type anymore
+		_, err := es.deleteData(ctx, client, found.index, conceptType, concordedUUID, TombstoneReasonConcorded)
 		if err != nil {
 			cleanupDataLog.WithError(err).WithField(concordedUUIDField, concordedUUID).
 				WithField(conceptTypeField, conceptType).
 				Error("Failed to delete concorded uuid.")
+			continue
 		}
+		cleaned = append(cleaned, concordedUUID)
 	}
-}
 
-func (es *esService) findConceptTypes(ctx context.Context, uuids []string) (map[string]string, error) {
-	if err := es.checkElasticClient(); err != nil {
-		return nil, err
+	if es.audit != nil && len(cleaned) > 0 {
+		sort.Strings(cleaned)
+		record := newChangeRecord(ctx, "", concept.PreferredUUID(), cleanupOperation, es.getCurrentTime())
+		record.ConcordedUUIDs = cleaned
+		es.recordChange(ctx, client, record)
 	}
+}
+
+// storedConcept is where a concept was found
+type storedConcept struct {
+	conceptType string
+	index       string
+}
+
+func (es *esService) findConcepts(ctx context.Context, client *elastic.Client, uuids []string) (map[string]storedConcept, error) {
+	ctx, cancel := es.withDeadline(ctx, findOperation)
+	defer cancel()
 
 	query := elastic.NewIdsQuery().Ids(uuids...)
-	result, err := es.elasticClient.Search(es.indexName).Query(query).Do(ctx)
+	var result *elastic.SearchResult
+	err := es.do(ctx, findOperation, func() (err error) {
+		result, err = client.Search(es.readIndex()).Query(query).Do(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	conceptTypeMap := make(map[string]string)
+	concepts := make(map[string]storedConcept)
 	for _, hit := range result.Hits.Hits {
 		esModel := EsConceptModel{}
 		err = json.Unmarshal(hit.Source, &esModel)
 		if err != nil {
 			return nil, err
 		}
-		conceptTypeMap[hit.Id] = esModel.Type
+		concepts[hit.Id] = storedConcept{conceptType: esModel.Type, index: hit.Index}
 	}
 
-	return conceptTypeMap, nil
+	return concepts, nil
 }
 
-func (es *esService) DeleteData(ctx context.Context, conceptType string, uuid string) (*elastic.DeleteResponse, error) {
+func (es *esService) DeleteData(ctx context.Context, conceptType string, uuid string) (*DeleteResult, error) {
+	client, err := es.client()
+	if err != nil {
+		log.WithError(err).
+			WithField(conceptTypeField, conceptType).
+			WithField(uuidField, uuid).
+			WithField(operationField, deleteOperation).
+			WithField(statusField, unknownStatus).
+			Error("Failed operation to Elasticsearch")
+		return nil, err
+	}
+	return es.deleteData(ctx, client, es.writeIndex(conceptType), conceptType, uuid, TombstoneReasonDeleted)
+}
+
+// deleteData deletes the concept from the index and leaves a tombstone for the given reason if soft deletes are
+// enabled. Only a concept that was stored leaves a tombstone, so that deleting an unknown concept does not reject its
+// first write.
+func (es *esService) deleteData(ctx context.Context, client *elastic.Client, index string, conceptType string, uuid string, reason string) (*DeleteResult, error) {
 	deleteDataLog := log.WithField(conceptTypeField, conceptType).
 		WithField(uuidField, uuid).
 		WithField(operationField, deleteOperation)
@@ -358,20 +854,38 @@ func (es *esService) DeleteData(ctx context.Context, conceptType string, uuid st
 	}
 	deleteDataLog = deleteDataLog.WithField(tid.TransactionIDKey, transactionID)
 
-	if err := es.checkElasticClient(); err != nil {
+	ctx, cancel := es.withDeadline(ctx, deleteOperation)
+	defer cancel()
+
+	if err := es.breaker.check(); err != nil {
 		deleteDataLog.WithError(err).
 			WithField(statusField, unknownStatus).
-			Error("Failed operation to Elasticsearch")
+			Warn("Failed operation to Elasticsearch")
 		return nil, err
 	}
 
-	resp, err := es.elasticClient.Delete().
-		Index(es.indexName).
-		Id(uuid).
-		Do(ctx)
+	var before *GetResult
+	if es.audit != nil {
+		err = es.do(ctx, readOperation, func() (err error) {
+			before, err = es.getDocument(ctx, client, index, uuid)
+			return err
+		})
+		if err != nil {
+			deleteDataLog.WithError(err).Warn("Failed to read the concept before deleting it, its change is not recorded")
+		}
+	}
+
+	var resp *elastic.DeleteResponse
+	err = es.do(ctx, deleteOperation, func() (err error) {
+		resp, err = client.Delete().
+			Index(index).
+			Id(uuid).
+			Do(ctx)
+		return err
+	})
 
 	if elastic.IsNotFound(err) {
-		return &elastic.DeleteResponse{Result: notFoundResult}, nil
+		return &DeleteResult{Result: notFoundResult}, nil
 	}
 
 	if err != nil {
@@ -385,73 +899,425 @@ func (es *esService) DeleteData(ctx context.Context, conceptType string, uuid st
 		deleteDataLog.WithError(err).
 			WithField(statusField, status).
 			Error("Failed operation to Elasticsearch")
+		return nil, err
 	}
 
-	return resp, err
+	if before != nil && before.Found {
+		record := newChangeRecord(ctx, conceptType, uuid, deleteOperation, es.getCurrentTime())
+		record.Changes, _ = diffDocuments(before.Source, nil)
+		es.recordChange(ctx, client, record)
+	}
+
+	if es.tombstones != nil {
+		tombstone := newTombstone(ctx, conceptType, uuid, reason, es.getCurrentTime())
+		err := es.do(ctx, deleteOperation, func() error {
+			_, err := client.Index().
+				Index(es.tombstones.Index).
+				Id(uuid).
+				BodyJson(tombstone).
+				Do(ctx)
+			return err
+		})
+		if err != nil {
+			deleteDataLog.WithError(err).
+				WithField(statusField, unknownStatus).
+				Error("Failed to write the tombstone of the deleted concept")
+			return nil, err
+		}
+	}
+	return &DeleteResult{Result: resp.Result}, nil
 }
 
-func (es *esService) LoadBulkData(uuid string, payload interface{}) {
-	r := elastic.NewBulkIndexRequest().Index(es.indexName).Id(uuid).Doc(payload)
+// LoadBulkData queues the concept in the bulk processor, unless it is identical to the stored one or was deleted after
+// it was last modified. The stored content hash is read before queueing, if it cannot be read the concept is queued
+// anyway. The concept counts in the requests in flight from the reads of the stored concept on, so that they are shed
+// with the load.
+func (es *esService) LoadBulkData(ctx context.Context, conceptType string, uuid string, payload interface{}) (bool, error) {
+	index := es.writeIndex(conceptType)
+	r := newBulkRequest(ctx, elastic.NewBulkIndexRequest().Index(index).Id(uuid).Doc(payload), conceptType, uuid, writeOperation)
 
-	es.RLock()
-	defer es.RUnlock()
+	ctx, cancel := es.withDeadline(ctx, writeOperation)
+	defer cancel()
 
-	es.bulkProcessor.Add(r)
+	if err := es.bulkInFlight.acquire(r); err != nil {
+		return false, err
+	}
+	queued, err := es.loadBulkData(ctx, r, conceptType, index, uuid, payload)
+	if !queued {
+		es.bulkInFlight.release([]elastic.BulkableRequest{r})
+	}
+	return queued, err
 }
 
-// PatchUpdateConcept updates a concept document with metrics. See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-update.html#_updates_with_a_partial_document
-func (es *esService) PatchUpdateConcept(uuid string, payload PayloadPatch) {
-	r := elastic.NewBulkUpdateRequest().Index(es.indexName).Id(uuid).Doc(payload)
+// loadBulkData checks the concept against its tombstone and stored document and queues it, reporting whether it was
+// queued. The concept is queued without the checks while there is no elastic client.
+func (es *esService) loadBulkData(ctx context.Context, r elastic.BulkableRequest, conceptType string, index string, uuid string, payload interface{}) (bool, error) {
+	client, _ := es.client()
 
-	es.RLock()
-	defer es.RUnlock()
+	var tombstone *Tombstone
+	var stored *GetResult
+	if client != nil {
+		tombstone, stored = es.lookupBulk(ctx, client, index, uuid, payload)
+	}
+	if tombstone != nil && tombstone.rejects(ctx, payload) {
+		return false, ErrConceptDeleted
+	}
+	if stored != nil && stored.Found && unchanged(stored.Source, payload) {
+		return false, nil
+	}
+
+	if err := es.bulk.add(r); err != nil {
+		return false, err
+	}
+	if tombstone != nil {
+		es.deleteTombstone(ctx, client, uuid)
+	}
+	if es.audit != nil && stored != nil {
+		record := es.writeRecord(ctx, conceptType, uuid, stored.Source, payload)
+		r := newBulkRequest(ctx, elastic.NewBulkIndexRequest().Index(es.audit.Index).Doc(record), conceptType, uuid, auditOperation)
+		if err := es.addBulkRequest(r); err != nil {
+			log.WithError(err).WithUUID(uuid).Warn("Failed to queue the change record of the concept")
+		}
+	}
+	return true, nil
+}
+
+// lookupBulk fetches the tombstone of the concept and its stored document, which a bulk write is checked against, in a
+// single multi get rather than a get each. The stored document is only fetched when the concept has a content hash or
+// its changes are recorded, in which case the whole document is read. A failed lookup is logged and returns nil, so
+// that the concept is queued anyway.
+func (es *esService) lookupBulk(ctx context.Context, client *elastic.Client, index string, uuid string, payload interface{}) (tombstone *Tombstone, stored *GetResult) {
+	var items []*elastic.MultiGetItem
+	if es.tombstones != nil {
+		items = append(items, elastic.NewMultiGetItem().Index(es.tombstones.Index).Id(uuid))
+	}
+	readStored := contentHash(payload) != "" || es.audit != nil
+	if readStored {
+		item := elastic.NewMultiGetItem().Index(index).Id(uuid)
+		if es.audit == nil {
+			item = item.FetchSource(elastic.NewFetchSourceContext(true).Include("contentHash"))
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	var resp *elastic.MgetResponse
+	err := es.do(ctx, readOperation, func() (err error) {
+		resp, err = client.Mget().Realtime(true).Add(items...).Do(ctx)
+		return err
+	})
+	if err != nil {
+		log.WithError(err).WithUUID(uuid).Warn("Failed to read the tombstone and content hash of the concept, queueing it anyway")
+		return nil, nil
+	}
+
+	for i, doc := range resp.Docs {
+		missing := doc.Error != nil && doc.Error.Type == "index_not_found_exception"
+		if doc.Error != nil && !missing {
+			log.WithField("index", doc.Index).WithUUID(uuid).Warnf("Failed to read the concept from the index, queueing it anyway: %s", doc.Error.Reason)
+			continue
+		}
+		if es.tombstones != nil && i == 0 {
+			if !doc.Found {
+				continue
+			}
+			tombstone = &Tombstone{}
+			if err := json.Unmarshal(doc.Source, tombstone); err != nil {
+				log.WithError(err).WithUUID(uuid).Warn("Failed to read the tombstone of the concept, queueing it anyway")
+				tombstone = nil
+			}
+			continue
+		}
+		stored = &GetResult{Found: doc.Found, Source: doc.Source}
+	}
+	return tombstone, stored
+}
+
+// addBulkRequest queues the request in the bulk processor whatever the requests in flight
+func (es *esService) addBulkRequest(r elastic.BulkableRequest) error {
+	es.bulkInFlight.track(r)
+	if err := es.bulk.add(r); err != nil {
+		es.bulkInFlight.release([]elastic.BulkableRequest{r})
+		return err
+	}
+	return nil
+}
+
+func (es *esService) BulkUsage() BulkUsage {
+	usage := es.bulkInFlight.usage()
+	for _, pipeline := range es.bulkPipelines() {
+		usage.Processors = append(usage.Processors, pipeline.stats())
+	}
+	return usage
+}
 
-	es.bulkProcessor.Add(r)
+// PatchUpdateConcept updates a concept document with metrics. See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-update.html#_updates_with_a_partial_document
+func (es *esService) PatchUpdateConcept(ctx context.Context, conceptType string, uuid string, payload PayloadPatch) error {
+	payload = withIndexedAt(payload, es.getCurrentTime())
+	r := newBulkRequest(ctx, elastic.NewBulkUpdateRequest().Index(es.writeIndex(conceptType)).Id(uuid).Doc(payload), conceptType, uuid, metricsOperation)
+	if es.metricsBulk == nil {
+		return es.addBulkRequest(r)
+	}
+	// the requests in flight limit the concepts only, the metrics are not accounted
+	return es.metricsBulk.add(r)
 }
 
-func (es *esService) CloseBulkProcessor() error {
-	return es.bulkProcessor.Close()
+// CloseBulkProcessor commits the queued bulk requests and stops the bulk processors, giving up once ctx is done.
+// The requests still pending because no bulk processor was ever created are reported as not flushed.
+func (es *esService) CloseBulkProcessor(ctx context.Context) error {
+	var errs []error
+	for _, pipeline := range es.bulkPipelines() {
+		bulkProcessor, pending := pipeline.close()
+		if len(pending) > 0 {
+			es.bulkInFlight.release(pending)
+			errs = append(errs, fmt.Errorf("%w: %d %s requests could not be flushed: %v", ErrBulkProcessorFlushTimeout, len(pending), pipeline.name, ErrNoElasticClient))
+			continue
+		}
+		if bulkProcessor != nil {
+			errs = append(errs, closeBulkProcessor(ctx, bulkProcessor))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (es *esService) GetAllIDs(ctx context.Context, includeTypes bool, excludeFTPinkAuthorities bool) chan EsIDTypePair {
-	ids := make(chan EsIDTypePair)
+// GetAllIDs scans the concept IDs in parallel slices of a point in time, sorted by id so that every page moves
+// the cursor of its slice with search_after. A backend without point in time scans the live index in one slice.
+// The point in time is closed once the scan ends or is cancelled; a scan failing otherwise keeps it until it expires,
+// so that the scan can be resumed from the cursor it ended with.
+func (es *esService) GetAllIDs(ctx context.Context, scan IDScan) (chan IDScanPage, error) {
+	client, err := es.client()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := newIDScanCursor(scan)
+	if err != nil {
+		return nil, err
+	}
+
+	index := es.readIndex()
+	query := scan.Filter.query()
+
+	if es.pointInTime {
+		pitCtx, cancel := es.withDeadline(ctx, scanOperation)
+		err := openPointInTime(pitCtx, client, index, cursor)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	} else if len(cursor.Slices) > 1 {
+		return nil, fmt.Errorf("%w: the backend does not support parallel scans", ErrInvalidIDScan)
+	}
 
+	state := newIDScanState(cursor)
 	go func() {
-		defer close(ids)
-		var r *elastic.ScrollService
-		if excludeFTPinkAuthorities {
-			r = elastic.NewScrollService(es.elasticClient).
-				Index(allConceptsAlias).
-				Query(elastic.NewBoolQuery().
-					MustNot(elastic.NewTermsQuery("authorities", "TME", "Smartlogic"))).
-				Sort("_doc", true).
-				Size(1000).
-				FetchSource(includeTypes)
+		defer close(state.pages)
+
+		scanCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		var once sync.Once
+		var scanErr error
+		for _, slice := range state.pendingSlices() {
+			wg.Add(1)
+			go func(slice int) {
+				defer wg.Done()
+				if err := es.scanSlice(scanCtx, client, index, query, scan, state, slice); err != nil {
+					once.Do(func() {
+						scanErr = err
+						cancel()
+					})
+				}
+			}(slice)
+		}
+		wg.Wait()
+
+		if scanErr != nil && ctx.Err() == nil {
+			log.WithError(scanErr).Error("Concept IDs scan was interrupted")
+			state.fail(scanErr, ctx.Done())
+			return
+		}
+		if ctx.Err() != nil {
+			log.WithError(ctx.Err()).Warn("Concept IDs scan was cancelled")
+		}
+		if cursor.PointInTime != "" {
+			// the point in time is closed even when ctx is done, as a cancelled scan is not resumed
+			if _, err := client.ClosePointInTime(cursor.PointInTime).Do(context.Background()); err != nil {
+				log.WithError(err).Warn("Failed to close the point in time of the concept IDs scan")
+			}
+		}
+	}()
+
+	return state.pages, nil
+}
+
+// CountConcepts counts the concepts selected by the filter
+func (es *esService) CountConcepts(ctx context.Context, filter ConceptFilter) (int64, error) {
+	client, err := es.client()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := es.withDeadline(ctx, readOperation)
+	defer cancel()
+
+	var count int64
+	err = es.do(ctx, readOperation, func() (err error) {
+		count, err = client.Count(es.readIndex()).Query(filter.query()).Do(ctx)
+		return err
+	})
+	return count, err
+}
+
+// openPointInTime opens the point in time of a new scan, or checks that the point in time of a resumed scan was not
+// released yet
+func openPointInTime(ctx context.Context, client *elastic.Client, index string, cursor *idScanCursor) error {
+	if cursor.PointInTime == "" {
+		pit, err := client.OpenPointInTime(index).KeepAlive(idScanKeepAlive).Do(ctx)
+		if err != nil {
+			return err
+		}
+		cursor.PointInTime = pit.Id
+		return nil
+	}
+
+	_, err := client.Search().
+		PointInTime(elastic.NewPointInTimeWithKeepAlive(cursor.PointInTime, idScanKeepAlive)).
+		Size(0).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrCursorExpired
+	}
+	return err
+}
+
+// scanSlice sends the pages of a slice of the scan, from its position in the cursor until it is scanned completely.
+// The fetch of each page is bounded by the scan timeout, the wait for the page to be received is not.
+func (es *esService) scanSlice(ctx context.Context, client *elastic.Client, index string, query elastic.Query, scan IDScan, state *idScanState, slice int) error {
+	after, pointInTime, slices := state.position(slice)
+	fetchSource := scan.IncludeTypes || scan.IncludeHashes
+	for {
+		source := elastic.NewSearchSource().
+			Query(query).
+			Size(idScanPageSize).
+			Sort(idScanSortField, true).
+			FetchSourceContext(elastic.NewFetchSourceContext(fetchSource).Include("type", "aggregateHash"))
+		search := client.Search()
+		if pointInTime != "" {
+			source.PointInTime(elastic.NewPointInTimeWithKeepAlive(pointInTime, idScanKeepAlive))
 		} else {
-			r = elastic.NewScrollService(es.elasticClient).
-				Index(es.indexName).
-				Query(elastic.NewMatchAllQuery()).
-				Sort("_doc", true).
-				Size(1000).
-				FetchSource(includeTypes)
+			search.Index(index)
+		}
+		if slices > 1 {
+			source.Slice(elastic.NewSliceQuery().Id(slice).Max(slices))
+		}
+		if after != nil {
+			source.SearchAfter(after...)
 		}
 
-		es.RLock()
-		defer es.RUnlock()
+		pageCtx, cancel := es.withDeadline(ctx, scanOperation)
+		res, err := search.SearchSource(source).Do(pageCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if res.PitId != "" {
+			pointInTime = res.PitId
+		}
+		if res.Hits == nil || len(res.Hits.Hits) == 0 {
+			state.sliceDone(slice)
+			return nil
+		}
 
-		var err error
-		for {
-			r, err = es.processScrollPage(ctx, r, ids, includeTypes)
-			if r == nil || err != nil {
-				return
+		ids := make([]EsIDTypePair, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			pair := EsIDTypePair{ID: hit.Id}
+			if fetchSource {
+				esModel := EsConceptModel{}
+				if err := json.Unmarshal(hit.Source, &esModel); err != nil {
+					return err
+				}
+				pair = scan.pair(hit.Id, esModel)
+			}
+			ids = append(ids, pair)
+		}
+
+		after = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+		last := len(res.Hits.Hits) < idScanPageSize
+		if !state.send(slice, ids, after, last, pointInTime, ctx.Done()) {
+			return ctx.Err()
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// ExportConcepts streams the source of the concepts selected by the filter, projected to its fields. The scroll
+// keeps the client it started with rather than the lock, which would hold back a client swap until the export is read.
+func (es *esService) ExportConcepts(ctx context.Context, filter ExportFilter) (chan ExportedConcept, error) {
+	client, err := es.client()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(chan ExportedConcept)
+	go func() {
+		defer close(docs)
+
+		r := elastic.NewScrollService(client).
+			Index(es.readIndex()).
+			Query(filter.ConceptFilter.query()).
+			Sort("_doc", true).
+			Size(1000).
+			FetchSourceContext(filter.fetchSource())
+
+		err := es.scroll(ctx, client, r, func(hit *elastic.SearchHit) error {
+			select {
+			case docs <- ExportedConcept{Source: hit.Source}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			log.WithError(err).Error("Concepts export was interrupted")
+			select {
+			case docs <- ExportedConcept{Err: err}:
+			case <-ctx.Done():
 			}
 		}
 	}()
 
-	return ids
+	return docs, nil
 }
 
-func (es *esService) processScrollPage(ctx context.Context, r *elastic.ScrollService, ch chan EsIDTypePair, includeTypes bool) (*elastic.ScrollService, error) {
+// scroll passes every hit of the scroll to handleHit, until all the pages are processed or an error occurs. The
+// scroll context is then cleared on the cluster rather than kept until it expires, also when ctx is cancelled.
+func (es *esService) scroll(ctx context.Context, client *elastic.Client, r *elastic.ScrollService, handleHit func(hit *elastic.SearchHit) error) error {
+	for {
+		next, err := es.processScrollPage(ctx, client, r, handleHit)
+		if next == nil || err != nil {
+			clearScroll(r)
+			return err
+		}
+		r = next
+	}
+}
+
+// clearScroll releases the scroll context of the last page fetched by r
+func clearScroll(r *elastic.ScrollService) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrollClearTimeout)
+	defer cancel()
+	if err := r.Clear(ctx); err != nil {
+		log.WithError(err).Warn("Failed to clear the scroll context")
+	}
+}
+
+func (es *esService) processScrollPage(ctx context.Context, client *elastic.Client, r *elastic.ScrollService, handleHit func(hit *elastic.SearchHit) error) (*elastic.ScrollService, error) {
 	res, err := r.Do(ctx)
 	if err == io.EOF {
 		return nil, nil
@@ -462,19 +1328,12 @@ func (es *esService) processScrollPage(ctx context.Context, r *elastic.ScrollSer
 
 	scrollId := res.ScrollId
 	for _, c := range res.Hits.Hits {
-		if includeTypes {
-			esModel := EsConceptModel{}
-			err = json.Unmarshal(c.Source, &esModel)
-			if err != nil {
-				return nil, err
-			}
-			ch <- EsIDTypePair{ID: c.Id, Type: esModel.Type}
-		} else {
-			ch <- EsIDTypePair{ID: c.Id}
+		if err = handleHit(c); err != nil {
+			return nil, err
 		}
 	}
 
-	return elastic.NewScrollService(es.elasticClient).ScrollId(scrollId), nil
+	return elastic.NewScrollService(client).ScrollId(scrollId), nil
 }
 
 func logDebugPatchData(log *logrus.Entry, payload PayloadPatch, msg string) {
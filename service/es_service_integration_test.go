@@ -53,10 +53,8 @@ func TestWrite(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	_, up, resp, err := writeTestDocument(service, organisationsType, testUUID)
@@ -66,7 +64,7 @@ func TestWrite(t *testing.T) {
 
 	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
 	assert.Equal(t, indexName, resp.Index, "index name")
-	assert.Equal(t, testUUID, resp.Id, "document id")
+	assert.Equal(t, testUUID, resp.ID, "document id")
 	assert.True(t, up, "updated was true")
 }
 
@@ -74,10 +72,8 @@ func TestWriteMakesPersonAnFTColumnist(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
 	op, _, _, err := writeTestPersonDocument(service, peopleType, testUUID, "false")
 	defer deleteTestDocument(t, service, peopleType, testUUID)
@@ -97,11 +93,11 @@ func TestWriteMakesPersonAnFTColumnist(t *testing.T) {
 	require.NoError(t, err, "expected successful write")
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful write")
 	assert.True(t, up, "author was updated")
 
-	p, err := service.ReadData(testUUID)
+	p, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var actual EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -116,12 +112,10 @@ func TestWriteMakesPersonAnFTJournalist(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
-	_, _, _, err = writeTestPersonDocument(service, peopleType, testUUID, "false")
+	_, _, _, err := writeTestPersonDocument(service, peopleType, testUUID, "false")
 	defer deleteTestDocument(t, service, peopleType, testUUID)
 	require.NoError(t, err, "expected successful write")
 	ctx := context.Background()
@@ -138,11 +132,11 @@ func TestWriteMakesPersonAnFTJournalist(t *testing.T) {
 	require.NoError(t, err, "expected successful write")
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful write")
 	assert.True(t, up, "Journalist updated")
 
-	p, err := service.ReadData(testUUID)
+	p, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var actual EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -160,10 +154,9 @@ func TestWriteDummyPersonWhenMembershipArrives(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, getTimeFunc}
+	service := newTestEsService(ec, &bulkProcessorConfig)
+	service.getCurrentTime = getTimeFunc
 	testUUID := uuid.New().String()
 	ctx := context.Background()
 
@@ -179,10 +172,10 @@ func TestWriteDummyPersonWhenMembershipArrives(t *testing.T) {
 	require.NoError(t, err, "expected successful write")
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful write")
 	assert.True(t, up, "Journalist updated")
-	p, err := service.ReadData(testUUID)
+	p, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var actual EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -196,10 +189,8 @@ func TestWritePersonAfterMembership(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
 	ctx := context.Background()
 
@@ -221,11 +212,11 @@ func TestWritePersonAfterMembership(t *testing.T) {
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
 
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful write")
 	assert.True(t, up, "Journalist updated")
 
-	p, err := service.ReadData(testUUID)
+	p, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var actual EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -254,7 +245,7 @@ func TestFTAuthorWriteOrder(t *testing.T) {
 	flushChangesToIndex(t, service)
 
 	var p1 EsPersonConceptModel
-	esResult, _ := service.ReadData(testUUID)
+	esResult, _ := service.ReadData(context.Background(), testUUID)
 	require.NoError(t, json.Unmarshal(esResult.Source, &p1))
 
 	deleteTestDocument(t, service, peopleType, testUUID)
@@ -266,7 +257,7 @@ func TestFTAuthorWriteOrder(t *testing.T) {
 	flushChangesToIndex(t, service)
 
 	var p2 EsPersonConceptModel
-	esResult, _ = service.ReadData(testUUID)
+	esResult, _ = service.ReadData(context.Background(), testUUID)
 	require.NoError(t, json.Unmarshal(esResult.Source, &p2))
 
 	deleteTestDocument(t, service, peopleType, testUUID)
@@ -280,12 +271,10 @@ func TestWriteMakesDoesNotMakePersonAnFTAuthor(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
-	_, _, _, err = writeTestPersonDocument(service, peopleType, testUUID, "false")
+	_, _, _, err := writeTestPersonDocument(service, peopleType, testUUID, "false")
 	defer deleteTestDocument(t, service, peopleType, testUUID)
 
 	require.NoError(t, err, "expected successful write")
@@ -331,11 +320,11 @@ func TestWriteMakesDoesNotMakePersonAnFTAuthor(t *testing.T) {
 			require.NoError(t, err, "expected successful write")
 			_, err = ec.Refresh(indexName).Do(ctx)
 			require.NoError(t, err, "expected successful flush")
-			err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+			err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 			require.NoError(t, err, "require successful write")
 			assert.False(t, up, "should not have updated person")
 
-			p, err := service.ReadData(testUUID)
+			p, err := service.ReadData(context.Background(), testUUID)
 			assert.NoError(t, err, "expected successful read")
 			var actual EsPersonConceptModel
 			assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -348,10 +337,8 @@ func TestWritePreservesPatchableDataForPerson(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	payload, _, _, err := writeTestPersonDocument(service, peopleType, testUUID, "true")
@@ -361,11 +348,11 @@ func TestWritePreservesPatchableDataForPerson(t *testing.T) {
 	ctx := context.Background()
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
-	service.PatchUpdateConcept(testUUID, &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 1234, PrevWeekAnnotationsCount: 123}})
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), peopleType, testUUID, &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 1234, PrevWeekAnnotationsCount: 123}}))
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful metrics write")
 
-	p, err := service.ReadData(testUUID)
+	p, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var previous EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &previous))
@@ -375,13 +362,13 @@ func TestWritePreservesPatchableDataForPerson(t *testing.T) {
 	payload.Metrics = nil // blank metrics
 	up, _, err := service.LoadData(ctx, peopleType, testUUID, payload)
 	require.NoError(t, err, "require successful metrics write")
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful metrics write")
 	_, err = ec.Refresh(indexName).Do(ctx)
 	require.NoError(t, err, "expected successful flush")
 	assert.True(t, up, "person should have been updated")
 
-	p, err = service.ReadData(testUUID)
+	p, err = service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful read")
 	var actual EsPersonConceptModel
 	assert.NoError(t, json.Unmarshal(p.Source, &actual))
@@ -397,27 +384,25 @@ func TestWritePreservesMetrics(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
-	_, _, _, err = writeTestDocument(service, organisationsType, testUUID)
+	_, _, _, err := writeTestDocument(service, organisationsType, testUUID)
 	defer deleteTestDocument(t, service, organisationsType, testUUID)
 
 	require.NoError(t, err, "require successful concept write")
 
 	testMetrics := &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 150000, PrevWeekAnnotationsCount: 15}}
-	service.PatchUpdateConcept(testUUID, testMetrics)
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), organisationsType, testUUID, testMetrics))
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful metrics write")
 
 	_, _, _, _ = writeTestDocument(service, organisationsType, testUUID)
-	err = service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	err = flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 	require.NoError(t, err, "require successful concept update")
 
-	actual, err := service.ReadData(testUUID)
+	actual, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err, "expected successful concept read")
 	m := make(map[string]interface{})
 	assert.NoError(t, json.Unmarshal(actual.Source, &m))
@@ -434,9 +419,9 @@ func TestWritePreservesMetrics(t *testing.T) {
 func TestIsReadOnly(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	service := &esService{sync.RWMutex{}, ec, nil, indexName, nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: indexName, getCurrentTime: time.Now}
 	defer ec.Stop()
-	readOnly, name, err := service.IsIndexReadOnly()
+	readOnly, name, err := service.IsIndexReadOnly(context.Background())
 	assert.False(t, readOnly, "index should not be read-only")
 	assert.Equal(t, name, indexName, "index name should be returned")
 	assert.NoError(t, err, "read-only check should not return an error")
@@ -444,7 +429,7 @@ func TestIsReadOnly(t *testing.T) {
 	setReadOnly(t, ec, indexName, true)
 	defer setReadOnly(t, ec, indexName, false)
 
-	readOnly, name, err = service.IsIndexReadOnly()
+	readOnly, name, err = service.IsIndexReadOnly(context.Background())
 	assert.True(t, readOnly, "index should be read-only")
 	assert.Equal(t, name, indexName, "index name should be returned")
 	assert.NoError(t, err, "read-only check should not return an error")
@@ -453,9 +438,9 @@ func TestIsReadOnly(t *testing.T) {
 func TestIsReadOnlyIndexNotFound(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	service := &esService{sync.RWMutex{}, ec, nil, "foo", nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: "foo", getCurrentTime: time.Now}
 	defer ec.Stop()
-	readOnly, name, err := service.IsIndexReadOnly()
+	readOnly, name, err := service.IsIndexReadOnly(context.Background())
 	assert.False(t, readOnly, "index should not be read-only")
 	assert.Empty(t, name, "no index name should be returned")
 	assert.Error(t, err, "index should not be found")
@@ -465,10 +450,8 @@ func TestRead(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	defer ec.Stop()
 
 	testUUID := uuid.New().String()
@@ -479,7 +462,7 @@ func TestRead(t *testing.T) {
 	_, err = ec.Refresh(indexName).Do(context.Background())
 	require.NoError(t, err, "expected successful flush")
 
-	resp, err := service.ReadData(testUUID)
+	resp, err := service.ReadData(context.Background(), testUUID)
 
 	assert.NoError(t, err, "expected no error for ES read")
 	assert.True(t, resp.Found, "should find a result")
@@ -497,7 +480,7 @@ func TestPassClientThroughChannel(t *testing.T) {
 	ecc := make(chan *elastic.Client)
 	defer close(ecc)
 
-	service := NewEsService(ecc, indexName, &bulkProcessorConfig)
+	service := NewEsService(ecc, indexName, &bulkProcessorConfig, nil, nil, nil, nil, nil)
 
 	ec := getElasticClient(t, esURL)
 
@@ -512,7 +495,7 @@ func TestPassClientThroughChannel(t *testing.T) {
 
 	assert.NoError(t, err, "expected successful write")
 
-	resp, err := service.ReadData(testUUID)
+	resp, err := service.ReadData(context.Background(), testUUID)
 
 	assert.NoError(t, err, "expected no error for ES read")
 	assert.True(t, resp.Found, "should find a result")
@@ -530,10 +513,8 @@ func TestDelete(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	_, _, resp, err := writeTestDocument(service, organisationsType, testUUID)
@@ -542,13 +523,13 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
 	assert.Equal(t, indexName, resp.Index, "index name")
 	//	assert.Equal(t, organisationsType, resp.Type, "concept type")
-	assert.Equal(t, testUUID, resp.Id, "document id")
+	assert.Equal(t, testUUID, resp.ID, "document id")
 
 	deleteResp, err := service.DeleteData(newTestContext(), organisationsType, testUUID)
 	require.NoError(t, err)
 	assert.Equal(t, esStatusDeleted, deleteResp.Result, "document is deleted")
 
-	getResp, err := service.ReadData(testUUID)
+	getResp, err := service.ReadData(context.Background(), testUUID)
 	assert.NoError(t, err)
 	assert.False(t, getResp.Found)
 }
@@ -563,12 +544,12 @@ func TestDeleteNotFoundConcept(t *testing.T) {
 	)
 	assert.NoError(t, err, "expected no error for ES client")
 
-	service := &esService{sync.RWMutex{}, ec, nil, indexName, nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: indexName, getCurrentTime: time.Now}
 
 	testUUID := uuid.New().String()
 	resp, _ := service.DeleteData(newTestContext(), organisationsType+"s", testUUID)
 
-	assert.False(t, resp.ForcedRefresh, "document is not found")
+	assert.Equal(t, notFoundResult, resp.Result, "document is not found")
 
 	assert.Empty(t, hook.AllEntries(), "It logged nothing")
 }
@@ -578,10 +559,8 @@ func TestCleanup(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID1 := uuid.New().String()
 	_, _, resp, err := writeTestDocument(service, organisationsType, testUUID1)
@@ -618,28 +597,74 @@ func TestCleanup(t *testing.T) {
 
 	service.CleanupData(newTestContext(), concept)
 
-	getResp, err := service.ReadData(testUUID2)
+	getResp, err := service.ReadData(context.Background(), testUUID2)
 	assert.NoError(t, err)
 	assert.False(t, getResp.Found)
 
-	getResp, err = service.ReadData(testUUID3)
+	getResp, err = service.ReadData(context.Background(), testUUID3)
 	assert.NoError(t, err)
 	assert.False(t, getResp.Found)
 
-	getResp, err = service.ReadData(testUUID1)
+	getResp, err = service.ReadData(context.Background(), testUUID1)
 	assert.NoError(t, err)
 	assert.True(t, getResp.Found)
 }
 
+func TestPerTypeIndexRouting(t *testing.T) {
+	service := getTestESService(t)
+	peopleIndex := createTestIndex(t, service.elasticClient, indexName+"-people")
+	service.conceptTypes = ConceptTypesFromList([]string{peopleType, organisationsType}, map[string]string{peopleType: peopleIndex})
+
+	personUUID := uuid.New().String()
+	_, _, resp, err := writeTestPersonDocument(service, peopleType, personUUID, "false")
+	require.NoError(t, err, "expected successful write")
+	assert.Equal(t, peopleIndex, resp.Index, "people should be written to their own index")
+
+	organisationUUID := uuid.New().String()
+	_, _, resp, err = writeTestDocument(service, organisationsType, organisationUUID)
+	require.NoError(t, err, "expected successful write")
+	assert.Equal(t, indexName, resp.Index, "organisations should be written to the default index")
+	defer deleteTestDocument(t, service, organisationsType, organisationUUID)
+
+	_, err = service.elasticClient.Refresh(indexName, peopleIndex).Do(context.Background())
+	require.NoError(t, err)
+
+	getResp, err := service.ReadData(context.Background(), personUUID)
+	require.NoError(t, err)
+	require.True(t, getResp.Found, "the person should be read through the alias")
+	assert.Equal(t, peopleIndex, getResp.Index)
+
+	pages, err := service.GetAllIDs(context.Background(), IDScan{IncludeTypes: true})
+	require.NoError(t, err)
+	ids := map[string]string{}
+	for page := range pages {
+		require.NoError(t, page.Err)
+		for _, id := range page.IDs {
+			ids[id.ID] = id.Type
+		}
+	}
+	assert.Equal(t, peopleType, ids[personUUID])
+	assert.Equal(t, organisationsType, ids[organisationUUID])
+
+	service.CleanupData(newTestContext(), AggregateConceptModel{PrefUUID: organisationUUID, SourceRepresentations: []SourceConcept{
+		{UUID: organisationUUID},
+		{UUID: personUUID},
+	}})
+
+	_, err = service.elasticClient.Refresh(peopleIndex).Do(context.Background())
+	require.NoError(t, err)
+	getResp, err = service.ReadData(context.Background(), personUUID)
+	require.NoError(t, err)
+	assert.False(t, getResp.Found, "the concorded person should be deleted from its own index")
+}
+
 func TestDeprecationFlagTrue(t *testing.T) {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
 	esURL := getElasticSearchTestURL()
 
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	payload := EsConceptModel{
@@ -661,9 +686,9 @@ func TestDeprecationFlagTrue(t *testing.T) {
 
 	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
 	assert.Equal(t, indexName, resp.Index, "index name")
-	assert.Equal(t, testUUID, resp.Id, "document id")
+	assert.Equal(t, testUUID, resp.ID, "document id")
 
-	readResp, err := service.ReadData(testUUID)
+	readResp, err := service.ReadData(context.Background(), testUUID)
 
 	assert.NoError(t, err, "expected no error for ES read")
 	assert.True(t, readResp.Found, "should find a result")
@@ -681,10 +706,8 @@ func TestDeprecationFlagFalse(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	payload := EsConceptModel{
@@ -705,9 +728,9 @@ func TestDeprecationFlagFalse(t *testing.T) {
 
 	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
 	assert.Equal(t, indexName, resp.Index, "index name")
-	assert.Equal(t, testUUID, resp.Id, "document id")
+	assert.Equal(t, testUUID, resp.ID, "document id")
 
-	readResp, err := service.ReadData(testUUID)
+	readResp, err := service.ReadData(context.Background(), testUUID)
 
 	assert.NoError(t, err, "expected no error for ES read")
 	assert.True(t, readResp.Found, "should find a result")
@@ -726,10 +749,8 @@ func TestMetricsUpdated(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	testUUID := uuid.New().String()
 	payload := EsConceptModel{
@@ -750,14 +771,14 @@ func TestMetricsUpdated(t *testing.T) {
 
 	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
 	assert.Equal(t, indexName, resp.Index, "index name")
-	assert.Equal(t, testUUID, resp.Id, "document id")
+	assert.Equal(t, testUUID, resp.ID, "document id")
 
 	testMetrics := &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 15000, PrevWeekAnnotationsCount: 150}}
-	service.PatchUpdateConcept(testUUID, testMetrics)
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), organisationsType, testUUID, testMetrics))
 
-	service.bulkProcessor.Flush() // wait for the bulk processor to write the data
+	flushBulkPipeline(service.bulk) // wait for the bulk processor to write the data
 
-	readResp, err := service.ReadData(testUUID)
+	readResp, err := service.ReadData(context.Background(), testUUID)
 
 	assert.NoError(t, err, "expected no error for ES read")
 	assert.True(t, readResp.Found, "should find a result")
@@ -779,9 +800,8 @@ func TestGetAllIDs(t *testing.T) {
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
-	bulkProcessor, _ := newBulkProcessor(ec, &bulkProcessorConfig)
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 
 	max := 1001
 	expected := make([]string, max)
@@ -811,10 +831,14 @@ func TestGetAllIDs(t *testing.T) {
 	_, err := ec.Refresh(indexName).Do(context.Background())
 	require.NoError(t, err, "expected successful flush")
 
-	ch := service.GetAllIDs(context.Background(), false, false)
+	pages, err := service.GetAllIDs(context.Background(), IDScan{Slices: 2})
+	require.NoError(t, err)
 	actual := make(map[string]struct{})
-	for id := range ch {
-		actual[id.ID] = struct{}{}
+	for page := range pages {
+		require.NoError(t, page.Err)
+		for _, id := range page.IDs {
+			actual[id.ID] = struct{}{}
+		}
 	}
 
 	notFound := 0
@@ -834,16 +858,8 @@ func getTestESService(t *testing.T) *esService {
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
 	esURL := getElasticSearchTestURL()
 	ec := getElasticClient(t, esURL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
-
-	return &esService{
-		elasticClient:       ec,
-		bulkProcessor:       bulkProcessor,
-		indexName:           indexName,
-		bulkProcessorConfig: &bulkProcessorConfig,
-		getCurrentTime:      time.Now,
-	}
+
+	return newTestEsService(ec, &bulkProcessorConfig)
 }
 
 func getElasticSearchTestURL() string {
@@ -863,7 +879,28 @@ func setReadOnly(t *testing.T, client *elastic.Client, indexName string, readOnl
 	assert.NoError(t, err, "expected no error for putting index settings")
 }
 
-func writeTestPersonDocument(es EsService, conceptType string, uuid string, isFTAuthor string) (EsPersonConceptModel, bool, *elastic.IndexResponse, error) {
+// createTestIndex creates an index with the concepts mapping which, along with the test index, is behind the
+// all-concepts alias until the end of the test
+func createTestIndex(t *testing.T, client *elastic.Client, name string) string {
+	mapping, err := os.ReadFile("../configs/referenceSchema.json")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = client.CreateIndex(name).BodyString(string(mapping)).Do(ctx)
+	require.NoError(t, err, "expected the index to be created")
+	_, err = client.Alias().Add(indexName, allConceptsAlias).Add(name, allConceptsAlias).Do(ctx)
+	require.NoError(t, err, "expected the alias to be created")
+
+	t.Cleanup(func() {
+		_, err := client.Alias().Remove(indexName, allConceptsAlias).Do(ctx)
+		assert.NoError(t, err)
+		_, err = client.DeleteIndex(name).Do(ctx)
+		assert.NoError(t, err)
+	})
+	return name
+}
+
+func writeTestPersonDocument(es EsService, conceptType string, uuid string, isFTAuthor string) (EsPersonConceptModel, bool, *IndexResult, error) {
 	payload := EsPersonConceptModel{
 		EsConceptModel: &EsConceptModel{
 			Id:           uuid,
@@ -885,7 +922,7 @@ func writeTestPersonDocument(es EsService, conceptType string, uuid string, isFT
 func waitForClientInjection(service EsService) error {
 	var err error
 	for i := 0; i < 10; i++ {
-		_, err = service.GetClusterHealth()
+		_, err = service.GetClusterHealth(context.Background())
 		if err == nil {
 			return nil
 		}
@@ -904,7 +941,7 @@ func deleteTestDocument(t *testing.T, es *esService, conceptType string, uuid st
 }
 
 func flushChangesToIndex(t *testing.T, es *esService) {
-	err := es.bulkProcessor.Flush()
+	err := flushBulkPipeline(es.bulk)
 	require.NoError(t, err)
 	_, err = es.elasticClient.Refresh(indexName).Do(context.Background())
 	require.NoError(t, err)
@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -35,9 +38,9 @@ func init() {
 }
 
 func TestNoElasticClient(t *testing.T) {
-	service := esService{sync.RWMutex{}, nil, nil, "test", nil, time.Now}
+	service := esService{indexName: "test", getCurrentTime: time.Now}
 
-	_, err := service.ReadData("any")
+	_, err := service.ReadData(context.Background(), "any")
 
 	assert.Equal(t, ErrNoElasticClient, err, "error response")
 }
@@ -48,10 +51,8 @@ func TestWriteWithGenericError(t *testing.T) {
 	defer es.Close()
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
 	ec := getElasticClient(t, es.URL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
 	_, up, _, err := writeTestDocument(service, organisationsType, testUUID)
 	assert.EqualError(t, err, "unexpected end of JSON input")
@@ -73,10 +74,8 @@ func TestWriteWithESError(t *testing.T) {
 	defer es.Close()
 	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, time.Second)
 	ec := getElasticClient(t, es.URL)
-	bulkProcessor, err := newBulkProcessor(ec, &bulkProcessorConfig)
-	require.NoError(t, err, "require a bulk processor")
 
-	service := &esService{sync.RWMutex{}, ec, bulkProcessor, indexName, &bulkProcessorConfig, time.Now}
+	service := newTestEsService(ec, &bulkProcessorConfig)
 	testUUID := uuid.New().String()
 	_, up, _, err := writeTestDocument(service, organisationsType, testUUID)
 
@@ -102,7 +101,7 @@ func TestDeleteWithESError(t *testing.T) {
 	)
 	assert.NoError(t, err, "expected no error for ES client")
 
-	service := &esService{sync.RWMutex{}, ec, nil, indexName, nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: indexName, getCurrentTime: time.Now}
 
 	testUUID := uuid.New().String()
 	_, err = service.DeleteData(newTestContext(), organisationsType+"s", testUUID)
@@ -127,7 +126,7 @@ func TestDeleteWithGenericError(t *testing.T) {
 		elastic.SetSniff(false),
 	)
 	assert.NoError(t, err, "expected no error for ES client")
-	service := &esService{sync.RWMutex{}, ec, nil, indexName, nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: indexName, getCurrentTime: time.Now}
 
 	testUUID := uuid.New().String()
 
@@ -154,7 +153,7 @@ func TestCleanupErrorLogging(t *testing.T) {
 	)
 	assert.NoError(t, err, "expected no error for ES client")
 
-	service := &esService{sync.RWMutex{}, ec, nil, indexName, nil, time.Now}
+	service := &esService{elasticClient: ec, indexName: indexName, getCurrentTime: time.Now}
 
 	testUUID1 := uuid.New().String()
 	testUUID2 := uuid.New().String()
@@ -177,6 +176,1207 @@ func TestCleanupErrorLogging(t *testing.T) {
 	assert.Equal(t, testTID, hook.LastEntry().Data[tid.TransactionIDKey])
 }
 
+func TestCleanupAndDeleteDuringClientSwaps(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodHead:
+		case http.MethodDelete:
+			w.Write([]byte(`{"result":"deleted"}`))
+		default:
+			w.Write([]byte(`{"hits":{"hits":[{"_index":"concept","_id":"1","_source":{"type":"organisations"}}]}}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{indexName: indexName, getCurrentTime: time.Now}
+	service.setElasticClient(getElasticClient(t, es.URL))
+	concept := AggregateConceptModel{PrefUUID: "2", SourceRepresentations: []SourceConcept{{UUID: "1"}, {UUID: "2"}}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			service.setElasticClient(getElasticClient(t, es.URL))
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		service.CleanupData(newTestContext(), concept)
+		_, err := service.DeleteData(newTestContext(), organisationsType, "1")
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+}
+
+func TestLoadDataOfRenamedTypes(t *testing.T) {
+	var requests []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"_index":"concepts-authors","_id":"1","found":true,"_source":{"id":"1","isFTAuthor":"false","metrics":{"annotationsCount":3}}}`))
+			return
+		}
+		w.Write([]byte(`{"_index":"concepts-authors","_id":"1","result":"updated"}`))
+	}))
+	defer es.Close()
+
+	conceptTypes, err := NewConceptTypes(map[string]ConceptTypeConfig{
+		"authors": {Index: "concepts-authors", Read: true, Write: true, Converter: PersonConverter},
+		"roles":   {Read: true, Write: true, Converter: MembershipConverter},
+	})
+	require.NoError(t, err)
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 10, 2<<20, time.Minute)
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	service.conceptTypes = conceptTypes
+
+	assert.Equal(t, "concepts-authors", service.writeIndex("roles"), "memberships are written into the person type")
+
+	up, _, err := service.LoadData(newTestContext(), "roles", "2", &EsMembershipModel{Id: "2", PersonId: "1", OrganisationId: ftOrgUUID, Memberships: []string{columnistUUID}})
+	require.NoError(t, err)
+	assert.True(t, up)
+	assert.Equal(t, []string{"GET /concepts-authors/_doc/1"}, requests, "the membership only updates its person")
+	assert.Equal(t, &EsPersonConceptPatch{Metrics: &ConceptMetrics{AnnotationsCount: 3}, IsFTAuthor: "true"},
+		getPatchData(nil, log.NewEntry(log.StandardLogger()), conceptTypes.Converter("roles"), &GetResult{Found: true, Source: json.RawMessage(`{"metrics":{"annotationsCount":3}}`)}))
+
+	requests = nil
+	_, _, err = service.LoadData(newTestContext(), "authors", "1", &EsPersonConceptModel{EsConceptModel: &EsConceptModel{Id: "1", Type: "authors"}, IsFTAuthor: "false"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET /concepts-authors/_doc/1", "PUT /concepts-authors/_doc/1"}, requests)
+	assert.Equal(t, &EsPersonConceptPatch{Metrics: &ConceptMetrics{AnnotationsCount: 3}, IsFTAuthor: "false"},
+		getPatchData(nil, log.NewEntry(log.StandardLogger()), conceptTypes.Converter("authors"), &GetResult{Found: true, Source: json.RawMessage(`{"isFTAuthor":"false","metrics":{"annotationsCount":3}}`)}),
+		"a person keeps its FT author flag")
+}
+
+func TestCloseBulkProcessorWithoutElasticClient(t *testing.T) {
+	service := esService{indexName: "test", getCurrentTime: time.Now}
+
+	assert.NoError(t, service.CloseBulkProcessor(context.Background()))
+}
+
+func TestCloseBulkProcessorReportsUnflushedRequests(t *testing.T) {
+	release := make(chan struct{})
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			<-release
+		}
+	}))
+	defer es.Close()
+	defer close(release)
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 10, 2<<20, time.Minute)
+	ec := getElasticClient(t, es.URL)
+
+	service := newTestEsService(ec, &bulkProcessorConfig)
+	service.LoadBulkData(context.Background(), organisationsType, uuid.New().String(), EsConceptModel{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := service.CloseBulkProcessor(ctx)
+
+	assert.ErrorIs(t, err, ErrBulkProcessorFlushTimeout)
+	assert.Contains(t, err.Error(), "1 requests could not be flushed")
+}
+
+func TestWritesAreRoutedPerConceptType(t *testing.T) {
+	conceptTypes, err := NewConceptTypes(map[string]ConceptTypeConfig{
+		person:            {Index: "concepts-people", Read: true, Write: true},
+		organisationsType: {Read: true, Write: true},
+	})
+	require.NoError(t, err)
+
+	var deletePath string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletePath = r.URL.Path
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"result":"deleted"}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now, conceptTypes: conceptTypes}
+
+	assert.Equal(t, "concepts-people", service.writeIndex(person))
+	assert.Equal(t, "concepts-people", service.writeIndex(memberships), "memberships are written into people")
+	assert.Equal(t, indexName, service.writeIndex(organisationsType))
+	assert.Equal(t, indexName, service.writeIndex("genres"))
+
+	_, err = service.DeleteData(newTestContext(), person, "1234")
+	require.NoError(t, err)
+	assert.Equal(t, "/concepts-people/_doc/1234", deletePath)
+}
+
+func TestUnchangedConceptsAreNotWritten(t *testing.T) {
+	var writes []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/_mget" {
+				w.Write([]byte(`{"docs":[{"_index":"test","_id":"1234","found":true,"_source":{"contentHash":"hash-1"}}]}`))
+				return
+			}
+			w.Write([]byte(`{"_index":"test","_id":"1234","found":true,"_source":{"contentHash":"hash-1"}}`))
+		case http.MethodHead:
+		default:
+			writes = append(writes, r.Method+" "+r.URL.Path)
+			w.Write([]byte(`{"_index":"test","_id":"1234","result":"updated"}`))
+		}
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 10, 2<<20, time.Minute)
+	ec := getElasticClient(t, es.URL)
+
+	service := newTestEsService(ec, &bulkProcessorConfig)
+
+	up, resp, err := service.LoadData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-1"})
+	require.NoError(t, err)
+	assert.False(t, up)
+	assert.Equal(t, UnchangedResult, resp.Result)
+	written, err := service.LoadBulkData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-1"})
+	require.NoError(t, err)
+	assert.False(t, written)
+	assert.Empty(t, writes)
+
+	up, resp, err = service.LoadData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-2"})
+	require.NoError(t, err)
+	assert.True(t, up)
+	assert.Equal(t, updatedResult, resp.Result)
+	assert.Contains(t, writes, "PUT /"+indexName+"/_doc/1234")
+}
+
+func TestConditionalWrite(t *testing.T) {
+	var writes []string
+	conflict := false
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"_index":"test","_id":"1234","_seq_no":12,"_primary_term":3,"found":true,"_source":{"contentHash":"hash-1"}}`))
+		case http.MethodHead:
+		default:
+			writes = append(writes, r.Method+" "+r.URL.RequestURI())
+			if conflict {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception"},"status":409}`))
+				return
+			}
+			w.Write([]byte(`{"_index":"test","_id":"1234","result":"updated"}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now}
+
+	_, _, err := service.LoadData(WithIfMatch(newTestContext(), `"3-11"`), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-2"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.Empty(t, writes, "a concept not matching If-Match is not written")
+
+	up, _, err := service.LoadData(WithIfMatch(newTestContext(), `"3-12"`), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-2"})
+	require.NoError(t, err)
+	assert.True(t, up)
+	assert.Equal(t, []string{"PUT /" + indexName + "/_doc/1234?if_primary_term=3&if_seq_no=12"}, writes, "the write is conditional on the revision read")
+
+	conflict = true
+	_, _, err = service.LoadData(WithIfMatch(newTestContext(), `"3-12"`), organisationsType, "1234", &EsConceptModel{Id: "1234", ContentHash: "hash-2"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed, "a concurrent write fails the precondition")
+}
+
+func TestSoftDelete(t *testing.T) {
+	var requests []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+		}
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/tombstones/"):
+			w.Write([]byte(`{"_index":"tombstones","_id":"1234","found":true,"_source":{"id":"1234","deletedAt":"2024-06-01T00:00:00Z","reason":"deleted"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/_mget":
+			w.Write([]byte(`{"docs":[{"_index":"tombstones","_id":"1234","found":true,"_source":{"id":"1234","deletedAt":"2024-06-01T00:00:00Z","reason":"deleted"}}]}`))
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"_index":"concept","_id":"1234","found":false}`))
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/"+indexName+"/"):
+			w.Write([]byte(`{"_index":"concept","_id":"1234","result":"created"}`))
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"id":"1234","conceptType":"organisations","deletedAt":"2024-06-01T00:00:00Z","transactionId":"tid_test","reason":"deleted"}`, string(body))
+			w.Write([]byte(`{"_index":"tombstones","_id":"1234","result":"created"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/"+indexName+"/_doc/5678":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"_index":"concept","_id":"5678","result":"not_found"}`))
+		case r.Method == http.MethodDelete:
+			w.Write([]byte(`{"result":"deleted"}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) },
+		tombstones:     &TombstoneConfig{Index: "tombstones"},
+	}
+
+	_, err := service.DeleteData(newTestContext(), organisationsType, "1234")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DELETE /" + indexName + "/_doc/1234", "PUT /tombstones/_doc/1234"}, requests, "the tombstone is written once the concept is deleted")
+
+	requests = nil
+	res, err := service.DeleteData(newTestContext(), organisationsType, "5678")
+	require.NoError(t, err)
+	assert.Equal(t, notFoundResult, res.Result)
+	assert.Equal(t, []string{"DELETE /" + indexName + "/_doc/5678"}, requests, "a concept that was not stored leaves no tombstone")
+
+	requests = nil
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234", LastModified: "2024-05-01T00:00:00Z"})
+	assert.ErrorIs(t, err, ErrConceptDeleted)
+	assert.Equal(t, []string{"GET /tombstones/_doc/1234"}, requests)
+
+	requests = nil
+	_, err = service.LoadBulkData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234", LastModified: "2024-05-01T00:00:00Z"})
+	assert.ErrorIs(t, err, ErrConceptDeleted)
+	assert.Equal(t, []string{"GET /_mget"}, requests, "the tombstone of a bulk write should be read in a multi get")
+
+	requests = nil
+	untimestamped, err := ConvertAggregateConceptToESConceptModel(AggregateConceptModel{PrefUUID: "1234", PrefLabel: "Late republish", DirectType: "Organisation",
+		SourceRepresentations: []SourceConcept{{UUID: "1234", Authority: "Smartlogic"}}}, organisationsType, testTID, publicAPIHost)
+	require.NoError(t, err)
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1234", untimestamped)
+	assert.ErrorIs(t, err, ErrConceptDeleted, "a republish without an upstream lastModified is rejected even though it is indexed after the deletion")
+	assert.Equal(t, []string{"GET /tombstones/_doc/1234"}, requests)
+
+	requests = nil
+	up, _, err := service.LoadData(WithRecreate(newTestContext()), organisationsType, "1234", untimestamped)
+	require.NoError(t, err)
+	assert.True(t, up, "an explicit re-create is written")
+	assert.Equal(t, []string{"GET /tombstones/_doc/1234", "GET /" + indexName + "/_doc/1234", "PUT /" + indexName + "/_doc/1234", "DELETE /tombstones/_doc/1234"}, requests, "the tombstone of a re-created concept is removed")
+}
+
+func TestRetryOverloadedCalls(t *testing.T) {
+	var attempts int
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			return
+		}
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"type":"es_rejected_execution_exception"},"status":429}`))
+			return
+		}
+		w.Write([]byte(`{"result":"deleted"}`))
+	}))
+	defer es.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		retry:          &RetryConfig{Delete: policy},
+	}
+
+	result, err := service.DeleteData(newTestContext(), organisationsType, "1234")
+	require.NoError(t, err)
+	assert.Equal(t, "deleted", result.Result)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	service.retry.Delete.MaxAttempts = 2
+	_, err = service.DeleteData(newTestContext(), organisationsType, "1234")
+	assert.True(t, overloaded(err), "the overloaded error is returned once the attempts are exhausted")
+	assert.Equal(t, 2, attempts)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var attempts int
+	status := http.StatusServiceUnavailable
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPut {
+			return
+		}
+		attempts++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"result":"created"}`))
+	}))
+	defer es.Close()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := &RetryConfig{BreakerThreshold: 2, BreakerCooldown: 30 * time.Second}
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		retry:          config,
+		breaker:        newCircuitBreaker(config),
+	}
+	service.breaker.now = func() time.Time { return now }
+	write := func() error {
+		_, _, err := service.LoadData(newTestContext(), organisationsType, "1234", &EsConceptModel{Id: "1234"})
+		return err
+	}
+
+	require.Error(t, write())
+	assert.Equal(t, CircuitBreakerState{State: CircuitClosed, ConsecutiveFailures: 1}, service.CircuitBreaker())
+	require.Error(t, write())
+	assert.Equal(t, CircuitBreakerState{State: CircuitOpen, ConsecutiveFailures: 2, RetryAfter: 30 * time.Second}, service.CircuitBreaker())
+
+	attempts = 0
+	err := write()
+	var circuitErr *CircuitOpenError
+	require.ErrorAs(t, err, &circuitErr)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 30*time.Second, circuitErr.RetryAfter)
+	assert.Zero(t, attempts, "elasticsearch is not called while the circuit breaker is open")
+
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, CircuitHalfOpen, service.CircuitBreaker().State)
+	status = http.StatusCreated
+	require.NoError(t, write())
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, CircuitBreakerState{State: CircuitClosed}, service.CircuitBreaker())
+}
+
+func TestCircuitBreakerLetsASingleProbeThrough(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(&RetryConfig{BreakerThreshold: 1, BreakerCooldown: 30 * time.Second})
+	breaker.now = func() time.Time { return now }
+	overloadedErr := &elastic.Error{Status: http.StatusTooManyRequests}
+
+	breaker.record(overloadedErr, false)
+	_, err := breaker.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, breaker.check(), "checking the half-open circuit breaker should not take the probe")
+	probe, err := breaker.allow()
+	require.NoError(t, err)
+	assert.True(t, probe)
+
+	_, err = breaker.allow()
+	var circuitErr *CircuitOpenError
+	require.ErrorAs(t, err, &circuitErr, "the calls should be rejected while the probe is in flight")
+	assert.Equal(t, probeRetryAfter, circuitErr.RetryAfter)
+	assert.ErrorIs(t, breaker.check(), ErrCircuitOpen)
+	assert.Equal(t, CircuitHalfOpen, breaker.state().State)
+
+	breaker.record(context.DeadlineExceeded, true)
+	probe, err = breaker.allow()
+	require.NoError(t, err, "a probe not answered by the cluster should let another one through")
+	assert.True(t, probe)
+
+	breaker.record(overloadedErr, true)
+	_, err = breaker.allow()
+	require.ErrorAs(t, err, &circuitErr)
+	assert.Equal(t, 30*time.Second, circuitErr.RetryAfter, "an overloaded probe should open the circuit breaker again")
+
+	now = now.Add(30 * time.Second)
+	probe, err = breaker.allow()
+	require.NoError(t, err)
+	breaker.record(nil, probe)
+	probe, err = breaker.allow()
+	require.NoError(t, err)
+	assert.False(t, probe)
+	assert.Equal(t, CircuitBreakerState{State: CircuitClosed}, breaker.state())
+}
+
+func TestHealthChecksAreRetried(t *testing.T) {
+	var attempts int
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		attempts++
+		if attempts%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"type":"cluster_block_exception"},"status":503}`))
+			return
+		}
+		if r.URL.Path == "/_cluster/health" {
+			w.Write([]byte(`{"cluster_name":"test","status":"green"}`))
+			return
+		}
+		w.Write([]byte(`{"concept":{"settings":{"index":{}}}}`))
+	}))
+	defer es.Close()
+
+	config := &RetryConfig{Health: RetryPolicy{MaxAttempts: 2}, BreakerThreshold: 1, BreakerCooldown: time.Minute}
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		retry:          config,
+		breaker:        newCircuitBreaker(config),
+	}
+
+	health, err := service.GetClusterHealth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "green", health.Status)
+	readOnly, _, err := service.IsIndexReadOnly(context.Background())
+	require.NoError(t, err)
+	assert.False(t, readOnly)
+	assert.Equal(t, 4, attempts)
+
+	service.retry.Health.MaxAttempts = 1
+	_, err = service.GetClusterHealth(context.Background())
+	assert.True(t, overloaded(err))
+	_, _, err = service.IsIndexReadOnly(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen, "the health checks should go through the circuit breaker")
+	assert.Equal(t, 5, attempts)
+}
+
+func TestClientIsReplacedDuringABackoff(t *testing.T) {
+	attempted := make(chan struct{}, 1)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"type":"es_rejected_execution_exception"},"status":429}`))
+	}))
+	defer es.Close()
+
+	service := &esService{
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		retry:          &RetryConfig{Read: RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour, MaxBackoff: time.Hour}},
+	}
+	service.setElasticClient(getElasticClient(t, es.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	read := make(chan error)
+	go func() {
+		_, err := service.ReadData(ctx, "1234")
+		read <- err
+	}()
+	<-attempted
+
+	replacement := getElasticClient(t, es.URL)
+	swapped := make(chan struct{})
+	go func() {
+		service.setElasticClient(replacement)
+		close(swapped)
+	}()
+	select {
+	case <-swapped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client swap waited for the backoff of the read")
+	}
+
+	cancel()
+	assert.True(t, overloaded(<-read))
+}
+
+func TestBulkBackpressure(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_bulk" {
+			w.Write([]byte(`{"items":[]}`))
+		}
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute).WithInFlightLimits(0, 2)
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	defer service.CloseBulkProcessor(context.Background())
+
+	for _, uuid := range []string{"1", "2"} {
+		written, err := service.LoadBulkData(newTestContext(), organisationsType, uuid, &EsConceptModel{Id: uuid})
+		require.NoError(t, err)
+		assert.True(t, written)
+	}
+	usage := service.BulkUsage()
+	assert.Equal(t, int64(2), usage.InFlightActions)
+	assert.Equal(t, int64(2), usage.MaxInFlightActions)
+	assert.Positive(t, usage.InFlightBytes)
+
+	_, err := service.LoadBulkData(newTestContext(), organisationsType, "3", &EsConceptModel{Id: "3"})
+	var backpressureErr *BackpressureError
+	require.ErrorAs(t, err, &backpressureErr)
+	assert.Equal(t, time.Minute, backpressureErr.RetryAfter)
+
+	require.NoError(t, flushBulkPipeline(service.bulk))
+	usage = service.BulkUsage()
+	assert.Zero(t, usage.InFlightActions, "the committed requests are not in flight anymore")
+	assert.Zero(t, usage.InFlightBytes)
+	assert.Equal(t, []BulkProcessorStats{{Name: conceptsBulkProcessor, Committed: 1}}, usage.Processors)
+
+	written, err := service.LoadBulkData(newTestContext(), organisationsType, "3", &EsConceptModel{Id: "3"})
+	require.NoError(t, err)
+	assert.True(t, written)
+}
+
+func TestBulkLookupsAreShedAndRetried(t *testing.T) {
+	var reads []string
+	var lookup map[string]interface{}
+	var lock sync.Mutex
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_bulk" {
+			w.Write([]byte(`{"items":[]}`))
+			return
+		}
+		if r.Method != http.MethodGet {
+			return
+		}
+		lock.Lock()
+		reads = append(reads, r.URL.Path)
+		attempt := len(reads)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&lookup))
+		lock.Unlock()
+		if attempt%2 == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"type":"es_rejected_execution_exception"},"status":429}`))
+			return
+		}
+		w.Write([]byte(`{"docs":[{"_index":"tombstones","_id":"1","found":false},` +
+			`{"_index":"test","_id":"1","found":true,"_source":{"contentHash":"hash-1"}}]}`))
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute).WithInFlightLimits(0, 1)
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	service.tombstones = &TombstoneConfig{Index: "tombstones"}
+	service.retry = &RetryConfig{Read: RetryPolicy{MaxAttempts: 2}}
+	defer service.CloseBulkProcessor(context.Background())
+
+	written, err := service.LoadBulkData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1", ContentHash: "hash-1"})
+	require.NoError(t, err)
+	assert.False(t, written)
+	assert.Equal(t, []string{"/_mget", "/_mget"}, reads, "the lookups should be a single multi get, retried while elasticsearch is overloaded")
+	assert.Equal(t, map[string]interface{}{"docs": []interface{}{
+		map[string]interface{}{"_index": "tombstones", "_id": "1"},
+		map[string]interface{}{"_index": indexName, "_id": "1", "_source": map[string]interface{}{"includes": []interface{}{"contentHash"}}},
+	}}, lookup)
+	assert.Zero(t, service.BulkUsage().InFlightActions, "an unchanged concept should not stay in flight")
+
+	written, err = service.LoadBulkData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1", ContentHash: "hash-2"})
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	reads = nil
+	_, err = service.LoadBulkData(newTestContext(), organisationsType, "2", &EsConceptModel{Id: "2", ContentHash: "hash-2"})
+	var backpressureErr *BackpressureError
+	require.ErrorAs(t, err, &backpressureErr)
+	assert.Empty(t, reads, "the lookups should be shed with the load")
+}
+
+func TestMetricsBulkProcessor(t *testing.T) {
+	var mu sync.Mutex
+	var bulkBodies []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_bulk" {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bulkBodies = append(bulkBodies, string(body))
+			mu.Unlock()
+			w.Write([]byte(`{"items":[]}`))
+		}
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute).
+		WithMetricsProcessor(NewBulkProcessorConfig(1, 100, 2<<20, time.Minute))
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	service.getCurrentTime = func() time.Time { return time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC) }
+	defer service.CloseBulkProcessor(context.Background())
+
+	_, err := service.LoadBulkData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1"})
+	require.NoError(t, err)
+	require.NoError(t, service.PatchUpdateConcept(newTestContext(), organisationsType, "2", &EsConceptModelPatch{Metrics: &ConceptMetrics{}}))
+
+	usage := service.BulkUsage()
+	assert.Equal(t, int64(1), usage.InFlightActions, "the metrics should not be accounted in the requests in flight")
+	require.Len(t, usage.Processors, 2)
+	assert.Equal(t, conceptsBulkProcessor, usage.Processors[0].Name)
+	assert.Equal(t, metricsBulkProcessor, usage.Processors[1].Name)
+
+	require.NoError(t, flushBulkPipeline(service.metricsBulk))
+	mu.Lock()
+	require.Len(t, bulkBodies, 1, "the metrics should be committed without the concepts")
+	assert.Contains(t, bulkBodies[0], `"update":{"_index":"`+indexName+`","_id":"2"}`)
+	assert.Contains(t, bulkBodies[0], `"indexedAt":"2024-06-03T12:00:00Z"`, "a patch should move the time the document was indexed at")
+	assert.NotContains(t, bulkBodies[0], `"_id":"1"`)
+	mu.Unlock()
+	assert.Equal(t, int64(1), service.BulkUsage().Processors[1].Committed)
+	assert.Zero(t, service.BulkUsage().Processors[0].Committed)
+}
+
+func TestBulkFailuresAreLoggedWithThePublish(t *testing.T) {
+	hook := testLog.NewLocal(logger.Logger())
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_bulk" {
+			w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"_index":"concepts","_id":"1","status":201}},
+				{"update":{"_index":"concepts","_id":"2","status":404,"error":{"type":"document_missing_exception","reason":"document missing"}}}
+			]}`))
+		}
+	}))
+	defer es.Close()
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 100, 2<<20, time.Minute)
+	service := newTestEsService(getElasticClient(t, es.URL), &bulkProcessorConfig)
+	defer service.CloseBulkProcessor(context.Background())
+
+	_, err := service.LoadBulkData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1"})
+	require.NoError(t, err)
+	require.NoError(t, service.PatchUpdateConcept(newTestContext(), person, "2", &EsConceptModelPatch{Metrics: &ConceptMetrics{}}))
+	require.NoError(t, flushBulkPipeline(service.bulk))
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, log.ErrorLevel, entry.Level)
+	assert.Equal(t, "Concept 2 of index concepts failed in the bulk request", entry.Message)
+	assert.Equal(t, testTID, entry.Data[tid.TransactionIDKey])
+	assert.Equal(t, person, entry.Data[conceptTypeField])
+	assert.Equal(t, "2", entry.Data[uuidField])
+	assert.Equal(t, metricsOperation, entry.Data[operationField])
+	assert.Equal(t, 404, entry.Data["status"])
+	assert.Contains(t, entry.Data["error"], "document missing [type=document_missing_exception]")
+}
+
+func TestAuditTrail(t *testing.T) {
+	var requests []string
+	var record map[string]interface{}
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/"+indexName+"/_doc/1234":
+			w.Write([]byte(`{"_index":"concept","_id":"1234","found":true,"_source":{"id":"1234","type":"Organisation","prefLabel":"Old label","lastModified":"2024-05-01T00:00:00Z"}}`))
+		case r.Method == http.MethodDelete:
+			w.Write([]byte(`{"result":"deleted"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/audit/_doc/":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&record))
+			w.Write([]byte(`{"_index":"audit","_id":"1","result":"created"}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			w.Write([]byte(`{"hits":{"hits":[{"_index":"audit","_id":"1","_source":{"conceptId":"1234","operation":"delete","timestamp":"2024-06-01T00:00:00Z","transactionId":"tid_test"}}]}}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}
+	_, err := service.History(context.Background(), "1234")
+	assert.ErrorIs(t, err, ErrHistoryDisabled)
+
+	service.audit = &AuditConfig{Index: "audit"}
+	_, err = service.DeleteData(newTestContext(), organisationsType, "1234")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET /" + indexName + "/_doc/1234", "DELETE /" + indexName + "/_doc/1234", "POST /audit/_doc/"}, requests)
+	assert.Equal(t, map[string]interface{}{
+		"conceptId":     "1234",
+		"conceptType":   organisationsType,
+		"operation":     deleteOperation,
+		"timestamp":     "2024-06-01T00:00:00Z",
+		"transactionId": testTID,
+		"changes": map[string]interface{}{
+			"id":        map[string]interface{}{"before": "1234"},
+			"type":      map[string]interface{}{"before": "Organisation"},
+			"prefLabel": map[string]interface{}{"before": "Old label"},
+		},
+	}, record)
+
+	history, err := service.History(context.Background(), "1234")
+	require.NoError(t, err)
+	assert.Equal(t, []ChangeRecord{{ConceptID: "1234", Operation: deleteOperation, Timestamp: "2024-06-01T00:00:00Z", TransactionID: testTID}}, history)
+}
+
+func TestReadsAreRealtimeWithPerTypeIndices(t *testing.T) {
+	var mgetBody map[string]interface{}
+	var mgetQuery string
+	var searchPath string
+	var deletePaths []string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/_mget":
+			mgetQuery = r.URL.RawQuery
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&mgetBody))
+			w.Write([]byte(`{"docs":[{"_index":"concept","_id":"1234","found":false},` +
+				`{"_index":"concepts-people-v1","_id":"1234","found":true,"_seq_no":3,"_primary_term":1,"_source":{"type":"people","prefLabel":"Jane Doe"}}]}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			searchPath = r.URL.Path
+			w.Write([]byte(`{"hits":{"hits":[{"_index":"concepts-people-v1","_id":"1234","_source":{"type":"people","prefLabel":"Jane Doe"}}]}}`))
+		case r.Method == http.MethodDelete:
+			deletePaths = append(deletePaths, r.URL.Path)
+			w.Write([]byte(`{"result":"deleted"}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		conceptTypes:   ConceptTypesFromList([]string{person, organisationsType}, map[string]string{person: "concepts-people"}),
+	}
+	assert.Equal(t, []string{indexName, "concepts-people"}, service.writeIndices())
+
+	result, err := service.ReadData(context.Background(), "1234")
+	require.NoError(t, err)
+	assert.Contains(t, mgetQuery, "realtime=true")
+	assert.Equal(t, map[string]interface{}{"docs": []interface{}{
+		map[string]interface{}{"_index": indexName, "_id": "1234"},
+		map[string]interface{}{"_index": "concepts-people", "_id": "1234"},
+	}}, mgetBody, "the concept should be fetched from every index")
+	assert.Empty(t, searchPath, "reads should not go through the search")
+	assert.True(t, result.Found)
+	assert.Equal(t, "concepts-people-v1", result.Index)
+	require.NotNil(t, result.SeqNo)
+	assert.Equal(t, int64(3), *result.SeqNo)
+	assert.JSONEq(t, `{"type":"people","prefLabel":"Jane Doe"}`, string(result.Source))
+
+	service.CleanupData(newTestContext(), AggregateConceptModel{PrefUUID: "5678", SourceRepresentations: []SourceConcept{{UUID: "5678"}, {UUID: "1234"}}})
+	assert.Equal(t, "/all-concepts/_search", searchPath, "concorded concepts should be found across all indices")
+	assert.Equal(t, []string{"/concepts-people-v1/_doc/1234"}, deletePaths, "concorded concepts should be deleted from the index they were found in")
+}
+
+func TestReadDataFailsForAConceptInSeveralIndices(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"docs":[{"_index":"concept","_id":"1234","found":true,"_source":{"type":"people"}},` +
+			`{"_index":"concepts-people-v1","_id":"1234","found":true,"_source":{"type":"people"}}]}`))
+	}))
+	defer es.Close()
+
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		conceptTypes:   ConceptTypesFromList([]string{person, organisationsType}, map[string]string{person: "concepts-people"}),
+	}
+
+	_, err := service.ReadData(context.Background(), "1234")
+	assert.ErrorIs(t, err, ErrDuplicateConcept)
+}
+
+func TestExportConcepts(t *testing.T) {
+	var searchPath string
+	var searchBody map[string]interface{}
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_search/scroll" {
+			w.Write([]byte(`{"_scroll_id":"scroll-1","hits":{"hits":[]}}`))
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/_search") {
+			return
+		}
+		searchPath = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&searchBody))
+		w.Write([]byte(`{"_scroll_id":"scroll-1","hits":{"hits":[` +
+			`{"_id":"1","_source":{"id":"http://api.ft.com/things/1","prefLabel":"Lex"}},` +
+			`{"_id":"2","_source":{"id":"http://api.ft.com/things/2","prefLabel":"Markets"}}]}}`))
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now}
+	isDeprecated := false
+	docs, err := service.ExportConcepts(context.Background(), ExportFilter{
+		ConceptFilter: ConceptFilter{
+			Types:        []string{"genres"},
+			Authorities:  []string{"TME"},
+			ModifiedFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			IsDeprecated: &isDeprecated,
+		},
+		Fields: []string{"prefLabel"},
+	})
+	require.NoError(t, err)
+
+	var exported []string
+	for doc := range docs {
+		require.NoError(t, doc.Err)
+		exported = append(exported, string(doc.Source))
+	}
+	assert.Equal(t, []string{
+		`{"id":"http://api.ft.com/things/1","prefLabel":"Lex"}`,
+		`{"id":"http://api.ft.com/things/2","prefLabel":"Markets"}`,
+	}, exported)
+
+	assert.Equal(t, "/"+indexName+"/_search", searchPath)
+	query, err := json.Marshal(searchBody["query"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{
+		"filter":[
+			{"terms":{"type":["genres"]}},
+			{"terms":{"authorities":["TME"]}},
+			{"range":{"lastModified":{"from":"2024-01-01T00:00:00Z","include_lower":true,"include_upper":true,"to":null}}}
+		],
+		"must_not":{"term":{"isDeprecated":true}}
+	}}`, string(query))
+	assert.Equal(t, map[string]interface{}{"includes": []interface{}{"id", "prefLabel"}}, searchBody["_source"])
+}
+
+func TestExportConceptsClearsCancelledScroll(t *testing.T) {
+	cleared := make(chan string, 1)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+			body := map[string][]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			cleared <- strings.Join(body["scroll_id"], ",")
+			w.Write([]byte(`{"succeeded":true}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			w.Write([]byte(`{"_scroll_id":"scroll-1","hits":{"hits":[{"_id":"1","_source":{}},{"_id":"2","_source":{}}]}}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now}
+	ctx, cancel := context.WithCancel(context.Background())
+	docs, err := service.ExportConcepts(ctx, ExportFilter{})
+	require.NoError(t, err)
+
+	<-docs
+	cancel()
+	for range docs {
+	}
+
+	select {
+	case scrollID := <-cleared:
+		assert.Equal(t, "scroll-1", scrollID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the scroll context of the cancelled export was not cleared")
+	}
+}
+
+func TestExportConceptsInterrupted(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+			w.Write([]byte(`{"succeeded":true}`))
+		case r.URL.Path == "/_search/scroll":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"search_context_missing_exception"},"status":404}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			w.Write([]byte(`{"_scroll_id":"scroll-1","hits":{"hits":[{"_id":"1","_source":{"id":"1"}}]}}`))
+		}
+	}))
+	defer es.Close()
+
+	service := &esService{indexName: indexName, getCurrentTime: time.Now}
+	service.setElasticClient(getElasticClient(t, es.URL))
+	docs, err := service.ExportConcepts(context.Background(), ExportFilter{})
+	require.NoError(t, err)
+
+	first := <-docs
+	assert.JSONEq(t, `{"id":"1"}`, string(first.Source))
+
+	swapped := make(chan struct{})
+	go func() {
+		service.setElasticClient(getElasticClient(t, es.URL))
+		close(swapped)
+	}()
+	select {
+	case <-swapped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client swap waited for the export to be read")
+	}
+
+	var last ExportedConcept
+	for doc := range docs {
+		last = doc
+	}
+	assert.True(t, elastic.IsNotFound(last.Err), "the export should end with the error interrupting it, got %v", last.Err)
+}
+
+func TestOperationTimeout(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer es.Close()
+
+	service := &esService{
+		elasticClient:  getElasticClient(t, es.URL),
+		indexName:      indexName,
+		getCurrentTime: time.Now,
+		timeouts:       &TimeoutConfig{Read: 50 * time.Millisecond},
+	}
+
+	start := time.Now()
+	_, err := service.ReadData(context.Background(), "1234")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second, "the read should be abandoned at its deadline")
+}
+
+func TestExportConceptsWithoutElasticClient(t *testing.T) {
+	service := &esService{indexName: indexName, getCurrentTime: time.Now}
+
+	_, err := service.ExportConcepts(context.Background(), ExportFilter{})
+	assert.Equal(t, ErrNoElasticClient, err)
+}
+
+// newPointInTimeESMock serves the ids sorted in a point in time, split in slices by their position
+func newPointInTimeESMock(t *testing.T, ids []string, requests *[]map[string]interface{}, closed *[]string) *httptest.Server {
+	var mutex sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/"+indexName+"/_pit":
+			w.Write([]byte(`{"id":"pit-1"}`))
+		case r.URL.Path == "/_pit" && r.Method == http.MethodDelete:
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			*closed = append(*closed, body["id"])
+			w.Write([]byte(`{"succeeded":true}`))
+		case r.URL.Path == "/_search":
+			body := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			mutex.Lock()
+			*requests = append(*requests, body)
+			mutex.Unlock()
+
+			if body["pit"].(map[string]interface{})["id"] == "expired" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"type":"search_phase_execution_exception"},"status":404}`))
+				return
+			}
+			var after string
+			if searchAfter, found := body["search_after"].([]interface{}); found {
+				after = searchAfter[0].(string)
+			}
+			slice, slices := 0, 1
+			if sliceQuery, found := body["slice"].(map[string]interface{}); found {
+				slice, slices = int(sliceQuery["id"].(float64)), int(sliceQuery["max"].(float64))
+			}
+			var hits []string
+			for i, id := range ids {
+				if id > after && i%slices == slice && body["size"].(float64) > 0 {
+					hits = append(hits, fmt.Sprintf(`{"_id":"%s","_source":{"type":"genres"},"sort":["%s"]}`, id, id))
+				}
+			}
+			fmt.Fprintf(w, `{"pit_id":"pit-1","hits":{"hits":[%s]}}`, strings.Join(hits, ","))
+		}
+	}))
+}
+
+func TestGetAllIDsScansSlicesOfAPointInTime(t *testing.T) {
+	var requests []map[string]interface{}
+	var closed []string
+	es := newPointInTimeESMock(t, []string{"a", "b", "c"}, &requests, &closed)
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now, pointInTime: true}
+	pages, err := service.GetAllIDs(context.Background(), IDScan{IncludeTypes: true, Slices: 2})
+	require.NoError(t, err)
+
+	var ids []EsIDTypePair
+	for page := range pages {
+		require.NoError(t, page.Err)
+		ids = append(ids, page.IDs...)
+	}
+	assert.ElementsMatch(t, []EsIDTypePair{{ID: "a", Type: "genres"}, {ID: "b", Type: "genres"}, {ID: "c", Type: "genres"}}, ids)
+	assert.Equal(t, []string{"pit-1"}, closed, "the point in time of a complete scan should be closed")
+	require.Len(t, requests, 2)
+	for _, request := range requests {
+		assert.Equal(t, "pit-1", request["pit"].(map[string]interface{})["id"])
+		assert.Equal(t, float64(2), request["slice"].(map[string]interface{})["max"])
+	}
+}
+
+func TestGetAllIDsClosesThePointInTimeOfACancelledScan(t *testing.T) {
+	ids := make([]string, idScanPageSize+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%04d", i)
+	}
+	var requests []map[string]interface{}
+	var closed []string
+	es := newPointInTimeESMock(t, ids, &requests, &closed)
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now, pointInTime: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	pages, err := service.GetAllIDs(ctx, IDScan{})
+	require.NoError(t, err)
+
+	<-pages
+	cancel()
+	for range pages {
+	}
+	assert.Equal(t, []string{"pit-1"}, closed, "the point in time of a cancelled scan should be closed")
+}
+
+func TestGetAllIDsTimeout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		blocked string
+	}{
+		{name: "Point in time", blocked: "/" + indexName + "/_pit"},
+		{name: "Page", blocked: "/_search"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == tc.blocked:
+					// the body is drained for the server to notice the cancellation of the request
+					io.Copy(io.Discard, r.Body)
+					<-r.Context().Done()
+				case r.URL.Path == "/"+indexName+"/_pit":
+					w.Write([]byte(`{"id":"pit-1"}`))
+				case r.URL.Path == "/_pit":
+					w.Write([]byte(`{"succeeded":true}`))
+				}
+			}))
+			defer es.Close()
+
+			service := &esService{
+				elasticClient:  getElasticClient(t, es.URL),
+				indexName:      indexName,
+				getCurrentTime: time.Now,
+				pointInTime:    true,
+				timeouts:       &TimeoutConfig{Scan: 50 * time.Millisecond},
+			}
+
+			start := time.Now()
+			pages, err := service.GetAllIDs(context.Background(), IDScan{})
+			if err == nil {
+				for page := range pages {
+					err = page.Err
+				}
+			}
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), 5*time.Second, "the scan should be abandoned at the deadline of the blocked call")
+		})
+	}
+}
+
+func TestGetAllIDsResumesFromCursor(t *testing.T) {
+	var requests []map[string]interface{}
+	var closed []string
+	es := newPointInTimeESMock(t, []string{"a", "b", "c"}, &requests, &closed)
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now, pointInTime: true}
+	cursor := &idScanCursor{PointInTime: "pit-1", Slices: []idScanSliceCursor{{After: []interface{}{"b"}}}}
+	pages, err := service.GetAllIDs(context.Background(), IDScan{Cursor: cursor.encode()})
+	require.NoError(t, err)
+
+	var ids []EsIDTypePair
+	var last IDScanPage
+	for page := range pages {
+		ids = append(ids, page.IDs...)
+		last = page
+	}
+	assert.Equal(t, []EsIDTypePair{{ID: "c"}}, ids)
+	resumed, err := decodeIDScanCursor(last.Cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []idScanSliceCursor{{After: []interface{}{"c"}, Done: true}}, resumed.Slices)
+
+	expired := &idScanCursor{PointInTime: "expired", Slices: []idScanSliceCursor{{After: []interface{}{"b"}}}}
+	_, err = service.GetAllIDs(context.Background(), IDScan{Cursor: expired.encode()})
+	assert.Equal(t, ErrCursorExpired, err)
+}
+
+func TestCountConcepts(t *testing.T) {
+	var countPath string
+	var countBody map[string]interface{}
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/_count") {
+			return
+		}
+		countPath = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&countBody))
+		w.Write([]byte(`{"count":42}`))
+	}))
+	defer es.Close()
+
+	service := &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName, getCurrentTime: time.Now}
+	isFTAuthor := true
+	filter := ConceptFilter{Types: []string{person}, IsFTAuthor: &isFTAuthor}
+	filter.ExcludeFTPinkAuthorities()
+
+	count, err := service.CountConcepts(context.Background(), filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+	assert.Equal(t, "/"+indexName+"/_count", countPath)
+	query, err := json.Marshal(countBody["query"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{
+		"filter":[
+			{"terms":{"type":["people"]}},
+			{"term":{"isFTAuthor":"true"}}
+		],
+		"must_not":{"terms":{"authorities":["TME","Smartlogic"]}}
+	}}`, string(query))
+}
+
+func TestGetAllIDsWithInvalidScan(t *testing.T) {
+	service := &esService{elasticClient: getElasticClient(t, newBrokenESMock().URL), indexName: indexName, pointInTime: true}
+
+	_, err := service.GetAllIDs(context.Background(), IDScan{Slices: MaxIDScanSlices + 1})
+	assert.ErrorIs(t, err, ErrInvalidIDScan)
+
+	_, err = service.GetAllIDs(context.Background(), IDScan{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	service.pointInTime = false
+	_, err = service.GetAllIDs(context.Background(), IDScan{Slices: 2})
+	assert.ErrorIs(t, err, ErrInvalidIDScan, "slices need a point in time")
+}
+
+func TestReadOnlyIndex(t *testing.T) {
+	settings := func(writeBlocked string) *elastic.IndicesGetSettingsResponse {
+		index := map[string]interface{}{}
+		if writeBlocked != "" {
+			index["blocks"] = map[string]interface{}{"write": writeBlocked}
+		}
+		return &elastic.IndicesGetSettingsResponse{Settings: map[string]interface{}{"index": index}}
+	}
+	es := &esService{}
+
+	readOnly, name, err := readOnlyIndex(map[string]*elastic.IndicesGetSettingsResponse{
+		"concepts":        settings(""),
+		"concepts-people": settings("true"),
+	}, es.isIndexReadOnly)
+	require.NoError(t, err)
+	assert.True(t, readOnly)
+	assert.Equal(t, "concepts-people", name)
+
+	readOnly, name, err = readOnlyIndex(map[string]*elastic.IndicesGetSettingsResponse{
+		"concepts-people": settings("false"),
+		"concepts":        settings(""),
+	}, es.isIndexReadOnly)
+	require.NoError(t, err)
+	assert.False(t, readOnly)
+	assert.Equal(t, "concepts, concepts-people", name)
+
+	_, _, err = readOnlyIndex(nil, es.isIndexReadOnly)
+	assert.Error(t, err)
+}
+
 func newBrokenESMock() *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodHead {
@@ -197,7 +1397,7 @@ func getElasticClient(t *testing.T, url string) *elastic.Client {
 	return ec
 }
 
-func writeTestDocument(es EsService, conceptType string, uuid string) (EsConceptModel, bool, *elastic.IndexResponse, error) {
+func writeTestDocument(es EsService, conceptType string, uuid string) (EsConceptModel, bool, *IndexResult, error) {
 	payload := EsConceptModel{
 		Id:           uuid,
 		Type:         conceptType,
@@ -213,6 +1413,24 @@ func writeTestDocument(es EsService, conceptType string, uuid string) (EsConcept
 	return payload, update, resp, err
 }
 
+// newTestEsService returns an esService built like the production one, with the bulk processors of ec
+func newTestEsService(ec *elastic.Client, bulkProcessorConfig *BulkProcessorConfig) *esService {
+	service := newEsService(make(chan *elastic.Client), indexName, bulkProcessorConfig, nil, nil, nil, nil, nil)
+	service.setElasticClient(ec)
+	return service
+}
+
+// flushBulkPipeline commits the requests queued in the bulk processor of the pipeline
+func flushBulkPipeline(pipeline *bulkPipeline) error {
+	pipeline.RLock()
+	defer pipeline.RUnlock()
+
+	if pipeline.processor == nil {
+		return ErrNoElasticClient
+	}
+	return pipeline.processor.Flush()
+}
+
 func newTestContext() context.Context {
 	return tid.TransactionAwareContext(context.Background(), testTID)
 }
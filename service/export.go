@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// ExportFilter selects the concepts streamed by an export and the fields exported
+type ExportFilter struct {
+	ConceptFilter
+	// Fields are the document fields to export, the whole document is exported when empty. The id is always exported.
+	Fields []string
+}
+
+// ExportedConcept is the source of an exported concept, or the error interrupting the export as its last item
+type ExportedConcept struct {
+	Source json.RawMessage
+	Err    error
+}
+
+func (f ExportFilter) fetchSource() *elastic.FetchSourceContext {
+	fetchSource := elastic.NewFetchSourceContext(true)
+	if len(f.Fields) > 0 {
+		fetchSource.Include(append([]string{"id"}, f.Fields...)...)
+	}
+	return fetchSource
+}
+
+// project returns the fields of the document selected by the filter
+func (f ExportFilter) project(source json.RawMessage) (json.RawMessage, error) {
+	if len(f.Fields) == 0 {
+		return source, nil
+	}
+
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	projected := make(map[string]json.RawMessage)
+	for _, field := range append([]string{"id"}, f.Fields...) {
+		if value, found := doc[field]; found {
+			projected[field] = value
+		}
+	}
+	return json.Marshal(projected)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrInvalidIDScan = errors.New("invalid ID scan")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorExpired = errors.New("the cursor expired, the scan must be restarted")
+)
+
+const (
+	// MaxIDScanSlices is the maximum number of slices of the concept IDs scanned in parallel
+	MaxIDScanSlices = 8
+	idScanPageSize  = 1000
+	// idScanKeepAlive is how long the point in time of a scan is kept between two pages, and therefore how long a
+	// scan can be resumed after it was interrupted
+	idScanKeepAlive = "5m"
+	idScanSortField = "id"
+)
+
+// IDScan configures a scan of the concept IDs
+type IDScan struct {
+	IncludeTypes bool
+	// IncludeHashes adds the aggregate hash of the concepts to their IDs
+	IncludeHashes bool
+	// Filter selects the concepts whose IDs are scanned
+	Filter ConceptFilter
+	// Slices is the number of slices of the IDs scanned in parallel, ignored when resuming from a cursor
+	Slices int
+	// Cursor resumes an interrupted scan, it must be used with the same options the scan was started with
+	Cursor string
+}
+
+// pair returns the ID of the concept along with the fields requested by the scan
+func (scan IDScan) pair(id string, esModel EsConceptModel) EsIDTypePair {
+	pair := EsIDTypePair{ID: id}
+	if scan.IncludeTypes {
+		pair.Type = esModel.Type
+	}
+	if scan.IncludeHashes {
+		pair.Hash = esModel.AggregateHash
+	}
+	return pair
+}
+
+// IDScanPage is a page of concept IDs and the cursor resuming the scan once the page, and all the ones received
+// before it, are processed. The last page of a failed scan has no IDs and reports the error.
+type IDScanPage struct {
+	IDs    []EsIDTypePair
+	Cursor string
+	Err    error
+}
+
+// idScanCursor is the position of a scan, for each of its slices
+type idScanCursor struct {
+	PointInTime string              `json:"pit,omitempty"`
+	Slices      []idScanSliceCursor `json:"slices"`
+}
+
+type idScanSliceCursor struct {
+	After []interface{} `json:"after,omitempty"`
+	Done  bool          `json:"done,omitempty"`
+}
+
+func newIDScanCursor(scan IDScan) (*idScanCursor, error) {
+	if scan.Cursor != "" {
+		return decodeIDScanCursor(scan.Cursor)
+	}
+
+	slices := scan.Slices
+	if slices < 1 {
+		slices = 1
+	}
+	if slices > MaxIDScanSlices {
+		return nil, fmt.Errorf("%w: at most %d slices can be scanned in parallel", ErrInvalidIDScan, MaxIDScanSlices)
+	}
+	return &idScanCursor{Slices: make([]idScanSliceCursor, slices)}, nil
+}
+
+func decodeIDScanCursor(encoded string) (*idScanCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	cursor := &idScanCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if len(cursor.Slices) < 1 || len(cursor.Slices) > MaxIDScanSlices {
+		return nil, fmt.Errorf("%w: unexpected number of slices %d", ErrInvalidCursor, len(cursor.Slices))
+	}
+	return cursor, nil
+}
+
+func (c *idScanCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// idScanState tracks the position of the slices of a scan and sends their pages in turn, so that the cursor of a
+// page never claims IDs that were not sent yet
+type idScanState struct {
+	sync.Mutex
+	cursor *idScanCursor
+	pages  chan IDScanPage
+}
+
+func newIDScanState(cursor *idScanCursor) *idScanState {
+	return &idScanState{cursor: cursor, pages: make(chan IDScanPage)}
+}
+
+// send sends the IDs of a slice page and moves the slice after them, last marks the slice as scanned completely.
+// It returns false if cancelled is closed before the page is sent.
+func (s *idScanState) send(slice int, ids []EsIDTypePair, after []interface{}, last bool, pointInTime string, cancelled <-chan struct{}) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cursor.Slices[slice] = idScanSliceCursor{After: after, Done: last}
+	if pointInTime != "" {
+		s.cursor.PointInTime = pointInTime
+	}
+	select {
+	case s.pages <- IDScanPage{IDs: ids, Cursor: s.cursor.encode()}:
+		return true
+	case <-cancelled:
+		return false
+	}
+}
+
+func (s *idScanState) sliceDone(slice int) {
+	s.Lock()
+	defer s.Unlock()
+	s.cursor.Slices[slice].Done = true
+}
+
+// fail sends the error of the scan along with the cursor resuming it
+func (s *idScanState) fail(err error, cancelled <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case s.pages <- IDScanPage{Cursor: s.cursor.encode(), Err: err}:
+	case <-cancelled:
+	}
+}
+
+// position returns where the slice is in the scan and the point in time it is scanned in
+func (s *idScanState) position(slice int) (after []interface{}, pointInTime string, slices int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.cursor.Slices[slice].After, s.cursor.PointInTime, len(s.cursor.Slices)
+}
+
+// pendingSlices returns the slices that are not scanned completely
+func (s *idScanState) pendingSlices() []int {
+	s.Lock()
+	defer s.Unlock()
+
+	var pending []int
+	for i, slice := range s.cursor.Slices {
+		if !slice.Done {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
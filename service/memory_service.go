@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MemoryBackend = "memory"
+
+	createdResult = "created"
+	updatedResult = "updated"
+	deletedResult = "deleted"
+)
+
+// memoryService is an EsService keeping the concept documents in memory. It follows the same write rules as the
+// elasticsearch backend (FT author memberships, preserved metrics, concordance cleanup) and is meant for unit tests
+// and local development.
+type memoryService struct {
+	sync.RWMutex
+	indexName string
+	// conceptTypes selects the write rules of the concept types by their converter, the default converters are used
+	// when it is nil
+	conceptTypes   *ConceptTypes
+	documents      map[string]json.RawMessage
+	versions       map[string]int64
+	getCurrentTime func() time.Time
+	// softDelete leaves a tombstone in tombstones for every deleted concept
+	softDelete bool
+	tombstones map[string]Tombstone
+	// audit appends a change record to history for every write and delete
+	audit   bool
+	history map[string][]ChangeRecord
+}
+
+func NewMemoryService(indexName string) EsService {
+	return newMemoryService(indexName)
+}
+
+func newMemoryService(indexName string) *memoryService {
+	return &memoryService{
+		indexName:      indexName,
+		documents:      make(map[string]json.RawMessage),
+		versions:       make(map[string]int64),
+		getCurrentTime: time.Now,
+		tombstones:     make(map[string]Tombstone),
+		history:        make(map[string][]ChangeRecord),
+	}
+}
+
+func (ms *memoryService) LoadData(ctx context.Context, conceptType string, uuid string, payload EsModel) (bool, *IndexResult, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	isMembership := ms.conceptTypes.Converter(conceptType) == MembershipConverter
+	storedID := uuid
+	if isMembership {
+		storedID = payload.(*EsMembershipModel).PersonId
+	}
+	// like the elasticsearch backend, the tombstone is checked before the If-Match precondition
+	if !isMembership && ms.rejects(ctx, uuid, payload) {
+		return false, nil, ErrConceptDeleted
+	}
+	if err := checkIfMatch(ctx, ms.readData(storedID)); err != nil {
+		return false, nil, err
+	}
+
+	if !isMembership {
+		if unchanged(ms.documents[uuid], payload) {
+			return false, &IndexResult{Index: ms.indexName, ID: uuid, Result: UnchangedResult, Version: ms.versions[uuid]}, nil
+		}
+		patch := ms.preservedFields(conceptType, uuid)
+		before := ms.documents[uuid]
+		res, err := ms.write(uuid, payload)
+		if err == nil {
+			delete(ms.tombstones, uuid)
+			ms.recordWrite(ctx, conceptType, uuid, before, payload)
+		}
+		if err != nil || patch == nil {
+			return err == nil, res, err
+		}
+		return true, res, ms.patch(uuid, patch)
+	}
+
+	emm := payload.(*EsMembershipModel)
+	if emm.OrganisationId != ftOrgUUID || len(emm.Memberships) < 1 || !isFtAuthor(emm.Memberships) { // drop as not FT Author
+		return false, nil, nil
+	}
+
+	personID := emm.PersonId
+	personType := ms.conceptTypes.PersonType()
+	if _, found := ms.documents[personID]; found {
+		return true, nil, ms.patch(personID, map[string]interface{}{"isFTAuthor": "true"})
+	}
+
+	now := ms.getCurrentTime().Format(time.RFC3339)
+	p := EsPersonConceptModel{
+		EsConceptModel: &EsConceptModel{
+			Id:           personID,
+			Type:         personType,
+			LastModified: now,
+			IndexedAt:    now,
+		},
+		IsFTAuthor: "true",
+	}
+	res, err := ms.write(personID, p)
+	if err == nil {
+		ms.recordWrite(ctx, personType, personID, nil, p)
+	}
+	return err == nil, res, err
+}
+
+// recordWrite appends the change record of writing the concept over the stored document to the history
+func (ms *memoryService) recordWrite(ctx context.Context, conceptType string, uuid string, before json.RawMessage, after interface{}) {
+	if !ms.audit {
+		return
+	}
+	record := newChangeRecord(ctx, conceptType, uuid, writeOperation, ms.getCurrentTime())
+	record.Changes, _ = diffDocuments(before, after)
+	ms.history[uuid] = append(ms.history[uuid], record)
+}
+
+// History returns the change records of a concept, latest first
+func (ms *memoryService) History(_ context.Context, uuid string) ([]ChangeRecord, error) {
+	if !ms.audit {
+		return nil, ErrHistoryDisabled
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+
+	recorded := ms.history[uuid]
+	records := make([]ChangeRecord, 0, len(recorded))
+	for i := len(recorded) - 1; i >= 0 && len(records) < MaxHistoryRecords; i-- {
+		records = append(records, recorded[i])
+	}
+	return records, nil
+}
+
+// preservedFields returns the fields of the stored document that are not sourced from the concept payload
+func (ms *memoryService) preservedFields(conceptType string, uuid string) map[string]interface{} {
+	source, found := ms.documents[uuid]
+	if !found {
+		return nil
+	}
+
+	stored := make(map[string]interface{})
+	if err := json.Unmarshal(source, &stored); err != nil {
+		return nil
+	}
+
+	preserved := map[string]interface{}{"metrics": stored["metrics"]}
+	if ms.conceptTypes.Converter(conceptType) == PersonConverter {
+		preserved["isFTAuthor"] = stored["isFTAuthor"]
+	}
+	return preserved
+}
+
+func (ms *memoryService) write(uuid string, payload interface{}) (*IndexResult, error) {
+	source, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	result := createdResult
+	if _, found := ms.documents[uuid]; found {
+		result = updatedResult
+	}
+	ms.documents[uuid] = source
+	ms.versions[uuid]++
+
+	return &IndexResult{Index: ms.indexName, ID: uuid, Result: result, Version: ms.versions[uuid]}, nil
+}
+
+func (ms *memoryService) patch(uuid string, payload interface{}) error {
+	source, found := ms.documents[uuid]
+	if !found {
+		return nil
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return err
+	}
+	// like a write, a patch moves the time the document was indexed at
+	patch["indexedAt"] = ms.getCurrentTime().Format(time.RFC3339)
+
+	mergeDocument(doc, patch)
+	_, err = ms.write(uuid, doc)
+	return err
+}
+
+// mergeDocument applies a partial document the same way the elasticsearch update API does
+func mergeDocument(doc map[string]interface{}, patch map[string]interface{}) {
+	for k, v := range patch {
+		if v == nil {
+			continue
+		}
+		patchObject, isObject := v.(map[string]interface{})
+		docObject, wasObject := doc[k].(map[string]interface{})
+		if isObject && wasObject {
+			mergeDocument(docObject, patchObject)
+			continue
+		}
+		doc[k] = v
+	}
+}
+
+func (ms *memoryService) ReadData(_ context.Context, uuid string) (*GetResult, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	return ms.readData(uuid), nil
+}
+
+// readData returns the stored document, which has no revision so that its entity tag is the hash of its content
+func (ms *memoryService) readData(uuid string) *GetResult {
+	source, found := ms.documents[uuid]
+	return &GetResult{Found: found, Index: ms.indexName, Source: source}
+}
+
+// rejects reports whether the concept was deleted after the document was last modified. The tombstone of a concept
+// written again is removed by the write.
+func (ms *memoryService) rejects(ctx context.Context, uuid string, payload interface{}) bool {
+	tombstone, found := ms.tombstones[uuid]
+	return found && tombstone.rejects(ctx, payload)
+}
+
+func (ms *memoryService) ReadTombstone(_ context.Context, uuid string) (*Tombstone, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	if tombstone, found := ms.tombstones[uuid]; found {
+		return &tombstone, nil
+	}
+	return nil, nil
+}
+
+func (ms *memoryService) DeleteData(ctx context.Context, conceptType string, uuid string) (*DeleteResult, error) {
+	return ms.deleteData(ctx, conceptType, uuid, TombstoneReasonDeleted)
+}
+
+func (ms *memoryService) deleteData(ctx context.Context, conceptType string, uuid string, reason string) (*DeleteResult, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	source, found := ms.documents[uuid]
+	if !found {
+		return &DeleteResult{Result: notFoundResult}, nil
+	}
+	delete(ms.documents, uuid)
+	delete(ms.versions, uuid)
+	if ms.softDelete {
+		ms.tombstones[uuid] = newTombstone(ctx, conceptType, uuid, reason, ms.getCurrentTime())
+	}
+	if ms.audit {
+		record := newChangeRecord(ctx, conceptType, uuid, deleteOperation, ms.getCurrentTime())
+		record.Changes, _ = diffDocuments(source, nil)
+		ms.history[uuid] = append(ms.history[uuid], record)
+	}
+	return &DeleteResult{Result: deletedResult}, nil
+}
+
+func (ms *memoryService) LoadBulkData(ctx context.Context, conceptType string, uuid string, payload interface{}) (bool, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if ms.rejects(ctx, uuid, payload) {
+		return false, ErrConceptDeleted
+	}
+	if unchanged(ms.documents[uuid], payload) {
+		return false, nil
+	}
+	before := ms.documents[uuid]
+	_, _ = ms.write(uuid, payload)
+	delete(ms.tombstones, uuid)
+	ms.recordWrite(ctx, conceptType, uuid, before, payload)
+	return true, nil
+}
+
+func (ms *memoryService) CleanupData(ctx context.Context, concept Concept) {
+	var cleaned []string
+	for _, uuid := range concept.ConcordedUUIDs() {
+		ms.RLock()
+		source, found := ms.documents[uuid]
+		ms.RUnlock()
+		// like the elasticsearch backend, only the concorded concepts that are stored are deleted
+		if !found {
+			continue
+		}
+		stored := EsConceptModel{}
+		_ = json.Unmarshal(source, &stored)
+		_, _ = ms.deleteData(ctx, stored.Type, uuid, TombstoneReasonConcorded)
+		cleaned = append(cleaned, uuid)
+	}
+
+	if ms.audit && len(cleaned) > 0 {
+		sort.Strings(cleaned)
+		record := newChangeRecord(ctx, "", concept.PreferredUUID(), cleanupOperation, ms.getCurrentTime())
+		record.ConcordedUUIDs = cleaned
+		ms.Lock()
+		ms.history[record.ConceptID] = append(ms.history[record.ConceptID], record)
+		ms.Unlock()
+	}
+}
+
+func (ms *memoryService) PatchUpdateConcept(_ context.Context, _ string, uuid string, payload PayloadPatch) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	// like a bulk update, a patch which cannot be applied is not reported to the caller
+	_ = ms.patch(uuid, payload)
+	return nil
+}
+
+func (ms *memoryService) CloseBulkProcessor(_ context.Context) error {
+	return nil
+}
+
+func (ms *memoryService) GetClusterHealth(_ context.Context) (*ClusterHealth, error) {
+	return &ClusterHealth{ClusterName: MemoryBackend, Status: greenStatus, NumberOfNodes: 1, NumberOfDataNodes: 1}, nil
+}
+
+func (ms *memoryService) CircuitBreaker() CircuitBreakerState {
+	return CircuitBreakerState{State: CircuitDisabled}
+}
+
+func (ms *memoryService) BulkUsage() BulkUsage {
+	return BulkUsage{}
+}
+
+// PlanWrite returns the actions of writing the concept, the memory backend has no indices
+func (ms *memoryService) PlanWrite(_ context.Context, conceptType string, concept Concept, payload EsModel, bulk bool) ([]PlannedAction, error) {
+	ms.RLock()
+	stored := make(map[string]storedConcept)
+	for _, uuid := range concept.ConcordedUUIDs() {
+		if source, found := ms.documents[uuid]; found {
+			esModel := EsConceptModel{}
+			_ = json.Unmarshal(source, &esModel)
+			stored[uuid] = storedConcept{conceptType: esModel.Type}
+		}
+	}
+	ms.RUnlock()
+	return planWrite(func(string) string { return "" }, ms.conceptTypes, conceptType, concept, payload, bulk, planCleanup(stored)), nil
+}
+
+func (ms *memoryService) IsIndexReadOnly(_ context.Context) (bool, string, error) {
+	return false, ms.indexName, nil
+}
+
+// GetAllIDs scans the concept IDs sorted by uuid, in a single slice
+func (ms *memoryService) GetAllIDs(ctx context.Context, scan IDScan) (chan IDScanPage, error) {
+	cursor, err := newIDScanCursor(scan)
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor.Slices) > 1 {
+		return nil, fmt.Errorf("%w: the backend does not support parallel scans", ErrInvalidIDScan)
+	}
+	var after string
+	if position := cursor.Slices[0].After; len(position) == 1 {
+		after, _ = position[0].(string)
+	}
+
+	ms.RLock()
+	var pairs []EsIDTypePair
+	for uuid, source := range ms.documents {
+		if cursor.Slices[0].Done || uuid <= after {
+			continue
+		}
+		esModel := EsConceptModel{}
+		if err := json.Unmarshal(source, &esModel); err != nil || !scan.Filter.matches(source) {
+			continue
+		}
+		pairs = append(pairs, scan.pair(uuid, esModel))
+	}
+	ms.RUnlock()
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].ID < pairs[j].ID })
+
+	state := newIDScanState(cursor)
+	go func() {
+		defer close(state.pages)
+		for start := 0; start < len(pairs); start += idScanPageSize {
+			end := start + idScanPageSize
+			if end > len(pairs) {
+				end = len(pairs)
+			}
+			if !state.send(0, pairs[start:end], []interface{}{pairs[end-1].ID}, end == len(pairs), "", ctx.Done()) {
+				return
+			}
+		}
+	}()
+	return state.pages, nil
+}
+
+func (ms *memoryService) ExportConcepts(ctx context.Context, filter ExportFilter) (chan ExportedConcept, error) {
+	ms.RLock()
+	uuids := make([]string, 0, len(ms.documents))
+	for uuid := range ms.documents {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var exported []json.RawMessage
+	for _, uuid := range uuids {
+		if !filter.matches(ms.documents[uuid]) {
+			continue
+		}
+		source, err := filter.project(ms.documents[uuid])
+		if err != nil {
+			ms.RUnlock()
+			return nil, err
+		}
+		exported = append(exported, source)
+	}
+	ms.RUnlock()
+
+	docs := make(chan ExportedConcept)
+	go func() {
+		defer close(docs)
+		for _, source := range exported {
+			select {
+			case docs <- ExportedConcept{Source: source}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return docs, nil
+}
+
+func (ms *memoryService) CountConcepts(_ context.Context, filter ConceptFilter) (int64, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	var count int64
+	for _, source := range ms.documents {
+		if filter.matches(source) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWriteReadDelete(t *testing.T) {
+	service := NewMemoryService(indexName)
+	testUUID := uuid.New().String()
+
+	payload, up, resp, err := writeTestDocument(service, organisationsType, testUUID)
+	require.NoError(t, err)
+	assert.True(t, up, "updated was true")
+	assert.Equal(t, createdResult, resp.Result)
+	assert.Equal(t, indexName, resp.Index)
+	assert.Equal(t, testUUID, resp.ID)
+
+	_, _, resp, err = writeTestDocument(service, organisationsType, testUUID)
+	require.NoError(t, err)
+	assert.Equal(t, updatedResult, resp.Result)
+	assert.Equal(t, int64(2), resp.Version)
+
+	getResp, err := service.ReadData(context.Background(), testUUID)
+	require.NoError(t, err)
+	require.True(t, getResp.Found)
+	var actual EsConceptModel
+	require.NoError(t, json.Unmarshal(getResp.Source, &actual))
+	assert.Equal(t, payload.PrefLabel, actual.PrefLabel)
+
+	deleteResp, err := service.DeleteData(newTestContext(), organisationsType, testUUID)
+	require.NoError(t, err)
+	assert.Equal(t, deletedResult, deleteResp.Result)
+
+	deleteResp, err = service.DeleteData(newTestContext(), organisationsType, testUUID)
+	require.NoError(t, err)
+	assert.Equal(t, notFoundResult, deleteResp.Result)
+
+	getResp, err = service.ReadData(context.Background(), testUUID)
+	require.NoError(t, err)
+	assert.False(t, getResp.Found)
+}
+
+func TestMemoryWritePreservesMetricsAndFTAuthor(t *testing.T) {
+	service := NewMemoryService(indexName)
+	testUUID := uuid.New().String()
+
+	_, _, _, err := writeTestDocument(service, person, testUUID)
+	require.NoError(t, err)
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), person, testUUID, &EsPersonConceptPatch{Metrics: &ConceptMetrics{AnnotationsCount: 10}, IsFTAuthor: "true"}))
+
+	_, _, _, err = writeTestDocument(service, person, testUUID)
+	require.NoError(t, err)
+
+	getResp, err := service.ReadData(context.Background(), testUUID)
+	require.NoError(t, err)
+	var actual EsPersonConceptModel
+	require.NoError(t, json.Unmarshal(getResp.Source, &actual))
+	assert.Equal(t, "true", actual.IsFTAuthor)
+	require.NotNil(t, actual.Metrics)
+	assert.Equal(t, 10, actual.Metrics.AnnotationsCount)
+}
+
+func TestMemoryPatchMovesIndexedAt(t *testing.T) {
+	service := newMemoryService(indexName)
+	service.getCurrentTime = func() time.Time { return time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC) }
+
+	_, err := service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Id: "1", Type: "genres", LastModified: "2024-06-01T00:00:00Z", IndexedAt: "2024-06-01T00:00:00Z"})
+	require.NoError(t, err)
+	require.NoError(t, service.PatchUpdateConcept(context.Background(), "genres", "1", &EsConceptModelPatch{Metrics: &ConceptMetrics{AnnotationsCount: 10}}))
+
+	getResp, err := service.ReadData(context.Background(), "1")
+	require.NoError(t, err)
+	var actual EsConceptModel
+	require.NoError(t, json.Unmarshal(getResp.Source, &actual))
+	assert.Equal(t, "2024-06-01T00:00:00Z", actual.LastModified)
+	assert.Equal(t, "2024-06-03T12:00:00Z", actual.IndexedAt, "a patch should move the time the document was indexed at")
+}
+
+func TestMemoryUnchangedConceptsAreNotWritten(t *testing.T) {
+	service := NewMemoryService(indexName)
+	payload := &EsConceptModel{Id: "1234", Type: "genres", PrefLabel: "Lex"}
+	payload.updateContentHash()
+
+	up, resp, err := service.LoadData(newTestContext(), "genres", "1234", payload)
+	require.NoError(t, err)
+	assert.True(t, up)
+	assert.Equal(t, createdResult, resp.Result)
+
+	up, resp, err = service.LoadData(newTestContext(), "genres", "1234", payload)
+	require.NoError(t, err)
+	assert.False(t, up)
+	assert.Equal(t, UnchangedResult, resp.Result)
+	assert.Equal(t, int64(1), resp.Version)
+	written, err := service.LoadBulkData(context.Background(), "genres", "1234", payload)
+	require.NoError(t, err)
+	assert.False(t, written)
+
+	payload.PrefLabel = "Lex column"
+	payload.updateContentHash()
+	written, err = service.LoadBulkData(context.Background(), "genres", "1234", payload)
+	require.NoError(t, err)
+	assert.True(t, written)
+}
+
+func TestMemoryConditionalWrite(t *testing.T) {
+	service := NewMemoryService(indexName)
+	payload := &EsConceptModel{Id: "1234", Type: "genres", PrefLabel: "Lex"}
+
+	_, _, err := service.LoadData(WithIfMatch(newTestContext(), "*"), "genres", "1234", payload)
+	assert.ErrorIs(t, err, ErrPreconditionFailed, "there is no concept to match")
+
+	_, _, err = service.LoadData(newTestContext(), "genres", "1234", payload)
+	require.NoError(t, err)
+	stored, err := service.ReadData(context.Background(), "1234")
+	require.NoError(t, err)
+
+	payload.PrefLabel = "Lex column"
+	up, _, err := service.LoadData(WithIfMatch(newTestContext(), stored.ETag()), "genres", "1234", payload)
+	require.NoError(t, err)
+	assert.True(t, up)
+
+	payload.PrefLabel = "Lex columns"
+	_, _, err = service.LoadData(WithIfMatch(newTestContext(), stored.ETag()), "genres", "1234", payload)
+	assert.ErrorIs(t, err, ErrPreconditionFailed, "the concept changed since it was read")
+}
+
+func TestMemorySoftDelete(t *testing.T) {
+	service := newMemoryService(indexName)
+	service.softDelete = true
+	service.getCurrentTime = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	_, _, _, err := writeTestDocument(service, organisationsType, "1")
+	require.NoError(t, err)
+	_, err = service.DeleteData(newTestContext(), organisationsType, "1")
+	require.NoError(t, err)
+
+	tombstone, err := service.ReadTombstone(context.Background(), "1")
+	require.NoError(t, err)
+	require.NotNil(t, tombstone)
+	assert.Equal(t, Tombstone{ID: "1", ConceptType: organisationsType, DeletedAt: "2024-06-01T00:00:00Z", TransactionID: testTID, Reason: TombstoneReasonDeleted}, *tombstone)
+
+	late := &EsConceptModel{Id: "1", Type: organisationsType, LastModified: "2024-05-31T23:59:59Z"}
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1", late)
+	assert.ErrorIs(t, err, ErrConceptDeleted)
+	_, _, err = service.LoadData(WithIfMatch(newTestContext(), `"etag"`), organisationsType, "1", late)
+	assert.ErrorIs(t, err, ErrConceptDeleted, "like the elasticsearch backend, the tombstone is checked before the If-Match precondition")
+	_, _, err = service.LoadData(WithIfMatch(WithRecreate(newTestContext()), `"etag"`), organisationsType, "1", late)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	tombstone, err = service.ReadTombstone(context.Background(), "1")
+	require.NoError(t, err)
+	assert.NotNil(t, tombstone, "the tombstone is kept when the write fails its precondition")
+	_, err = service.LoadBulkData(newTestContext(), organisationsType, "1", late)
+	assert.ErrorIs(t, err, ErrConceptDeleted)
+
+	untimestamped, err := ConvertAggregateConceptToESConceptModel(AggregateConceptModel{PrefUUID: "1", PrefLabel: "Late republish", DirectType: "Organisation",
+		SourceRepresentations: []SourceConcept{{UUID: "1", Authority: "Smartlogic"}}}, organisationsType, testTID, publicAPIHost)
+	require.NoError(t, err)
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1", untimestamped)
+	assert.ErrorIs(t, err, ErrConceptDeleted, "a republish without an upstream lastModified is rejected even though it is indexed after the deletion")
+	_, err = service.LoadBulkData(newTestContext(), organisationsType, "1", untimestamped)
+	assert.ErrorIs(t, err, ErrConceptDeleted)
+	up, _, err := service.LoadData(WithRecreate(newTestContext()), organisationsType, "1", late)
+	require.NoError(t, err)
+	assert.True(t, up, "an explicit re-create is written")
+	tombstone, err = service.ReadTombstone(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Nil(t, tombstone, "the tombstone of a re-created concept is removed")
+	_, err = service.DeleteData(newTestContext(), organisationsType, "1")
+	require.NoError(t, err)
+
+	republished := &EsConceptModel{Id: "1", Type: organisationsType, LastModified: "2024-06-02T00:00:00Z"}
+	up, _, err = service.LoadData(newTestContext(), organisationsType, "1", republished)
+	require.NoError(t, err)
+	assert.True(t, up)
+	tombstone, err = service.ReadTombstone(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Nil(t, tombstone, "the tombstone of a concept written again is removed")
+
+	_, _, _, err = writeTestDocument(service, "genres", "2")
+	require.NoError(t, err)
+	service.CleanupData(newTestContext(), AggregateConceptModel{PrefUUID: "1", SourceRepresentations: []SourceConcept{{UUID: "1"}, {UUID: "2"}}})
+	tombstone, err = service.ReadTombstone(context.Background(), "2")
+	require.NoError(t, err)
+	require.NotNil(t, tombstone)
+	assert.Equal(t, "genres", tombstone.ConceptType)
+	assert.Equal(t, TombstoneReasonConcorded, tombstone.Reason)
+
+	res, err := service.DeleteData(newTestContext(), organisationsType, "3")
+	require.NoError(t, err)
+	assert.Equal(t, notFoundResult, res.Result)
+	service.CleanupData(newTestContext(), AggregateConceptModel{PrefUUID: "1", SourceRepresentations: []SourceConcept{{UUID: "1"}, {UUID: "4"}}})
+	for _, uuid := range []string{"3", "4"} {
+		tombstone, err = service.ReadTombstone(context.Background(), uuid)
+		require.NoError(t, err)
+		assert.Nil(t, tombstone, "a concept that was not stored leaves no tombstone")
+	}
+	first := &EsConceptModel{Id: "3", Type: organisationsType, LastModified: "2024-05-01T00:00:00Z"}
+	up, _, err = service.LoadData(newTestContext(), organisationsType, "3", first)
+	require.NoError(t, err)
+	assert.True(t, up, "the first write of a concept deleted before it was stored is not rejected")
+}
+
+func TestMemoryHistory(t *testing.T) {
+	service := newMemoryService(indexName)
+	service.getCurrentTime = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	_, err := service.History(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrHistoryDisabled)
+
+	service.audit = true
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1", Type: organisationsType, PrefLabel: "Old label", LastModified: "2024-05-01T00:00:00Z"})
+	require.NoError(t, err)
+	_, _, err = service.LoadData(newTestContext(), organisationsType, "1", &EsConceptModel{Id: "1", Type: organisationsType, PrefLabel: "New label", LastModified: "2024-05-02T00:00:00Z"})
+	require.NoError(t, err)
+	_, _, _, err = writeTestDocument(service, "genres", "2")
+	require.NoError(t, err)
+	service.CleanupData(newTestContext(), AggregateConceptModel{PrefUUID: "1", SourceRepresentations: []SourceConcept{{UUID: "1"}, {UUID: "2"}}})
+	_, err = service.DeleteData(newTestContext(), organisationsType, "1")
+	require.NoError(t, err)
+
+	history, err := service.History(context.Background(), "1")
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	assert.Equal(t, []string{deleteOperation, cleanupOperation, writeOperation, writeOperation},
+		[]string{history[0].Operation, history[1].Operation, history[2].Operation, history[3].Operation}, "the latest change record comes first")
+
+	assert.Equal(t, map[string]FieldChange{"prefLabel": {Before: "Old label", After: "New label"}}, history[2].Changes, "only the changed fields are recorded")
+	assert.Equal(t, testTID, history[2].TransactionID)
+	assert.Equal(t, "2024-06-01T00:00:00Z", history[2].Timestamp)
+	assert.Equal(t, FieldChange{After: "Old label"}, history[3].Changes["prefLabel"])
+	assert.NotContains(t, history[3].Changes, "lastModified", "the write metadata is not recorded")
+	assert.Equal(t, []string{"2"}, history[1].ConcordedUUIDs)
+	assert.Equal(t, FieldChange{Before: "New label"}, history[0].Changes["prefLabel"])
+}
+
+func TestMemoryConvertersOfRenamedTypes(t *testing.T) {
+	conceptTypes, err := NewConceptTypes(map[string]ConceptTypeConfig{
+		"authors":   {Read: true, Write: true, Converter: PersonConverter},
+		"companies": {Read: true, Write: true, Converter: OrganisationConverter},
+		"roles":     {Read: true, Write: true, Converter: MembershipConverter},
+		"labels":    {Read: true, Write: true, Converter: ConceptConverter},
+	})
+	require.NoError(t, err)
+	service := newMemoryService(indexName)
+	service.conceptTypes = conceptTypes
+
+	author := &EsPersonConceptModel{EsConceptModel: &EsConceptModel{Id: "1", Type: "authors", PrefLabel: "Lex"}, IsFTAuthor: "false"}
+	_, _, err = service.LoadData(newTestContext(), "authors", "1", author)
+	require.NoError(t, err)
+	_, _, err = service.LoadData(newTestContext(), "companies", "2", &EsConceptModel{Id: "2", Type: "companies"})
+	require.NoError(t, err)
+	_, _, err = service.LoadData(newTestContext(), "labels", "3", &EsConceptModel{Id: "3", Type: "labels"})
+	require.NoError(t, err)
+
+	roles := []string{journalistUUID}
+	up, _, err := service.LoadData(newTestContext(), "roles", "4", &EsMembershipModel{Id: "4", PersonId: "1", OrganisationId: ftOrgUUID, Memberships: roles})
+	require.NoError(t, err)
+	assert.True(t, up)
+	up, _, err = service.LoadData(newTestContext(), "roles", "5", &EsMembershipModel{Id: "5", PersonId: "6", OrganisationId: ftOrgUUID, Memberships: roles})
+	require.NoError(t, err)
+	assert.True(t, up)
+
+	for _, id := range []string{"4", "5"} {
+		stored, err := service.ReadData(context.Background(), id)
+		require.NoError(t, err)
+		assert.False(t, stored.Found, "a membership is not stored as a concept of its own")
+	}
+
+	var stored EsPersonConceptModel
+	readDocument(t, service, "6", &stored)
+	assert.Equal(t, "authors", stored.Type, "the person of a membership is written as the person type")
+	assert.Equal(t, "true", stored.IsFTAuthor)
+
+	_, _, err = service.LoadData(newTestContext(), "authors", "1", &EsPersonConceptModel{EsConceptModel: &EsConceptModel{Id: "1", Type: "authors", PrefLabel: "Lex column"}, IsFTAuthor: "false"})
+	require.NoError(t, err)
+	readDocument(t, service, "1", &stored)
+	assert.Equal(t, "Lex column", stored.PrefLabel)
+	assert.Equal(t, "true", stored.IsFTAuthor, "the FT author flag of a person is kept when it is written again")
+}
+
+func readDocument(t *testing.T, service EsService, uuid string, v interface{}) {
+	result, err := service.ReadData(context.Background(), uuid)
+	require.NoError(t, err)
+	require.True(t, result.Found)
+	require.NoError(t, json.Unmarshal(result.Source, v))
+}
+
+func TestMemoryMembership(t *testing.T) {
+	testCases := []struct {
+		name         string
+		organisation string
+		roles        []string
+		existing     bool
+		updated      bool
+		found        bool
+	}{
+		{
+			name:         "FT journalist without person writes a dummy person",
+			organisation: ftOrgUUID,
+			roles:        []string{journalistUUID},
+			updated:      true,
+			found:        true,
+		},
+		{
+			name:         "FT columnist updates the person",
+			organisation: ftOrgUUID,
+			roles:        []string{columnistUUID},
+			existing:     true,
+			updated:      true,
+			found:        true,
+		},
+		{
+			name:         "Not an FT author is dropped",
+			organisation: ftOrgUUID,
+			roles:        []string{uuid.New().String()},
+		},
+		{
+			name:         "Not an FT membership is dropped",
+			organisation: uuid.New().String(),
+			roles:        []string{journalistUUID},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewMemoryService(indexName)
+			personUUID := uuid.New().String()
+			if tc.existing {
+				_, _, _, err := writeTestDocument(service, person, personUUID)
+				require.NoError(t, err)
+			}
+
+			membership := &EsMembershipModel{
+				Id:             uuid.New().String(),
+				PersonId:       personUUID,
+				OrganisationId: tc.organisation,
+				Memberships:    tc.roles,
+			}
+			up, _, err := service.LoadData(newTestContext(), memberships, membership.Id, membership)
+			require.NoError(t, err)
+			assert.Equal(t, tc.updated, up)
+
+			getResp, err := service.ReadData(context.Background(), personUUID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.found, getResp.Found)
+			if !tc.found {
+				return
+			}
+			var actual EsPersonConceptModel
+			require.NoError(t, json.Unmarshal(getResp.Source, &actual))
+			assert.Equal(t, "true", actual.IsFTAuthor)
+			assert.Equal(t, person, actual.Type)
+		})
+	}
+}
+
+func TestMemoryCleanup(t *testing.T) {
+	service := NewMemoryService(indexName)
+	prefUUID := uuid.New().String()
+	concordedUUID := uuid.New().String()
+
+	for _, id := range []string{prefUUID, concordedUUID} {
+		_, _, _, err := writeTestDocument(service, organisationsType, id)
+		require.NoError(t, err)
+	}
+
+	service.CleanupData(newTestContext(), AggregateConceptModel{
+		PrefUUID:              prefUUID,
+		SourceRepresentations: []SourceConcept{{UUID: prefUUID}, {UUID: concordedUUID}},
+	})
+
+	getResp, err := service.ReadData(context.Background(), prefUUID)
+	require.NoError(t, err)
+	assert.True(t, getResp.Found)
+
+	getResp, err = service.ReadData(context.Background(), concordedUUID)
+	require.NoError(t, err)
+	assert.False(t, getResp.Found)
+}
+
+func TestMemoryGetAllIDs(t *testing.T) {
+	service := NewMemoryService(indexName)
+	service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Type: "genres", Authorities: []string{"TME"}})
+	service.LoadBulkData(context.Background(), person, "2", EsConceptModel{Type: "people", Authorities: []string{"Wikidata"}})
+
+	all, _ := scanIDs(t, service, IDScan{IncludeTypes: true})
+	assert.Equal(t, []EsIDTypePair{{ID: "1", Type: "genres"}, {ID: "2", Type: "people"}}, all)
+
+	notFTPink := ConceptFilter{}
+	notFTPink.ExcludeFTPinkAuthorities()
+	ids, _ := scanIDs(t, service, IDScan{Filter: notFTPink})
+	assert.Equal(t, []EsIDTypePair{{ID: "2"}}, ids)
+
+	_, err := service.GetAllIDs(context.Background(), IDScan{Slices: 2})
+	assert.ErrorIs(t, err, ErrInvalidIDScan)
+}
+
+func TestMemoryGetAllIDsResumesFromCursor(t *testing.T) {
+	service := NewMemoryService(indexName)
+	var expected []EsIDTypePair
+	for i := 0; i < idScanPageSize+1; i++ {
+		id := uuid.New().String()
+		service.LoadBulkData(context.Background(), "genres", id, EsConceptModel{Type: "genres"})
+		expected = append(expected, EsIDTypePair{ID: id})
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+
+	pages, err := service.GetAllIDs(context.Background(), IDScan{})
+	require.NoError(t, err)
+	first := <-pages
+	assert.Equal(t, expected[:idScanPageSize], first.IDs)
+
+	rest, cursor := scanIDs(t, service, IDScan{Cursor: first.Cursor})
+	assert.Equal(t, expected[idScanPageSize:], rest)
+
+	ids, _ := scanIDs(t, service, IDScan{Cursor: cursor})
+	assert.Empty(t, ids, "a complete scan should not be resumed")
+}
+
+func scanIDs(t *testing.T, service EsService, scan IDScan) ([]EsIDTypePair, string) {
+	pages, err := service.GetAllIDs(context.Background(), scan)
+	require.NoError(t, err)
+
+	var ids []EsIDTypePair
+	var cursor string
+	for page := range pages {
+		require.NoError(t, page.Err)
+		ids = append(ids, page.IDs...)
+		cursor = page.Cursor
+	}
+	return ids, cursor
+}
+
+func TestMemoryExportConcepts(t *testing.T) {
+	service := NewMemoryService(indexName)
+	service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Id: "1", Type: "genres", PrefLabel: "Lex", Authorities: []string{"TME"}, LastModified: "2024-01-01T00:00:00Z"})
+	service.LoadBulkData(context.Background(), "genres", "2", EsConceptModel{Id: "2", Type: "genres", PrefLabel: "Markets", Authorities: []string{"Smartlogic"}, LastModified: "2024-02-01T00:00:00Z", IsDeprecated: true})
+	service.LoadBulkData(context.Background(), person, "3", EsConceptModel{Id: "3", Type: person, PrefLabel: "Jane Doe", Authorities: []string{"TME"}, LastModified: "2024-03-01T00:00:00Z"})
+
+	export := func(filter ExportFilter) []string {
+		docs, err := service.ExportConcepts(context.Background(), filter)
+		require.NoError(t, err)
+		var exported []string
+		for doc := range docs {
+			esModel := EsConceptModel{}
+			require.NoError(t, json.Unmarshal(doc.Source, &esModel))
+			exported = append(exported, esModel.Id)
+		}
+		return exported
+	}
+	notDeprecated := false
+
+	assert.Equal(t, []string{"1", "2", "3"}, export(ExportFilter{}))
+	assert.Equal(t, []string{"1", "2"}, export(ExportFilter{ConceptFilter: ConceptFilter{Types: []string{"genres"}}}))
+	assert.Equal(t, []string{"1", "3"}, export(ExportFilter{ConceptFilter: ConceptFilter{Authorities: []string{"TME"}}}))
+	assert.Equal(t, []string{"2"}, export(ExportFilter{ConceptFilter: ConceptFilter{
+		ModifiedFrom: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		ModifiedTo:   time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+	}}))
+	assert.Equal(t, []string{"1", "3"}, export(ExportFilter{ConceptFilter: ConceptFilter{IsDeprecated: &notDeprecated}}))
+
+	docs, err := service.ExportConcepts(context.Background(), ExportFilter{ConceptFilter: ConceptFilter{Types: []string{person}}, Fields: []string{"prefLabel"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"3","prefLabel":"Jane Doe"}`, string((<-docs).Source))
+}
+
+func TestMemoryCountConcepts(t *testing.T) {
+	service := NewMemoryService(indexName)
+	service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Type: "genres", Authorities: []string{"TME"}})
+	service.LoadBulkData(context.Background(), person, "2", EsPersonConceptModel{EsConceptModel: &EsConceptModel{Type: person, Authorities: []string{"Wikidata"}}, IsFTAuthor: "true"})
+	service.LoadBulkData(context.Background(), person, "3", EsConceptModel{Type: person, Authorities: []string{"Smartlogic", "Wikidata"}})
+
+	count := func(filter ConceptFilter) int64 {
+		count, err := service.CountConcepts(context.Background(), filter)
+		require.NoError(t, err)
+		return count
+	}
+	ftAuthor, notFTAuthor := true, false
+
+	assert.Equal(t, int64(3), count(ConceptFilter{}))
+	assert.Equal(t, int64(2), count(ConceptFilter{Types: []string{person}}))
+	assert.Equal(t, int64(1), count(ConceptFilter{Types: []string{person}, IsFTAuthor: &ftAuthor}))
+	assert.Equal(t, int64(2), count(ConceptFilter{IsFTAuthor: &notFTAuthor}))
+	assert.Equal(t, int64(2), count(ConceptFilter{Authorities: []string{"Wikidata"}}))
+	assert.Equal(t, int64(1), count(ConceptFilter{Authorities: []string{"Wikidata"}, ExcludedAuthorities: []string{"Smartlogic"}}))
+}
@@ -1,11 +1,18 @@
 package service
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Concept contains common function between both concept models
 type Concept interface {
 	// GetAuthorities returns an array containing all authorities that this concept is identified by
 	GetAuthorities() []string
 	// ConcordedUUIDs returns an array containing all concorded concept uuids - N.B. it will not contain the canonical prefUUID.
 	ConcordedUUIDs() []string
+	// LastModified returns when the concept was last modified upstream, the zero time if it is unknown
+	LastModified() time.Time
 
 	PreferredUUID() string
 }
@@ -21,6 +28,7 @@ type ConceptModel struct {
 	AlternativeIdentifiers map[string]interface{} `json:"alternativeIdentifiers,omitempty"`
 	IsDeprecated           bool                   `json:"isDeprecated,omitempty"`
 	ScopeNote              string                 `json:"scopeNote,omitempty"`
+	LastModifiedEpoch      int64                  `json:"lastModifiedEpoch,omitempty"`
 }
 
 type AggregateMembershipRole struct {
@@ -49,11 +57,14 @@ type AggregateConceptModel struct {
 	SourceRepresentations []SourceConcept `json:"sourceRepresentations"`
 	// NAICS
 	NAICS []NAICS `json:"naicsIndustryClassifications,omitempty"`
+	// AggregateHash is the hash of the concept in the upstream concept store
+	AggregateHash string `json:"aggregateHash,omitempty"`
 }
 
 type SourceConcept struct {
-	UUID      string `json:"uuid"`
-	Authority string `json:"authority"`
+	UUID              string `json:"uuid"`
+	Authority         string `json:"authority"`
+	LastModifiedEpoch int64  `json:"lastModifiedEpoch,omitempty"`
 }
 
 type NAICS struct {
@@ -71,7 +82,8 @@ type EsConceptModel struct {
 	Authorities            []string        `json:"authorities"`
 	DirectType             string          `json:"directType"`
 	Aliases                []string        `json:"aliases,omitempty"`
-	LastModified           string          `json:"lastModified"`
+	LastModified           string          `json:"lastModified"` // upstream modification time, the indexing time if unknown
+	IndexedAt              string          `json:"indexedAt,omitempty"`
 	PublishReference       string          `json:"publishReference"`
 	IsDeprecated           bool            `json:"isDeprecated,omitempty"` // stored only if this is true
 	ScopeNote              string          `json:"scopeNote,omitempty"`
@@ -79,6 +91,11 @@ type EsConceptModel struct {
 	CountryOfIncorporation string          `json:"countryOfIncorporation,omitempty"`
 	Metrics                *ConceptMetrics `json:"metrics,omitempty"`
 	NAICS                  []NAICS         `json:"NAICS,omitempty"`
+	AggregateHash          string          `json:"aggregateHash,omitempty"`
+	// ContentHash is the hash of the fields sourced from the concept payload, see updateContentHash
+	ContentHash string `json:"contentHash,omitempty"`
+	// lastModifiedUnknown is set when the payload had no upstream lastModified and LastModified is the indexing time
+	lastModifiedUnknown bool
 }
 
 type EsMembershipModel struct {
@@ -91,10 +108,55 @@ type EsMembershipModel struct {
 type EsIDTypePair struct {
 	ID   string `json:"id,omitempty"`
 	Type string `json:"type,omitempty"`
+	Hash string `json:"hash,omitempty"`
+}
+
+// IndexResult is the outcome of writing a concept document
+type IndexResult struct {
+	Index   string
+	ID      string
+	Result  string
+	Version int64
+}
+
+// GetResult is a concept document read from the store
+type GetResult struct {
+	Found  bool
+	Index  string
+	Source json.RawMessage
+	// SeqNo and PrimaryTerm identify the revision of the document, they are nil if the store does not return them
+	SeqNo       *int64
+	PrimaryTerm *int64
+}
+
+// DeleteResult is the outcome of deleting a concept document, Result is "not_found" if there was nothing to delete
+type DeleteResult struct {
+	Result string
+}
+
+// ClusterHealth describes the health of the cluster backing the store, serialised the same way as the _cluster/health API
+type ClusterHealth struct {
+	ClusterName                    string  `json:"cluster_name"`
+	Status                         string  `json:"status"`
+	TimedOut                       bool    `json:"timed_out"`
+	NumberOfNodes                  int     `json:"number_of_nodes"`
+	NumberOfDataNodes              int     `json:"number_of_data_nodes"`
+	ActivePrimaryShards            int     `json:"active_primary_shards"`
+	ActiveShards                   int     `json:"active_shards"`
+	RelocatingShards               int     `json:"relocating_shards"`
+	InitializingShards             int     `json:"initializing_shards"`
+	UnassignedShards               int     `json:"unassigned_shards"`
+	DelayedUnassignedShards        int     `json:"delayed_unassigned_shards"`
+	NumberOfPendingTasks           int     `json:"number_of_pending_tasks"`
+	NumberOfInFlightFetch          int     `json:"number_of_in_flight_fetch"`
+	TaskMaxWaitTimeInQueueInMillis int     `json:"task_max_waiting_in_queue_millis"`
+	ActiveShardsPercentAsNumber    float64 `json:"active_shards_percent_as_number"`
 }
 
 type EsConceptModelPatch struct {
 	Metrics *ConceptMetrics `json:"metrics"`
+	// IndexedAt is stamped when the patch is queued, see withIndexedAt
+	IndexedAt string `json:"indexedAt,omitempty"`
 }
 
 type ConceptMetrics struct {
@@ -110,6 +172,23 @@ type EsPersonConceptModel struct {
 type EsPersonConceptPatch struct {
 	Metrics    *ConceptMetrics `json:"metrics"`
 	IsFTAuthor string          `json:"isFTAuthor"`
+	IndexedAt  string          `json:"indexedAt,omitempty"`
+}
+
+// withIndexedAt returns a copy of the patch stamped with the time it is indexed at, so that indexedAt changes with
+// every write or patch of the document
+func withIndexedAt(patch PayloadPatch, indexedAt time.Time) PayloadPatch {
+	switch p := patch.(type) {
+	case *EsConceptModelPatch:
+		stamped := *p
+		stamped.IndexedAt = indexedAt.Format(time.RFC3339)
+		return &stamped
+	case *EsPersonConceptPatch:
+		stamped := *p
+		stamped.IndexedAt = indexedAt.Format(time.RFC3339)
+		return &stamped
+	}
+	return patch
 }
 
 func (c AggregateConceptModel) PreferredUUID() string {
@@ -144,6 +223,28 @@ func (c AggregateConceptModel) GetAuthorities() []string {
 	return authorities
 }
 
+func (c ConceptModel) LastModified() time.Time {
+	return epochTime(c.LastModifiedEpoch)
+}
+
+// LastModified returns the latest modification time of the source representations
+func (c AggregateConceptModel) LastModified() time.Time {
+	var latest int64
+	for _, src := range c.SourceRepresentations {
+		if src.LastModifiedEpoch > latest {
+			latest = src.LastModifiedEpoch
+		}
+	}
+	return epochTime(latest)
+}
+
+func epochTime(epoch int64) time.Time {
+	if epoch <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0).UTC()
+}
+
 func (c ConceptModel) ConcordedUUIDs() []string {
 	return make([]string, 0) // we don't want to remove concorded concepts for the original concept model.
 }
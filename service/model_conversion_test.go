@@ -700,6 +700,38 @@ func TestReverse(t *testing.T) {
 	}
 }
 
+func TestContentHashIgnoresWriteMetadata(t *testing.T) {
+	concept := AggregateConceptModel{PrefUUID: "2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", PrefLabel: "Apple, Inc.", DirectType: "Organisation", AggregateHash: "1234"}
+
+	first, err := ConvertAggregateConceptToESConceptModel(concept, "organisations", "tid_1", publicAPIHost)
+	require.NoError(t, err)
+	second, err := ConvertAggregateConceptToESConceptModel(concept, "organisations", "tid_2", publicAPIHost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.(*EsConceptModel).ContentHash)
+	assert.Equal(t, first.(*EsConceptModel).ContentHash, second.(*EsConceptModel).ContentHash)
+
+	concept.PrefLabel = "Apple"
+	changed, err := ConvertAggregateConceptToESConceptModel(concept, "organisations", "tid_1", publicAPIHost)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.(*EsConceptModel).ContentHash, changed.(*EsConceptModel).ContentHash)
+}
+
+func TestUpstreamLastModifiedIsKeptApartFromIndexedAt(t *testing.T) {
+	concept := AggregateConceptModel{PrefUUID: "2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", PrefLabel: "Apple, Inc.", DirectType: "Organisation",
+		SourceRepresentations: []SourceConcept{{UUID: "2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", Authority: "Smartlogic"}}}
+
+	esModel, err := ConvertAggregateConceptToESConceptModel(concept, "organisations", testTID, publicAPIHost)
+	require.NoError(t, err)
+	assert.Equal(t, esModel.(*EsConceptModel).IndexedAt, esModel.(*EsConceptModel).LastModified, "the indexing time is used when the upstream one is unknown")
+
+	concept.SourceRepresentations[0].LastModifiedEpoch = 1498127042
+	upstream, err := ConvertAggregateConceptToESConceptModel(concept, "organisations", testTID, publicAPIHost)
+	require.NoError(t, err)
+	assert.Equal(t, "2017-06-22T10:24:02Z", upstream.(*EsConceptModel).LastModified)
+	assert.NotEqual(t, upstream.(*EsConceptModel).LastModified, upstream.(*EsConceptModel).IndexedAt)
+	assert.Equal(t, esModel.(*EsConceptModel).ContentHash, upstream.(*EsConceptModel).ContentHash)
+}
+
 func TestValidateEsConceptModelMarshalling(t *testing.T) {
 	tests := []struct {
 		testName           string
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	ElasticsearchBackend = "elasticsearch"
+	OpenSearchBackend    = "opensearch"
+
+	greenStatus              = "green"
+	illegalArgumentException = "illegal_argument_exception"
+)
+
+// openSearchService is the EsService backend for Amazon OpenSearch, both managed domains and serverless collections.
+// Documents, bulk requests and scrolls are compatible with the elasticsearch backend, only the cluster administration
+// APIs differ: serverless collections do not expose cluster health nor index blocks.
+type openSearchService struct {
+	*esService
+}
+
+func NewOpenSearchService(ch chan *elastic.Client, indexName string, bulkProcessorConfig *BulkProcessorConfig, conceptTypes *ConceptTypes, tombstones *TombstoneConfig, audit *AuditConfig, retry *RetryConfig, timeouts *TimeoutConfig) EsService {
+	es := newEsService(ch, indexName, bulkProcessorConfig, conceptTypes, tombstones, audit, retry, timeouts)
+	// the point in time API of elasticsearch is not available in OpenSearch 1.x
+	es.pointInTime = false
+	return &openSearchService{esService: es}
+}
+
+// NewBackendService returns the EsService implementation for the given backend name.
+func NewBackendService(backend string, ch chan *elastic.Client, indexName string, bulkProcessorConfig *BulkProcessorConfig, conceptTypes *ConceptTypes, tombstones *TombstoneConfig, audit *AuditConfig, retry *RetryConfig, timeouts *TimeoutConfig) (EsService, error) {
+	switch backend {
+	case ElasticsearchBackend, "":
+		return NewEsService(ch, indexName, bulkProcessorConfig, conceptTypes, tombstones, audit, retry, timeouts), nil
+	case OpenSearchBackend:
+		return NewOpenSearchService(ch, indexName, bulkProcessorConfig, conceptTypes, tombstones, audit, retry, timeouts), nil
+	case MemoryBackend:
+		ms := newMemoryService(indexName)
+		ms.conceptTypes = conceptTypes
+		ms.softDelete = tombstones != nil
+		ms.audit = audit != nil
+		return ms, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+}
+
+func (oss *openSearchService) GetClusterHealth(ctx context.Context) (*ClusterHealth, error) {
+	health, err := oss.esService.GetClusterHealth(ctx)
+	if !isUnsupportedOperation(err) {
+		return health, err
+	}
+
+	ctx, cancel := oss.withDeadline(ctx, healthOperation)
+	defer cancel()
+
+	client, err := oss.client()
+	if err != nil {
+		return nil, err
+	}
+
+	// without the cluster health API the cluster is as healthy as the index is reachable
+	var exists bool
+	err = oss.do(ctx, healthOperation, func() (err error) {
+		exists, err = client.IndexExists(oss.indexName).Do(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", oss.indexName)
+	}
+	return &ClusterHealth{Status: greenStatus}, nil
+}
+
+func (oss *openSearchService) IsIndexReadOnly(ctx context.Context) (bool, string, error) {
+	ctx, cancel := oss.withDeadline(ctx, healthOperation)
+	defer cancel()
+
+	client, err := oss.client()
+	if err != nil {
+		return false, "", err
+	}
+
+	indices := oss.writeIndices()
+	var resp map[string]*elastic.IndicesGetSettingsResponse
+	err = oss.do(ctx, healthOperation, func() (err error) {
+		resp, err = client.IndexGetSettings(indices...).Do(ctx)
+		return err
+	})
+	if isUnsupportedOperation(err) {
+		// index blocks cannot be set on serverless collections
+		return false, strings.Join(indices, ", "), nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	return readOnlyIndex(resp, oss.isIndexReadOnly)
+}
+
+// isUnsupportedOperation reports whether the error is the answer of a serverless collection to a cluster administration
+// API it does not expose: a 404 of an unknown route, which unlike a missing index has no error type, or a 400 rejecting
+// the API as an illegal argument. Authentication and authorization failures are not, so that they reach the health
+// checks and the client supervisor.
+func isUnsupportedOperation(err error) bool {
+	var esErr *elastic.Error
+	if !errors.As(err, &esErr) {
+		return false
+	}
+	switch esErr.Status {
+	case http.StatusNotFound:
+		return esErr.Details == nil || esErr.Details.Type == ""
+	case http.StatusBadRequest:
+		return esErr.Details != nil && esErr.Details.Type == illegalArgumentException
+	}
+	return false
+}
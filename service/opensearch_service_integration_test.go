@@ -0,0 +1,111 @@
+//go:build integration
+// +build integration
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSearchWriteReadDelete(t *testing.T) {
+	service := getTestOpenSearchService(t)
+	defer service.elasticClient.Stop()
+
+	testUUID := uuid.New().String()
+	payload, up, resp, err := writeTestDocument(service, organisationsType, testUUID)
+	require.NoError(t, err, "expected successful write")
+	assert.True(t, up, "updated was true")
+	assert.Equal(t, esStatusCreated, resp.Result, "document should have been created")
+
+	_, err = service.elasticClient.Refresh(indexName).Do(context.Background())
+	require.NoError(t, err, "expected successful refresh")
+
+	getResp, err := service.ReadData(context.Background(), testUUID)
+	require.NoError(t, err, "expected successful read")
+	require.True(t, getResp.Found, "should find a result")
+
+	obj := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(getResp.Source, &obj))
+	assert.Equal(t, payload.PrefLabel, obj["prefLabel"], "prefLabel")
+
+	deleteResp, err := service.DeleteData(newTestContext(), organisationsType, testUUID)
+	require.NoError(t, err)
+	assert.Equal(t, esStatusDeleted, deleteResp.Result, "document is deleted")
+}
+
+func TestOpenSearchClusterHealth(t *testing.T) {
+	service := getTestOpenSearchService(t)
+	defer service.elasticClient.Stop()
+
+	health, err := service.GetClusterHealth(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, health.Status)
+}
+
+func TestOpenSearchIsReadOnly(t *testing.T) {
+	service := getTestOpenSearchService(t)
+	defer service.elasticClient.Stop()
+
+	readOnly, name, err := service.IsIndexReadOnly(context.Background())
+	assert.NoError(t, err, "read-only check should not return an error")
+	assert.False(t, readOnly, "index should not be read-only")
+	assert.Equal(t, indexName, name, "index name should be returned")
+
+	setReadOnly(t, service.elasticClient, indexName, true)
+	defer setReadOnly(t, service.elasticClient, indexName, false)
+
+	readOnly, _, err = service.IsIndexReadOnly(context.Background())
+	assert.NoError(t, err, "read-only check should not return an error")
+	assert.True(t, readOnly, "index should be read-only")
+}
+
+func TestOpenSearchGetAllIDs(t *testing.T) {
+	service := getTestOpenSearchService(t)
+	defer service.elasticClient.Stop()
+
+	testUUID := uuid.New().String()
+	_, _, _, err := writeTestDocument(service, organisationsType, testUUID)
+	require.NoError(t, err, "expected successful write")
+	defer deleteTestDocument(t, service.esService, organisationsType, testUUID)
+
+	_, err = service.elasticClient.Refresh(indexName).Do(context.Background())
+	require.NoError(t, err, "expected successful refresh")
+
+	pages, err := service.GetAllIDs(context.Background(), IDScan{IncludeTypes: true})
+	require.NoError(t, err)
+	found := false
+	for page := range pages {
+		require.NoError(t, page.Err)
+		for _, id := range page.IDs {
+			if id.ID == testUUID {
+				found = true
+				assert.Equal(t, organisationsType, id.Type)
+			}
+		}
+	}
+	assert.True(t, found, "written document should be listed")
+}
+
+func getTestOpenSearchService(t *testing.T) *openSearchService {
+	openSearchURL := os.Getenv("OPENSEARCH_TEST_URL")
+	if strings.TrimSpace(openSearchURL) == "" {
+		t.Skip("OPENSEARCH_TEST_URL is not set")
+	}
+
+	bulkProcessorConfig := NewBulkProcessorConfig(1, 1, 1, 100*time.Millisecond)
+	ec := getElasticClient(t, openSearchURL)
+
+	service := NewOpenSearchService(make(chan *elastic.Client), indexName, &bulkProcessorConfig, nil, nil, nil, nil, nil).(*openSearchService)
+	service.setElasticClient(ec)
+	return service
+}
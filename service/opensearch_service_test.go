@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSearchServerlessClusterHealth(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+		case "/_cluster/health":
+			w.WriteHeader(http.StatusNotFound)
+		case "/" + indexName:
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer es.Close()
+
+	service := &openSearchService{esService: &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName}}
+
+	health, err := service.GetClusterHealth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "green", health.Status)
+}
+
+func TestOpenSearchServerlessIndexNotFound(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer es.Close()
+
+	service := &openSearchService{esService: &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName}}
+
+	_, err := service.GetClusterHealth(context.Background())
+	assert.EqualError(t, err, "index concept not found")
+}
+
+func TestOpenSearchClusterHealthForbidden(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+		case "/_cluster/health":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"type":"security_exception"},"status":403}`))
+		default:
+			t.Errorf("a forbidden cluster health should not fall back to %s", r.URL.Path)
+		}
+	}))
+	defer es.Close()
+
+	service := &openSearchService{esService: &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName}}
+
+	_, err := service.GetClusterHealth(context.Background())
+	assert.True(t, elastic.IsForbidden(err), "expected the forbidden error, got %v", err)
+}
+
+func TestOpenSearchIsIndexReadOnly(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		settings string
+		readOnly bool
+		err      bool
+	}{
+		{
+			name:     "Write blocked as string",
+			status:   http.StatusOK,
+			settings: `{"concept":{"settings":{"index":{"blocks":{"write":"true"}}}}}`,
+			readOnly: true,
+		},
+		{
+			name:     "Write blocked as boolean",
+			status:   http.StatusOK,
+			settings: `{"concept":{"settings":{"index":{"blocks":{"write":true}}}}}`,
+			readOnly: true,
+		},
+		{
+			name:     "No blocks",
+			status:   http.StatusOK,
+			settings: `{"concept":{"settings":{"index":{"number_of_shards":"1"}}}}`,
+		},
+		{
+			name:     "Settings not supported",
+			status:   http.StatusBadRequest,
+			settings: `{"error":{"type":"illegal_argument_exception"},"status":400}`,
+		},
+		{
+			name:     "Index not found",
+			status:   http.StatusNotFound,
+			settings: `{"error":{"type":"index_not_found_exception"},"status":404}`,
+			err:      true,
+		},
+		{
+			name:     "Forbidden",
+			status:   http.StatusForbidden,
+			settings: `{"error":{"type":"security_exception"},"status":403}`,
+			err:      true,
+		},
+		{
+			name:     "Unauthorized",
+			status:   http.StatusUnauthorized,
+			settings: `{"message":"The security token included in the request is expired"}`,
+			err:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.settings))
+			}))
+			defer es.Close()
+
+			service := &openSearchService{esService: &esService{elasticClient: getElasticClient(t, es.URL), indexName: indexName}}
+
+			readOnly, name, err := service.IsIndexReadOnly(context.Background())
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.readOnly, readOnly)
+			assert.Equal(t, indexName, name)
+		})
+	}
+}
+
+func TestNewBackendService(t *testing.T) {
+	_, isES := mustNewBackendService(t, ElasticsearchBackend).(*esService)
+	assert.True(t, isES)
+
+	_, isOpenSearch := mustNewBackendService(t, OpenSearchBackend).(*openSearchService)
+	assert.True(t, isOpenSearch)
+
+	_, err := NewBackendService("solr", nil, indexName, nil, nil, nil, nil, nil, nil)
+	assert.EqualError(t, err, `unknown backend "solr"`)
+}
+
+func TestAWSSigningTransportServiceName(t *testing.T) {
+	testCases := []struct {
+		service        string
+		expectedScope  string
+		expectedSHA256 bool
+	}{
+		{service: "", expectedScope: "/eu-west-1/es/aws4_request"},
+		{service: SigningServiceES, expectedScope: "/eu-west-1/es/aws4_request"},
+		{service: SigningServiceAOSS, expectedScope: "/eu-west-1/aoss/aws4_request", expectedSHA256: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.service, func(t *testing.T) {
+			var received *http.Request
+			es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+			}))
+			defer es.Close()
+
+			transport := AWSSigningTransport{
+				HTTPClient:  es.Client(),
+				Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+				Region:      "eu-west-1",
+				Service:     tc.service,
+			}
+			req, err := http.NewRequest(http.MethodGet, es.URL, nil)
+			require.NoError(t, err)
+
+			_, err = transport.RoundTrip(req)
+			require.NoError(t, err)
+
+			assert.Contains(t, received.Header.Get("Authorization"), tc.expectedScope)
+			if tc.expectedSHA256 {
+				assert.Equal(t, payloadHash(nil), received.Header.Get("X-Amz-Content-Sha256"))
+			} else {
+				assert.Empty(t, received.Header.Get("X-Amz-Content-Sha256"))
+			}
+		})
+	}
+}
+
+func mustNewBackendService(t *testing.T, backend string) EsService {
+	ch := make(chan *elastic.Client)
+	close(ch)
+	service, err := NewBackendService(backend, ch, indexName, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	return service
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPreconditionFailed is the error of a conditional write whose If-Match does not match the stored concept
+var ErrPreconditionFailed = errors.New("the concept does not match the If-Match precondition")
+
+type ifMatchKey struct{}
+
+// WithIfMatch returns a context making LoadData write the concept only if the stored one matches the If-Match value,
+// a list of entity tags or * for any stored concept
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, ifMatch)
+}
+
+// ifMatchFromContext returns the If-Match value of the write, the empty string if it is not conditional
+func ifMatchFromContext(ctx context.Context) string {
+	ifMatch, _ := ctx.Value(ifMatchKey{}).(string)
+	return ifMatch
+}
+
+// ETag returns the entity tag of the document, derived from its sequence number and primary term when the store
+// returns them and from its content otherwise. It is empty if the document was not found.
+func (r *GetResult) ETag() string {
+	if r == nil || !r.Found {
+		return ""
+	}
+	if r.SeqNo != nil && r.PrimaryTerm != nil {
+		return fmt.Sprintf(`"%d-%d"`, *r.PrimaryTerm, *r.SeqNo)
+	}
+	sum := sha256.Sum256(r.Source)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// ETagMatches reports whether the entity tag is in the list of the If-Match or If-None-Match header, * matching any
+// entity tag. The weak comparison ignores the W/ prefix of the tags, the strong comparison never matches a weak tag.
+func ETagMatches(list string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch returns ErrPreconditionFailed if the write is conditional and the stored document does not match it
+func checkIfMatch(ctx context.Context, stored *GetResult) error {
+	ifMatch := ifMatchFromContext(ctx)
+	if ifMatch == "" || ETagMatches(ifMatch, stored.ETag(), false) {
+		return nil
+	}
+	return ErrPreconditionFailed
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	seqNo, primaryTerm := int64(12), int64(3)
+	assert.Equal(t, `"3-12"`, (&GetResult{Found: true, Source: json.RawMessage(`{}`), SeqNo: &seqNo, PrimaryTerm: &primaryTerm}).ETag())
+
+	etag := (&GetResult{Found: true, Source: json.RawMessage(`{"prefLabel":"Lex"}`)}).ETag()
+	assert.Len(t, etag, 66, "a quoted sha256 of the content")
+	assert.NotEqual(t, etag, (&GetResult{Found: true, Source: json.RawMessage(`{"prefLabel":"Lex column"}`)}).ETag())
+
+	assert.Empty(t, (&GetResult{Found: false}).ETag())
+	assert.Empty(t, (*GetResult)(nil).ETag())
+}
+
+func TestETagMatches(t *testing.T) {
+	testCases := []struct {
+		name  string
+		list  string
+		etag  string
+		weak  bool
+		match bool
+	}{
+		{name: "Same tag", list: `"3-12"`, etag: `"3-12"`, match: true},
+		{name: "Tag of a list", list: `"3-11", "3-12"`, etag: `"3-12"`, match: true},
+		{name: "Other tag", list: `"3-11"`, etag: `"3-12"`},
+		{name: "Any tag", list: "*", etag: `"3-12"`, match: true},
+		{name: "Any tag of a missing document", list: "*", etag: ""},
+		{name: "Weak tag in a strong comparison", list: `W/"3-12"`, etag: `"3-12"`},
+		{name: "Weak tag in a weak comparison", list: `W/"3-12"`, etag: `"3-12"`, weak: true, match: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.match, ETagMatches(tc.list, tc.etag, tc.weak))
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+var (
+	ErrReconcileRunning = errors.New("a reconciliation is already running")
+	// ErrUpstreamIncomplete is the reason the extra concepts are not deleted when the upstream list did not end with
+	// its completeness trailer
+	ErrUpstreamIncomplete = errors.New("the upstream concept list is not complete")
+	// ErrTooManyExtras is the reason the extra concepts are not deleted when there are more than the delete limits
+	ErrTooManyExtras = errors.New("too many extra concepts to delete")
+)
+
+// ReconcileConfig configures the reconciliation of the index with the upstream concept store
+type ReconcileConfig struct {
+	// SourceURL lists the upstream concepts, one {"uuid":"...","hash":"..."} JSON object per line, followed by a
+	// {"complete":true,"count":...} trailer with the number of concepts listed
+	SourceURL string
+	// DeleteExtras deletes the concepts of the index that are not upstream
+	DeleteExtras bool
+	// MaxDeletes is the most extra concepts deleted by a reconciliation, none is deleted when there are more, 0 is
+	// unbounded
+	MaxDeletes int
+	// MaxDeleteRatio is the largest share of the indexed concepts deleted by a reconciliation, none is deleted when
+	// the extra concepts are a larger share, 0 is unbounded
+	MaxDeleteRatio float64
+	// RepublishURL is posted the uuids of the missing and stale concepts as {"uuids":[...]}, nothing is republished
+	// when empty
+	RepublishURL string
+}
+
+// ReconcileReport is the difference between the upstream concepts and the index
+type ReconcileReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Upstream   int       `json:"upstream"`
+	// Complete tells whether the upstream list ended with its completeness trailer
+	Complete bool `json:"complete"`
+	Indexed  int  `json:"indexed"`
+	// Missing are the upstream concepts that are not in the index
+	Missing []string `json:"missing"`
+	// Extra are the concepts of the index that are not upstream
+	Extra []string `json:"extra"`
+	// Stale are the concepts of the index with a different hash than upstream, the concepts without a hash on either
+	// side are never stale
+	Stale   []string `json:"stale"`
+	Deleted int      `json:"deleted"`
+	// DeleteRefused is why the extra concepts were not deleted, empty if they were or deletes are disabled
+	DeleteRefused string `json:"deleteRefused,omitempty"`
+	Republished   int    `json:"republished"`
+}
+
+// upstreamConcept is a line of the upstream list, either a concept or the completeness trailer ending the list
+type upstreamConcept struct {
+	UUID     string `json:"uuid"`
+	Hash     string `json:"hash"`
+	Complete bool   `json:"complete"`
+	Count    int    `json:"count"`
+}
+
+// Reconciler compares the concepts of the index with the ones of the upstream concept store
+type Reconciler struct {
+	es      EsService
+	client  *http.Client
+	config  ReconcileConfig
+	running sync.Mutex
+
+	reportLock sync.RWMutex
+	lastReport *ReconcileReport
+}
+
+func NewReconciler(es EsService, client *http.Client, config ReconcileConfig) *Reconciler {
+	return &Reconciler{es: es, client: client, config: config}
+}
+
+// Schedule reconciles the index at every interval until ctx is done
+func (r *Reconciler) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if _, err := r.Run(ctx); err != nil {
+			log.WithError(err).Error("Scheduled reconciliation failed")
+		}
+	}
+}
+
+// LastReport returns the report of the last successful reconciliation, nil if there was none yet
+func (r *Reconciler) LastReport() *ReconcileReport {
+	r.reportLock.RLock()
+	defer r.reportLock.RUnlock()
+	return r.lastReport
+}
+
+// Run compares the upstream concepts with the index, then deletes the extra concepts and requests the republish of
+// the missing and stale ones if configured to
+func (r *Reconciler) Run(ctx context.Context) (*ReconcileReport, error) {
+	if !r.running.TryLock() {
+		return nil, ErrReconcileRunning
+	}
+	defer r.running.Unlock()
+
+	transactionID := tid.NewTransactionID()
+	ctx = tid.TransactionAwareContext(ctx, transactionID)
+	report := &ReconcileReport{StartedAt: time.Now()}
+
+	// the index is scanned first, so that a concept published during the reconciliation is at worst reported missing
+	// and republished, rather than reported extra and deleted
+	indexed, err := r.scanIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scanning the index: %w", err)
+	}
+	upstream, complete, err := r.fetchUpstream(ctx, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the upstream concepts: %w", err)
+	}
+	report.Upstream, report.Complete, report.Indexed = len(upstream), complete, len(indexed)
+
+	for uuid, hash := range upstream {
+		stored, found := indexed[uuid]
+		switch {
+		case !found:
+			report.Missing = append(report.Missing, uuid)
+		// a concept without a hash upstream or in the index cannot be told stale
+		case hash != "" && stored.Hash != "" && hash != stored.Hash:
+			report.Stale = append(report.Stale, uuid)
+		}
+	}
+	for uuid := range indexed {
+		if _, found := upstream[uuid]; !found {
+			report.Extra = append(report.Extra, uuid)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Stale)
+
+	if r.config.DeleteExtras {
+		report.Deleted, err = r.deleteExtras(ctx, report, indexed)
+		if err != nil {
+			log.WithError(err).WithTransactionID(transactionID).Error("The extra concepts are not deleted")
+			report.DeleteRefused = err.Error()
+		}
+	}
+	if r.config.RepublishURL != "" {
+		republished, err := r.requestRepublish(ctx, transactionID, append(append([]string{}, report.Missing...), report.Stale...))
+		if err != nil {
+			return nil, fmt.Errorf("requesting the republish of the concepts: %w", err)
+		}
+		report.Republished = republished
+	}
+	report.FinishedAt = time.Now()
+
+	log.WithTransactionID(transactionID).
+		WithField("upstream", report.Upstream).
+		WithField("indexed", report.Indexed).
+		WithField("missing", len(report.Missing)).
+		WithField("extra", len(report.Extra)).
+		WithField("stale", len(report.Stale)).
+		WithField("deleted", report.Deleted).
+		WithField("republished", report.Republished).
+		Info("Reconciled the index with the upstream concepts")
+
+	r.reportLock.Lock()
+	r.lastReport = report
+	r.reportLock.Unlock()
+	return report, nil
+}
+
+// fetchUpstream returns the hashes of the upstream concepts by uuid, and whether the list ended with a completeness
+// trailer matching the concepts listed
+func (r *Reconciler) fetchUpstream(ctx context.Context, transactionID string) (map[string]string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.config.SourceURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set(tid.TransactionIDHeader, transactionID)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, r.config.SourceURL)
+	}
+
+	concepts := make(map[string]string)
+	listed := 0
+	var trailer *upstreamConcept
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var concept upstreamConcept
+		err := dec.Decode(&concept)
+		if err == io.EOF {
+			return concepts, trailer != nil && trailer.Count == listed, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		// anything listed after the trailer means it did not end the list
+		trailer = nil
+		switch {
+		case concept.Complete:
+			trailer = &concept
+		case concept.UUID != "":
+			concepts[concept.UUID] = concept.Hash
+			listed++
+		}
+	}
+}
+
+func (r *Reconciler) scanIndex(ctx context.Context) (map[string]EsIDTypePair, error) {
+	pages, err := r.es.GetAllIDs(ctx, IDScan{IncludeTypes: true, IncludeHashes: true})
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := make(map[string]EsIDTypePair)
+	for page := range pages {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		for _, id := range page.IDs {
+			indexed[id.ID] = id
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return indexed, nil
+}
+
+// deleteExtras deletes the extra concepts from the index. None is deleted when the upstream list is not complete or
+// empty, or when there are more extra concepts than the delete limits.
+func (r *Reconciler) deleteExtras(ctx context.Context, report *ReconcileReport, indexed map[string]EsIDTypePair) (int, error) {
+	if !report.Complete {
+		return 0, ErrUpstreamIncomplete
+	}
+	if report.Upstream == 0 {
+		log.Warn("The upstream concept store listed no concept, the extra concepts are not deleted")
+		return 0, nil
+	}
+	extra := len(report.Extra)
+	if r.config.MaxDeletes > 0 && extra > r.config.MaxDeletes {
+		return 0, fmt.Errorf("%w: %d is more than %d", ErrTooManyExtras, extra, r.config.MaxDeletes)
+	}
+	if r.config.MaxDeleteRatio > 0 && float64(extra) > r.config.MaxDeleteRatio*float64(report.Indexed) {
+		return 0, fmt.Errorf("%w: %d is more than %g of the %d indexed concepts", ErrTooManyExtras, extra, r.config.MaxDeleteRatio, report.Indexed)
+	}
+
+	deleted := 0
+	for _, uuid := range report.Extra {
+		res, err := r.es.DeleteData(ctx, indexed[uuid].Type, uuid)
+		if err != nil {
+			log.WithError(err).WithUUID(uuid).Error("Failed to delete an extra concept")
+			continue
+		}
+		if res.Result != notFoundResult {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *Reconciler) requestRepublish(ctx context.Context, transactionID string, uuids []string) (int, error) {
+	if len(uuids) == 0 {
+		return 0, nil
+	}
+
+	body, err := json.Marshal(map[string][]string{"uuids": uuids})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.RepublishURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(tid.TransactionIDHeader, transactionID)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, r.config.RepublishURL)
+	}
+	return len(uuids), nil
+}
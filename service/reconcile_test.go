@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpstreamMock(t *testing.T, concepts ...upstreamConcept) *httptest.Server {
+	return newUpstreamMockWithTrailer(t, fmt.Sprintf("{\"complete\":true,\"count\":%d}\n", len(concepts)), concepts...)
+}
+
+func newUpstreamMockWithTrailer(t *testing.T, trailer string, concepts ...upstreamConcept) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("X-Request-Id"))
+		for _, concept := range concepts {
+			fmt.Fprintf(w, "{\"uuid\":\"%s\",\"hash\":\"%s\"}\n", concept.UUID, concept.Hash)
+		}
+		fmt.Fprint(w, trailer)
+	}))
+}
+
+func newReconcileTestService() EsService {
+	service := NewMemoryService(indexName)
+	service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Type: "genres", AggregateHash: "hash-1"})
+	service.LoadBulkData(context.Background(), "genres", "2", EsConceptModel{Type: "genres", AggregateHash: "old-hash-2"})
+	service.LoadBulkData(context.Background(), person, "4", EsConceptModel{Type: person, AggregateHash: "hash-4"})
+	return service
+}
+
+func TestReconcileReport(t *testing.T) {
+	upstream := newUpstreamMock(t, upstreamConcept{UUID: "1", Hash: "hash-1"}, upstreamConcept{UUID: "2", Hash: "hash-2"}, upstreamConcept{UUID: "3", Hash: "hash-3"})
+	defer upstream.Close()
+	service := newReconcileTestService()
+
+	reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL})
+	assert.Nil(t, reconciler.LastReport())
+
+	report, err := reconciler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Upstream)
+	assert.True(t, report.Complete)
+	assert.Equal(t, 3, report.Indexed)
+	assert.Equal(t, []string{"3"}, report.Missing)
+	assert.Equal(t, []string{"4"}, report.Extra)
+	assert.Equal(t, []string{"2"}, report.Stale)
+	assert.Zero(t, report.Deleted)
+	assert.Zero(t, report.Republished)
+	assert.Equal(t, report, reconciler.LastReport())
+
+	getResp, err := service.ReadData(context.Background(), "4")
+	require.NoError(t, err)
+	assert.True(t, getResp.Found, "extra concepts should only be reported by default")
+}
+
+func TestReconcileDeletesExtrasAndRequestsRepublish(t *testing.T) {
+	upstream := newUpstreamMock(t, upstreamConcept{UUID: "1", Hash: "hash-1"}, upstreamConcept{UUID: "2", Hash: "hash-2"}, upstreamConcept{UUID: "3", Hash: "hash-3"})
+	defer upstream.Close()
+	var republished map[string][]string
+	republish := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&republished))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer republish.Close()
+	service := newReconcileTestService()
+
+	reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL, DeleteExtras: true, RepublishURL: republish.URL})
+	report, err := reconciler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Deleted)
+	assert.Equal(t, 2, report.Republished)
+	assert.Equal(t, map[string][]string{"uuids": {"3", "2"}}, republished)
+
+	getResp, err := service.ReadData(context.Background(), "4")
+	require.NoError(t, err)
+	assert.False(t, getResp.Found, "extra concepts should be deleted")
+}
+
+func TestReconcileKeepsConceptsPublishedDuringTheRun(t *testing.T) {
+	service := newReconcileTestService()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// concept 5 is published after the upstream list was taken, while it is sent
+		service.LoadBulkData(context.Background(), "genres", "5", EsConceptModel{Type: "genres", AggregateHash: "hash-5"})
+		fmt.Fprint(w, "{\"uuid\":\"1\",\"hash\":\"hash-1\"}\n{\"complete\":true,\"count\":1}\n")
+	}))
+	defer upstream.Close()
+
+	reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL, DeleteExtras: true})
+	report, err := reconciler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Missing)
+	assert.Equal(t, []string{"2", "4"}, report.Extra)
+	assert.Equal(t, 2, report.Deleted)
+
+	getResp, err := service.ReadData(context.Background(), "5")
+	require.NoError(t, err)
+	assert.True(t, getResp.Found, "a concept published during the reconciliation should not be deleted")
+}
+
+func TestReconcileWithoutHashes(t *testing.T) {
+	upstream := newUpstreamMock(t, upstreamConcept{UUID: "1", Hash: "hash-1"}, upstreamConcept{UUID: "2"})
+	defer upstream.Close()
+	service := NewMemoryService(indexName)
+	service.LoadBulkData(context.Background(), "genres", "1", EsConceptModel{Type: "genres"})
+	service.LoadBulkData(context.Background(), "genres", "2", EsConceptModel{Type: "genres", AggregateHash: "hash-2"})
+
+	reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL})
+	report, err := reconciler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Stale, "a concept without a hash should not be reported stale")
+}
+
+func TestReconcileKeepsTheIndexWithoutUpstreamConcepts(t *testing.T) {
+	upstream := newUpstreamMock(t)
+	defer upstream.Close()
+	service := newReconcileTestService()
+
+	reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL, DeleteExtras: true})
+	report, err := reconciler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, report.Extra, 3)
+	assert.Zero(t, report.Deleted)
+}
+
+func TestReconcileKeepsTheIndexWithAnIncompleteUpstreamList(t *testing.T) {
+	testCases := []struct {
+		name    string
+		trailer string
+	}{
+		{name: "No trailer", trailer: ""},
+		{name: "Trailer with another count", trailer: `{"complete":true,"count":3}` + "\n"},
+		{name: "Concepts after the trailer", trailer: `{"complete":true,"count":2}` + "\n" + `{"uuid":"3","hash":"hash-3"}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := newUpstreamMockWithTrailer(t, tc.trailer, upstreamConcept{UUID: "1", Hash: "hash-1"}, upstreamConcept{UUID: "2", Hash: "hash-2"})
+			defer upstream.Close()
+			service := newReconcileTestService()
+
+			reconciler := NewReconciler(service, http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL, DeleteExtras: true})
+			report, err := reconciler.Run(context.Background())
+			require.NoError(t, err)
+			assert.False(t, report.Complete)
+			assert.Equal(t, []string{"4"}, report.Extra)
+			assert.Zero(t, report.Deleted)
+			assert.Equal(t, ErrUpstreamIncomplete.Error(), report.DeleteRefused)
+
+			getResp, err := service.ReadData(context.Background(), "4")
+			require.NoError(t, err)
+			assert.True(t, getResp.Found, "extra concepts should not be deleted after an incomplete upstream list")
+		})
+	}
+}
+
+func TestReconcileRefusesToDeleteAboveTheLimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  ReconcileConfig
+		deleted int
+		refused string
+	}{
+		{name: "Below the limits", config: ReconcileConfig{MaxDeletes: 2, MaxDeleteRatio: 0.7}, deleted: 2},
+		{name: "Above the count", config: ReconcileConfig{MaxDeletes: 1}, refused: "too many extra concepts to delete: 2 is more than 1"},
+		{name: "Above the ratio", config: ReconcileConfig{MaxDeleteRatio: 0.5}, refused: "too many extra concepts to delete: 2 is more than 0.5 of the 3 indexed concepts"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := newUpstreamMock(t, upstreamConcept{UUID: "1", Hash: "hash-1"})
+			defer upstream.Close()
+			service := newReconcileTestService()
+
+			tc.config.SourceURL, tc.config.DeleteExtras = upstream.URL, true
+			report, err := NewReconciler(service, http.DefaultClient, tc.config).Run(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []string{"2", "4"}, report.Extra)
+			assert.Equal(t, tc.deleted, report.Deleted)
+			assert.Equal(t, tc.refused, report.DeleteRefused)
+		})
+	}
+}
+
+func TestReconcileErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	upstream := newUpstreamMock(t, upstreamConcept{UUID: "3", Hash: "hash-3"})
+	defer upstream.Close()
+
+	reconciler := NewReconciler(newReconcileTestService(), http.DefaultClient, ReconcileConfig{SourceURL: failing.URL})
+	_, err := reconciler.Run(context.Background())
+	assert.Error(t, err)
+
+	reconciler = NewReconciler(newReconcileTestService(), http.DefaultClient, ReconcileConfig{SourceURL: upstream.URL, RepublishURL: failing.URL})
+	_, err = reconciler.Run(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, reconciler.LastReport(), "a failed reconciliation should not be reported")
+
+	reconciler.running.Lock()
+	_, err = reconciler.Run(context.Background())
+	assert.Equal(t, ErrReconcileRunning, err)
+}
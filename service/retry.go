@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrCircuitOpen = errors.New("elasticsearch is overloaded, the circuit breaker is open")
+
+const (
+	readOperation   = "read"
+	findOperation   = "find"
+	healthOperation = "health"
+
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+	CircuitDisabled = "disabled"
+
+	// probeRetryAfter is how long the calls are told to wait while the half-open circuit breaker probes the cluster
+	probeRetryAfter = time.Second
+)
+
+// RetryPolicy is how an elasticsearch operation is retried when the cluster is overloaded
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff returns the jittered wait before the retry following the given attempt, which grows exponentially up to
+// MaxBackoff
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(wait) + 1))
+}
+
+// RetryConfig holds the retry policy of each synchronous elasticsearch operation and the circuit breaker settings
+type RetryConfig struct {
+	Write  RetryPolicy
+	Read   RetryPolicy
+	Delete RetryPolicy
+	Find   RetryPolicy
+	// Health is the policy of the cluster health and index settings checks
+	Health RetryPolicy
+	// BreakerThreshold is the number of consecutive overloaded calls opening the circuit breaker, 0 disables it
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit breaker stays open before letting calls through again
+	BreakerCooldown time.Duration
+}
+
+// policy returns the retry policy of the operation, a single attempt when retries are not configured
+func (c *RetryConfig) policy(operation string) RetryPolicy {
+	if c == nil {
+		return RetryPolicy{MaxAttempts: 1}
+	}
+	switch operation {
+	case writeOperation:
+		return c.Write
+	case readOperation:
+		return c.Read
+	case deleteOperation:
+		return c.Delete
+	case findOperation:
+		return c.Find
+	case healthOperation:
+		return c.Health
+	}
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// CircuitOpenError is returned without calling elasticsearch while the circuit breaker is open
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %v", ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreakerState is the state of the circuit breaker reported by the health checks
+type CircuitBreakerState struct {
+	State               string        `json:"state"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	RetryAfter          time.Duration `json:"-"`
+}
+
+// Open reports whether the elasticsearch calls fail fast
+func (s CircuitBreakerState) Open() bool {
+	return s.State == CircuitOpen
+}
+
+// circuitBreaker fails the elasticsearch calls fast after threshold consecutive overloaded calls. Once the cooldown
+// is over a single call goes through as a probe while the others still fail fast: an overloaded probe opens it for
+// another cooldown and a successful one closes it.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(config *RetryConfig) *circuitBreaker {
+	if config == nil || config.BreakerThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: config.BreakerThreshold, cooldown: config.BreakerCooldown, now: time.Now}
+}
+
+// allow returns a CircuitOpenError while the circuit breaker is open. Once the cooldown is over the call is let through
+// as the probe of the cluster, and the other calls are rejected until the outcome of the probe is recorded.
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	if err := b.rejection(); err != nil {
+		return false, err
+	}
+	if b.failures < b.threshold {
+		return false, nil
+	}
+	b.probing = true
+	return true, nil
+}
+
+// check returns the CircuitOpenError allow would return, without taking the probe of the half-open circuit breaker.
+// It fails an operation fast before its first call.
+func (b *circuitBreaker) check() error {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+	return b.rejection()
+}
+
+func (b *circuitBreaker) rejection() error {
+	if b.failures < b.threshold {
+		return nil
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return &CircuitOpenError{RetryAfter: wait}
+	}
+	if b.probing {
+		return &CircuitOpenError{RetryAfter: probeRetryAfter}
+	}
+	return nil
+}
+
+// record counts the overloaded calls, a call answered otherwise by the cluster closes the circuit breaker. The outcome
+// of the probe ends the half-open state whatever it is, so that a probe failing for another reason is retried.
+func (b *circuitBreaker) record(err error, probe bool) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	var esErr *elastic.Error
+	switch {
+	case overloaded(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	case err == nil, errors.As(err, &esErr):
+		b.failures = 0
+	}
+}
+
+func (b *circuitBreaker) state() CircuitBreakerState {
+	if b == nil {
+		return CircuitBreakerState{State: CircuitDisabled}
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	state := CircuitBreakerState{State: CircuitClosed, ConsecutiveFailures: b.failures}
+	if b.failures >= b.threshold {
+		state.State = CircuitHalfOpen
+		if wait := b.openUntil.Sub(b.now()); wait > 0 {
+			state.State = CircuitOpen
+			state.RetryAfter = wait
+		}
+	}
+	return state
+}
+
+// overloaded reports whether elasticsearch rejected the call because it is overloaded, which is worth retrying
+func overloaded(err error) bool {
+	var esErr *elastic.Error
+	if !errors.As(err, &esErr) {
+		return false
+	}
+	return esErr.Status == http.StatusTooManyRequests || esErr.Status == http.StatusServiceUnavailable
+}
+
+// do runs the elasticsearch call of the operation, retrying it with its policy while the cluster is overloaded and
+// failing fast while the circuit breaker is open. The caller must not hold the lock of the service, which would block
+// the replacement of the client during the backoff: it calls the client it snapshot with client().
+func (es *esService) do(ctx context.Context, operation string, call func() error) error {
+	probe, err := es.breaker.allow()
+	if err != nil {
+		return err
+	}
+
+	policy := es.retry.policy(operation)
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if !overloaded(err) || attempt >= policy.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-ctx.Done():
+			es.breaker.record(err, probe)
+			return err
+		}
+	}
+	es.breaker.record(err, probe)
+	return err
+}
+
+func (es *esService) CircuitBreaker() CircuitBreakerState {
+	return es.breaker.state()
+}
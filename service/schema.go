@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	ontology "github.com/Financial-Times/cm-graph-ontology/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultSchemaVersion is the version of the payload schemas used when the concept type does not configure one
+const DefaultSchemaVersion = "v1"
+
+const (
+	schemaDir         = "schemas"
+	definitionsSchema = "definitions.json"
+)
+
+//go:embed schemas
+var schemaFiles embed.FS
+
+// ErrInvalidPayload is the error of a concept payload not matching its schema
+var ErrInvalidPayload = errors.New("invalid concept payload")
+
+// FieldError is a problem with a field of a concept payload, the field is a JSON pointer to it, e.g.
+// /sourceRepresentations/0/uuid, and is empty for the payload itself
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PayloadError lists the fields of a concept payload not matching its schema
+type PayloadError struct {
+	Schema string
+	Fields []FieldError
+}
+
+func (e *PayloadError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Field + ": " + field.Message
+	}
+	return fmt.Sprintf("%s for schema %s: %s", ErrInvalidPayload, e.Schema, strings.Join(problems, ", "))
+}
+
+func (e *PayloadError) Unwrap() error {
+	return ErrInvalidPayload
+}
+
+// payloadSchemas compiles the embedded schemas once, they are keyed by their path within the schemas directory,
+// e.g. v1/concept.json
+var payloadSchemas = sync.OnceValues(compileSchemas)
+
+func compileSchemas() (map[string]*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	compiler.Formats["ontology-type"] = isOntologyType
+
+	var names []string
+	err := fs.WalkDir(schemaFiles, schemaDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := compiler.AddResource(schemaURL(file), bytes.NewReader(content)); err != nil {
+			return err
+		}
+		if path.Base(file) != definitionsSchema {
+			names = append(names, strings.TrimPrefix(file, schemaDir+"/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading the payload schemas: %w", err)
+	}
+
+	schemas := make(map[string]*jsonschema.Schema, len(names))
+	for _, name := range names {
+		schema, err := compiler.Compile(schemaURL(path.Join(schemaDir, name)))
+		if err != nil {
+			return nil, fmt.Errorf("compiling the payload schema %s: %w", name, err)
+		}
+		schemas[name] = schema
+	}
+	return schemas, nil
+}
+
+// schemaURL is the URL the schema file is known by, which resolves the relative references between the schemas
+func schemaURL(file string) string {
+	return "file:///" + file
+}
+
+// isOntologyType reports whether the directType is a type of the ontology
+func isOntologyType(v interface{}) bool {
+	directType, ok := v.(string)
+	if !ok {
+		return true
+	}
+	_, err := ontology.FullTypeHierarchy(directType)
+	return err == nil
+}
+
+// schemaName returns the schema of the payloads converted by the converter in the given version
+func schemaName(version, converter string) string {
+	if converter == MembershipConverter {
+		return version + "/membership.json"
+	}
+	return version + "/concept.json"
+}
+
+// payloadSchema returns the schema of the payloads converted by the converter in the given version
+func payloadSchema(version, converter string) (*jsonschema.Schema, error) {
+	schemas, err := payloadSchemas()
+	if err != nil {
+		return nil, err
+	}
+	schema, found := schemas[schemaName(version, converter)]
+	if !found {
+		return nil, fmt.Errorf("unknown schema version %q", version)
+	}
+	return schema, nil
+}
+
+// ValidatePayload validates the concept payload against the schema of its converter in the given version, a payload
+// not matching it returns a PayloadError listing the invalid fields
+func ValidatePayload(version, converter string, body []byte) error {
+	schema, err := payloadSchema(version, converter)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var payload interface{}
+	if err := dec.Decode(&payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	err = schema.Validate(payload)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	return &PayloadError{Schema: schemaName(version, converter), Fields: fieldErrors(validationErr)}
+}
+
+// fieldErrors returns the sorted problems of the fields, which are the causes the validation error ends with
+func fieldErrors(err *jsonschema.ValidationError) []FieldError {
+	var fields []FieldError
+	var collect func(*jsonschema.ValidationError)
+	collect = func(err *jsonschema.ValidationError) {
+		for _, cause := range err.Causes {
+			collect(cause)
+		}
+		if len(err.Causes) > 0 {
+			return
+		}
+		if strings.HasSuffix(err.KeywordLocation, "/required") {
+			fields = append(fields, missingFields(err)...)
+			return
+		}
+		fields = append(fields, FieldError{Field: err.InstanceLocation, Message: err.Message})
+	}
+	collect(err)
+
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Field != fields[j].Field {
+			return fields[i].Field < fields[j].Field
+		}
+		return fields[i].Message < fields[j].Message
+	})
+	return fields
+}
+
+// missingFields reports each of the missing properties of a required error on its own field
+func missingFields(err *jsonschema.ValidationError) []FieldError {
+	var fields []FieldError
+	for _, name := range strings.Split(strings.TrimPrefix(err.Message, "missing properties: "), ", ") {
+		fields = append(fields, FieldError{Field: err.InstanceLocation + "/" + strings.Trim(name, "'"), Message: "is required"})
+	}
+	return fields
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePayload(t *testing.T) {
+	testCases := []struct {
+		name      string
+		converter string
+		payload   string
+		fields    []FieldError
+	}{
+		{
+			name:      "Concept model",
+			converter: ConceptConverter,
+			payload:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre","aliases":["Reports"],"alternativeIdentifiers":{"TME":["Mg==-R2VucmVz"],"uuids":["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]}}`,
+		},
+		{
+			name:      "Aggregate concept model",
+			converter: OrganisationConverter,
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Apple, Inc.","type":"PublicCompany","countryCode":"US","sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","authority":"Smartlogic","lastModifiedEpoch":1498127042}]}`,
+		},
+		{
+			name:      "Membership",
+			converter: MembershipConverter,
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Journalist","type":"Membership","membershipRoles":[{"membershipRoleUUID":"9c21f096-0af5-4c16-a513-a64a1abe42fa"}],"organisationUUID":["cf73d36c-330b-48ae-a2aa-8cb3602855cc"],"personUUID":["efcd7388-49d7-4fa1-b8f3-baf59fbf28eb"],"sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580"}]}`,
+		},
+		{
+			name:      "Missing fields of the concept model",
+			converter: ConceptConverter,
+			payload:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580"}`,
+			fields: []FieldError{
+				{Field: "/prefLabel", Message: "is required"},
+				{Field: "/type", Message: "is required"},
+			},
+		},
+		{
+			name:      "Invalid fields of the aggregate concept model",
+			converter: PersonConverter,
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"","type":"Person","aliases":"Anna","sourceRepresentations":[{"uuid":"8ff7dfef"}]}`,
+			fields: []FieldError{
+				{Field: "/aliases", Message: "expected array, but got string"},
+				{Field: "/prefLabel", Message: "length must be >= 1, but got 0"},
+				{Field: "/sourceRepresentations/0/uuid", Message: "'8ff7dfef' is not valid 'uuid'"},
+			},
+		},
+		{
+			name:      "Membership of several people",
+			converter: MembershipConverter,
+			payload:   `{"prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Journalist","type":"Membership","organisationUUID":["cf73d36c-330b-48ae-a2aa-8cb3602855cc"],"personUUID":["efcd7388-49d7-4fa1-b8f3-baf59fbf28eb","0c6f72df-ce99-4bd2-8336-e89d7ce2d1e6"],"sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580"}]}`,
+			fields: []FieldError{
+				{Field: "/personUUID", Message: "maximum 1 items required, but found 2 items"},
+			},
+		},
+		{
+			name:      "Membership in the concept model",
+			converter: MembershipConverter,
+			payload:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Journalist","type":"Membership"}`,
+			fields: []FieldError{
+				{Field: "/organisationUUID", Message: "is required"},
+				{Field: "/personUUID", Message: "is required"},
+				{Field: "/prefUUID", Message: "is required"},
+				{Field: "/sourceRepresentations", Message: "is required"},
+			},
+		},
+		{
+			name:      "Concept model with a prefUUID",
+			converter: ConceptConverter,
+			payload:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefUUID":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Market Report","type":"Genre"}`,
+		},
+		{
+			name:      "Aggregate concept model without a prefUUID",
+			converter: OrganisationConverter,
+			payload:   `{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580","prefLabel":"Apple, Inc.","type":"PublicCompany","sourceRepresentations":[{"uuid":"8ff7dfef-0330-3de0-b37a-2d6aa9c98580"}]}`,
+			fields: []FieldError{
+				{Field: "/prefUUID", Message: "is required"},
+			},
+		},
+		{
+			name:      "Not an object",
+			converter: ConceptConverter,
+			payload:   `["8ff7dfef-0330-3de0-b37a-2d6aa9c98580"]`,
+			fields: []FieldError{
+				{Field: "", Message: "expected object, but got array"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePayload(DefaultSchemaVersion, tc.converter, []byte(tc.payload))
+			if tc.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var payloadErr *PayloadError
+			require.True(t, errors.As(err, &payloadErr), "expected a payload error, got %v", err)
+			assert.True(t, errors.Is(err, ErrInvalidPayload))
+			assert.Equal(t, tc.fields, payloadErr.Fields)
+		})
+	}
+}
+
+func TestValidatePayloadErrors(t *testing.T) {
+	err := ValidatePayload("v0", ConceptConverter, []byte(`{}`))
+	assert.EqualError(t, err, `unknown schema version "v0"`)
+
+	err = ValidatePayload(DefaultSchemaVersion, ConceptConverter, []byte(`{"uuid":`))
+	assert.True(t, errors.Is(err, ErrInvalidPayload))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// scanOperation is the opening of the point in time of an ID scan and the fetch of each of its pages
+const scanOperation = "scan"
+
+// TimeoutConfig holds how long each elasticsearch operation may take including its retries, 0 leaves it to the
+// request context
+type TimeoutConfig struct {
+	Write  time.Duration
+	Read   time.Duration
+	Delete time.Duration
+	Find   time.Duration
+	// Health bounds the cluster health and index settings checks
+	Health time.Duration
+	// Scan bounds the opening of the point in time of an ID scan and each of its pages, rather than the whole scan
+	Scan time.Duration
+}
+
+// timeout returns the timeout of the operation, 0 when timeouts are not configured
+func (c *TimeoutConfig) timeout(operation string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	switch operation {
+	case writeOperation:
+		return c.Write
+	case readOperation:
+		return c.Read
+	case deleteOperation:
+		return c.Delete
+	case findOperation:
+		return c.Find
+	case healthOperation:
+		return c.Health
+	case scanOperation:
+		return c.Scan
+	}
+	return 0
+}
+
+// withDeadline returns the context of the operation, bounded by its timeout
+func (es *esService) withDeadline(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if timeout := es.timeouts.timeout(operation); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
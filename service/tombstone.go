@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrConceptDeleted = errors.New("the concept was deleted after it was last modified")
+
+const (
+	// TombstoneReasonDeleted marks a concept deleted through the API
+	TombstoneReasonDeleted = "deleted"
+	// TombstoneReasonConcorded marks a concept deleted because it was concorded into another one
+	TombstoneReasonConcorded = "concorded"
+
+	tombstoneDeletedAtField = "deletedAt"
+)
+
+// TombstoneConfig enables soft deletes: a deleted concept leaves a tombstone in Index, and the writes of the concept
+// last modified before the tombstone are rejected
+type TombstoneConfig struct {
+	Index string
+}
+
+// Tombstone records the deletion of a concept
+type Tombstone struct {
+	ID            string `json:"id"`
+	ConceptType   string `json:"conceptType,omitempty"`
+	DeletedAt     string `json:"deletedAt"`
+	TransactionID string `json:"transactionId"`
+	Reason        string `json:"reason"`
+}
+
+func newTombstone(ctx context.Context, conceptType string, uuid string, reason string, now time.Time) Tombstone {
+	transactionID, err := tid.GetTransactionIDFromContext(ctx)
+	if err != nil {
+		transactionID = tidNotFound
+	}
+	return Tombstone{
+		ID:            uuid,
+		ConceptType:   conceptType,
+		DeletedAt:     now.Format(time.RFC3339),
+		TransactionID: transactionID,
+		Reason:        reason,
+	}
+}
+
+type recreateKey struct{}
+
+// WithRecreate returns a context making LoadData write a deleted concept again whatever its upstream last modified
+// date, removing its tombstone
+func WithRecreate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recreateKey{}, true)
+}
+
+// recreateFromContext reports whether the write explicitly re-creates a deleted concept
+func recreateFromContext(ctx context.Context) bool {
+	recreate, _ := ctx.Value(recreateKey{}).(bool)
+	return recreate
+}
+
+// rejects reports whether the concept document was last modified upstream before the tombstone. A concept document
+// without an upstream last modified date cannot be told apart from a late republish, so it is rejected until the
+// tombstone is purged, unless the write explicitly re-creates the concept.
+func (t Tombstone) rejects(ctx context.Context, payload interface{}) bool {
+	if recreateFromContext(ctx) {
+		return false
+	}
+	esModel := conceptModel(payload)
+	if esModel == nil {
+		return false
+	}
+	lastModified, ok := esModel.upstreamLastModified()
+	if !ok {
+		return true
+	}
+	deletedAt, err := time.Parse(time.RFC3339, t.DeletedAt)
+	return err == nil && lastModified.Before(deletedAt)
+}
+
+// PurgeTombstones removes the tombstones of the concepts deleted before the given time from the tombstone index and
+// returns how many were removed
+func PurgeTombstones(ctx context.Context, client *elastic.Client, index string, before time.Time) (int64, error) {
+	resp, err := client.DeleteByQuery(index).
+		Query(elastic.NewRangeQuery(tombstoneDeletedAtField).Lt(before.Format(time.RFC3339))).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}